@@ -23,7 +23,115 @@ type Promote struct {
 
 // PromoteSpec defines the desired state of Promote.
 type PromoteSpec struct {
+	// RuleSpec the single promotion rule to use. This is the simplest form of configuration and is evaluated
+	// before any Rules
+	RuleSpec `json:",inline"`
 
+	// Rules specifies a list of promotion rules which are evaluated in order against the same git clone
+	// of the environment. If any rule fails then the changes made by the previous rules are reverted
+	Rules []RuleSpec `json:"rules,omitempty"`
+
+	// Policy the semantic version policy which versions must satisfy to be promoted into the Environment
+	Policy *PolicySpec `json:"policy,omitempty"`
+
+	// EnvironmentPolicies the policies indexed by Environment name which are used instead of the Policy for those
+	// Environments. This is useful when multiple Environments share the same git repository
+	EnvironmentPolicies map[string]PolicySpec `json:"environmentPolicies,omitempty"`
+
+	// FreezeWindows the change freezes during which promotions into the Environment are not allowed
+	FreezeWindows []FreezeWindow `json:"freezeWindows,omitempty"`
+
+	// PullRequest the templates of the Pull Request and commit used to promote into the Environment and how the
+	// Pull Request is merged
+	PullRequest *PullRequestSpec `json:"pullRequest,omitempty"`
+}
+
+// PullRequestSpec specifies the go templates used to create the promotion Pull Request and commit along with the
+// merge method. Blank templates use the default text
+type PullRequestSpec struct {
+	// Title the template of the Pull Request title such as `chore(deps): promote {{.AppName}} to {{.Version}}`
+	Title string `json:"title,omitempty"`
+
+	// Body the template of the Pull Request body
+	Body string `json:"body,omitempty"`
+
+	// Branch the template of the branch name such as `promote/{{.Environment}}/{{.AppName}}`. Defaults to a
+	// generated branch name. If the template has no version an open Pull Request of the branch is updated by the
+	// next promotion
+	Branch string `json:"branch,omitempty"`
+
+	// CommitMessage the template of the commit message
+	CommitMessage string `json:"commitMessage,omitempty"`
+
+	// MergeCommitTitle the template of the title of the commit created when merging the Pull Request. Defaults to
+	// the Pull Request title when squashing
+	MergeCommitTitle string `json:"mergeCommitTitle,omitempty"`
+
+	// MergeMethod how the Pull Request is merged. Either `merge`, `squash` or `rebase`. Defaults to the default of
+	// the git provider
+	MergeMethod string `json:"mergeMethod,omitempty"`
+}
+
+// FreezeWindow specifies a change freeze which is either recurring, using a cron expression and a duration, or an
+// absolute range between a start and end time
+type FreezeWindow struct {
+	// Name the name of the freeze used in messages such as `weekend`
+	Name string `json:"name,omitempty"`
+
+	// Cron the cron expression `minute hour day-of-month month day-of-week` of the start of a recurring freeze such
+	// as `0 18 * * 5` for Friday at 6pm
+	Cron string `json:"cron,omitempty"`
+
+	// Duration the duration of a recurring freeze such as `62h`
+	Duration string `json:"duration,omitempty"`
+
+	// Start the start of an absolute freeze in RFC 3339 format or `2006-01-02 15:04` in the TimeZone
+	Start string `json:"start,omitempty"`
+
+	// End the end of an absolute freeze in RFC 3339 format or `2006-01-02 15:04` in the TimeZone
+	End string `json:"end,omitempty"`
+
+	// TimeZone the IANA time zone such as `Europe/London` used for the cron expression and times. Defaults to UTC
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Action what to do when promoting during the freeze. Either `Fail`, `Skip` to skip the promotion or `Queue` to
+	// wait for the freeze to end. Defaults to `Fail`
+	Action string `json:"action,omitempty"`
+
+	// Environments the names of the Environments the freeze applies to. Defaults to all Environments
+	Environments []string `json:"environments,omitempty"`
+}
+
+// PolicySpec specifies which semantic versions can be promoted into an Environment.
+//
+// Policies are checked against the version currently declared in the environment git repository before any
+// Pull Request is created. A promotion refused by the policy can be overridden via the `--force` flag unlike a
+// promotion refused by the promotion chain or soak time
+type PolicySpec struct {
+	// Constraint the semantic version range the version must satisfy such as `>=1.0.0 <2.0.0`
+	Constraint string `json:"constraint,omitempty"`
+
+	// PreventDowngrade if enabled versions lower than the currently declared version are refused
+	PreventDowngrade bool `json:"preventDowngrade,omitempty"`
+
+	// DenyPrerelease if enabled prerelease versions such as `1.2.3-rc.1` are refused
+	DenyPrerelease bool `json:"denyPrerelease,omitempty"`
+
+	// AutomaticUpgrade the largest upgrade allowed when promoting to an Environment with the `Auto` promotion
+	// strategy. Either `patch`, `minor` or `major`. Defaults to allowing any upgrade
+	AutomaticUpgrade string `json:"automaticUpgrade,omitempty"`
+
+	// RequirePromotionChain if enabled the version, or a newer version, must have been merged into every permanent
+	// Environment with a lower order before it can be promoted
+	RequirePromotionChain bool `json:"requirePromotionChain,omitempty"`
+
+	// SoakTime the minimum duration such as `24h` the version must have been merged into every permanent Environment
+	// with a lower order before it can be promoted. Implies RequirePromotionChain
+	SoakTime string `json:"soakTime,omitempty"`
+}
+
+// RuleSpec specifies a promotion rule. Only one of the rule kinds should be specified
+type RuleSpec struct {
 	// AppsRule uses a 'jx-apps.yml` file to store apps to be deployed
 	AppsRule *AppsRule `json:"appsRule,omitempty"`
 
@@ -39,6 +147,19 @@ type PromoteSpec struct {
 
 	// KptRule specifies to fetch the apps resource via kpt : https://googlecontainertools.github.io/kpt/
 	KptRule *KptRule `json:"kptRule,omitempty"`
+
+	// KustomizeRule specifies a 'kustomization.yaml' file to promote to by modifying its images or helm charts
+	KustomizeRule *KustomizeRule `json:"kustomizeRule,omitempty"`
+
+	// PathRule specifies a YAML or JSON file and the paths within it to set such as the image tag in a
+	// 'values.yaml' file or the version in a 'package.json' file
+	PathRule *PathRule `json:"pathRule,omitempty"`
+
+	// ArgoCDRule specifies to promote by modifying the Argo CD Application or ApplicationSet for the app
+	ArgoCDRule *ArgoCDRule `json:"argoCDRule,omitempty"`
+
+	// FluxRule specifies to promote by modifying the Flux HelmRelease for the app
+	FluxRule *FluxRule `json:"fluxRule,omitempty"`
 }
 
 // AppsRule uses a 'jx-apps.yml` file to store apps to be deployed
@@ -50,10 +171,15 @@ type AppsRule struct {
 	Namespace string `json:"namespace"`
 }
 
-// HelmRule specifies which chart to add the app to the Chart's 'requirements.yaml' file
+// HelmRule specifies which chart to add the app to. Helm 3 charts using `apiVersion: v2` have the app added to the
+// dependencies in the 'Chart.yaml' file otherwise the app is added to the 'requirements.yaml' file
 type HelmRule struct {
-	// Path to the chart folder (which should contain Chart.yaml and requirements.yaml)
+	// Path to the chart folder (which should contain Chart.yaml and optionally requirements.yaml)
 	Path string `json:"path"`
+
+	// MigrateRequirements if enabled the chart is migrated to a Helm 3 `apiVersion: v2` chart by moving any
+	// dependencies in the 'requirements.yaml' file into the 'Chart.yaml' file and removing the 'requirements.yaml' file
+	MigrateRequirements bool `json:"migrateRequirements,omitempty"`
 }
 
 // HelmfileRule specifies which 'helmfile.yaml' file to use to promote the app into
@@ -63,9 +189,21 @@ type HelmfileRule struct {
 	// Namespace if specified the given namespace is used in the `helmfile.yml` file when using Environments in the
 	// same cluster using the same git repository URL as the dev environment
 	Namespace string `json:"namespace"`
+	// ReleaseName if specified only the releases with the given name are promoted. This is a go template so it can
+	// refer to the app such as `{{ .AppName }}-canary`
+	ReleaseName string `json:"releaseName,omitempty"`
+	// Selector if specified only the releases matching the label selector are promoted. As with helmfile selectors
+	// releases also have the implicit `name`, `namespace` and `chart` labels
+	Selector string `json:"selector,omitempty"`
+	// Chart if specified only the releases using the chart name are promoted. This can be the chart name with or
+	// without the repository prefix
+	Chart string `json:"chart,omitempty"`
 }
 
 // KptRule specifies to fetch the apps resource via kpt : https://googlecontainertools.github.io/kpt/
+//
+// By default packages are fetched with git and the upstream and upstreamLock of the 'Kptfile' are updated without
+// needing the kpt binary. Updates use a three way merge so that local changes to the package are kept
 type KptRule struct {
 	// Path specifies the folder to fetch kpt resources into.
 	// For example if the 'config-root'' directory contains a Config Sync git layout we may want applications to be deployed into the
@@ -75,6 +213,102 @@ type KptRule struct {
 	// Namespace specifies the namespace to deploy applications if using kpt. If specified this value will be used instead
 	// of the Environment.Spec.Namespace in the Environment CRD
 	Namespace string `json:"namespace,omitempty"`
+
+	// PackagePath the path of the package in the git repository of the app. This is a go template which defaults to
+	// `charts/{{ .AppName }}/resources`
+	PackagePath string `json:"packagePath,omitempty"`
+
+	// RefTemplate the go template of the git ref of the package such as `release-{{ .Version }}`. Defaults to the
+	// version with a `v` prefix or `master` if there is no version
+	RefTemplate string `json:"refTemplate,omitempty"`
+
+	// DirTemplate the go template of the folder inside the Path to fetch the package into. Defaults to `{{ .AppName }}`
+	DirTemplate string `json:"dirTemplate,omitempty"`
+
+	// UseCLI if enabled the `kpt` binary is used to fetch and update packages rather than the native implementation
+	UseCLI bool `json:"useCLI,omitempty"`
+}
+
+// KustomizeRule specifies which 'kustomization.yaml' file to modify to promote the app.
+//
+// Any 'images' entries for the app have their tag or digest updated and any 'helmCharts' entries for the app have
+// their version updated. If the app is not found then a new 'images' entry is added if an Image is specified
+// otherwise a new 'helmCharts' entry is added
+type KustomizeRule struct {
+	// Path to the kustomization file to modify. Defaults to `kustomization.yaml`
+	Path string `json:"path,omitempty"`
+
+	// Image the name of the container image of the app in the 'images' section. Defaults to matching any image
+	// whose last path segment is the app name. This is a go template which can use the app name and version
+	Image string `json:"image,omitempty"`
+
+	// Namespace if specified the given namespace is used for new 'helmCharts' entries
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// PathRule specifies a YAML or JSON file and the paths within it to modify to promote the app.
+//
+// YAML files may contain multiple documents in which case each value is set in every document which contains
+// the path. Comments and the order of keys are retained
+type PathRule struct {
+	// Path the path to the YAML or JSON file to modify. Files ending in `.json` are treated as JSON. This is mandatory
+	Path string `json:"path"`
+
+	// Values the paths and values to set in the file
+	Values []PathValue `json:"values,omitempty"`
+}
+
+// PathValue specifies a path expression and the value to set at that path
+type PathValue struct {
+	// Expression the JSONPath or yq style path expression of the field to modify such as `.image.tag`,
+	// `$.spec.containers[0].image` or `.releases[name=myapp].version`. This is a go template which can use the
+	// app name and version
+	Expression string `json:"expression"`
+
+	// Value the go template of the value to set such as `{{.Version}}`. Defaults to the version
+	Value string `json:"value,omitempty"`
+}
+
+// ArgoCDRule specifies how to find and modify the Argo CD Application or ApplicationSet resources for the app.
+//
+// The 'targetRevision' of the source is set to the version, which is either the chart version or git revision.
+// If no resource is found for the app then a new Application is created
+type ArgoCDRule struct {
+	// Path the folder containing the Argo CD resources. Defaults to the root of the repository
+	Path string `json:"path,omitempty"`
+
+	// Selector the label selector used to find the resources for the app such as `app.kubernetes.io/name={{.AppName}}`.
+	// Defaults to matching resources whose name is the app name. This is a go template which can use the app name
+	Selector string `json:"selector,omitempty"`
+
+	// VersionParameter if specified the helm parameter of this name is also set to the version such as `image.tag`
+	VersionParameter string `json:"versionParameter,omitempty"`
+
+	// RevisionTemplate the go template of the target revision of git sources such as `release-{{ .Version }}`.
+	// Defaults to the version with a `v` prefix. Helm chart sources always use the version
+	RevisionTemplate string `json:"revisionTemplate,omitempty"`
+
+	// Template the path of a go template file used to create a new Application if the app is not found.
+	// Defaults to an Application for the chart of the app
+	Template string `json:"template,omitempty"`
+
+	// Namespace the destination namespace for new Applications. Defaults to the namespace of the Environment
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// FluxRule specifies how to find and modify the Flux HelmRelease resources for the app.
+//
+// The chart version of any HelmRelease for the app is set to the version. If no HelmRelease is found then a new one
+// is created along with a HelmRepository for the chart repository if it is not declared yet
+type FluxRule struct {
+	// Path the folder containing the Flux resources. Defaults to the root of the repository
+	Path string `json:"path,omitempty"`
+
+	// Namespace the namespace of the HelmRelease. Defaults to the namespace of the Environment
+	Namespace string `json:"namespace,omitempty"`
+
+	// RepositoryNamespace the namespace of new HelmRepository resources. Defaults to `flux-system`
+	RepositoryNamespace string `json:"repositoryNamespace,omitempty"`
 }
 
 // FileRule specifies how to modify a 'Makefile` or shell script to add a new helm/kpt style command
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x/jx-helpers/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-promote/pkg/common"
+	"github.com/jenkins-x/jx-promote/pkg/promote"
+	"github.com/spf13/cobra"
+)
+
+var (
+	applyLong = templates.LongDesc(`
+		Promotes a version of an application into a local Environment git repository directory.
+
+		No kubernetes cluster or git server is required and the changes are neither committed nor pushed
+`)
+
+	applyExample = templates.Examples(`
+		# promotes version 1.2.3 of myapp into the current directory
+		%s apply --app myapp --version 1.2.3
+
+		# promotes into another directory using a local version stream and chart repository
+		%s apply --dir ./env-repo --app myapp --version 1.2.3 --version-stream-dir ./versionStream --helm-repo-url https://charts.example.com
+	`)
+)
+
+// NewCmdApply creates a command object for the apply command
+func NewCmdApply() (*cobra.Command, *promote.ApplyOptions) {
+	options := &promote.ApplyOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "apply [application]",
+		Short:   "Promotes a version of an application into a local Environment git repository directory",
+		Long:    applyLong,
+		Example: fmt.Sprintf(applyExample, common.BinaryName, common.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			options.Args = args
+			err := options.RunApply()
+			helper.CheckErr(err)
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.OutDir, "dir", "d", ".", "The directory of the Environment git repository to promote into")
+	cmd.Flags().StringVarP(&options.Application, "app", "a", "", "The Application to promote")
+	cmd.Flags().StringVarP(&options.Version, "version", "v", "", "The Version to promote")
+	cmd.Flags().StringVarP(&options.Alias, "alias", "", "", "The optional alias used in the 'requirements.yaml' file")
+	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "The Namespace to promote the Application to. Defaults to the namespace of the rule or 'jx'")
+	cmd.Flags().StringVarP(&options.AppGitURL, "app-git-url", "", "", "The Git URL of the application being promoted. Only required if using file or kpt rules")
+	cmd.Flags().StringVarP(&options.HelmRepositoryURL, "helm-repo-url", "u", "", "The Helm Repository URL of the App")
+	cmd.Flags().StringVarP(&options.VersionStreamDir, "version-stream-dir", "", "", "The directory of the version stream. Defaults to the 'versionStream' directory of the Environment git repository")
+	return cmd, options
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x/jx-helpers/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-promote/pkg/common"
+	"github.com/jenkins-x/jx-promote/pkg/promote"
+	"github.com/spf13/cobra"
+)
+
+var (
+	removeLong = templates.LongDesc(`
+		Creates a Pull Request to remove an application from an Environment
+`)
+
+	removeExample = templates.Examples(`
+		# removes the myapp application from the staging environment
+		%s remove --app myapp --env staging
+	`)
+)
+
+// NewCmdRemove creates a command object for the remove command
+func NewCmdRemove() (*cobra.Command, *promote.Options) {
+	options := &promote.Options{}
+
+	cmd := &cobra.Command{
+		Use:     "remove [application]",
+		Short:   "Creates a Pull Request to remove an application from an Environment",
+		Long:    removeLong,
+		Example: fmt.Sprintf(removeExample, common.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			options.Args = args
+			err := options.RunRemove()
+			helper.CheckErr(err)
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.Application, "app", "a", "", "The Application to remove")
+	cmd.Flags().StringVarP(&options.Environment, "env", "e", "", "The Environment to remove the Application from")
+	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "The Namespace of the development environment")
+	cmd.Flags().StringVarP(&options.AppGitURL, "app-git-url", "", "", "The Git URL of the application being removed. Only required if using file rules which reference it")
+	cmd.Flags().StringVarP(&options.HelmRepositoryURL, "helm-repo-url", "u", "", "The Helm Repository URL of the App")
+	cmd.Flags().StringVarP(&options.EnvironmentsFile, "environments-file", "", "", "The YAML file defining the Environments to use instead of the Environment resources in the cluster")
+	cmd.Flags().StringVarP(&options.DevEnvContext.GitUsername, "git-user", "", "", "Git username used to clone the development environment. If not specified its loaded from the git credentials file")
+	cmd.Flags().StringVarP(&options.DevEnvContext.GitToken, "git-token", "", "", "Git token used to clone the development environment. If not specified its loaded from the git credentials file")
+	cmd.Flags().BoolVarP(&options.BreakGlass, "break-glass", "", false, "Removes the Application even if the Environment is frozen. The override is recorded in the commit message")
+	cmd.Flags().BoolVarP(&options.DryRun, "dry-run", "", false, "Prints the changes and the Pull Request which would be created without pushing them")
+	cmd.Flags().BoolVarP(&options.BatchMode, "batch-mode", "b", false, "Enables batch mode which avoids prompting for user input")
+	return cmd, options
+}
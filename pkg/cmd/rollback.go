@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x/jx-helpers/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-promote/pkg/common"
+	"github.com/jenkins-x/jx-promote/pkg/promote"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rollbackLong = templates.LongDesc(`
+		Creates a Pull Request to restore a previous version of an application in an Environment.
+
+		The previous version is found by reading the version of the application declared in each commit of the history of the Environment git repository
+`)
+
+	rollbackExample = templates.Examples(`
+		# rolls back the myapp application in production to the version before the current one
+		%s rollback --app myapp --env production
+
+		# rolls back the myapp application in production to a specific version
+		%s rollback --app myapp --env production --to 1.2.2
+	`)
+)
+
+// NewCmdRollback creates a command object for the rollback command
+func NewCmdRollback() (*cobra.Command, *promote.RollbackOptions) {
+	options := &promote.RollbackOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "rollback [application]",
+		Short:   "Creates a Pull Request to restore a previous version of an application in an Environment",
+		Long:    rollbackLong,
+		Example: fmt.Sprintf(rollbackExample, common.BinaryName, common.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			options.Args = args
+			err := options.RunRollback()
+			helper.CheckErr(err)
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.Application, "app", "a", "", "The Application to rollback")
+	cmd.Flags().StringVarP(&options.Environment, "env", "e", "", "The Environment to rollback the Application in")
+	cmd.Flags().StringVarP(&options.To, "to", "", "", "The previous version to rollback to. It must be declared in the history of the Environment git repository")
+	cmd.Flags().IntVarP(&options.Steps, "steps", "", 0, "The number of previous versions to rollback. Defaults to 1 if --to is not specified")
+	cmd.Flags().BoolVarP(&options.AutoMerge, "auto-merge", "", false, "Labels the Pull Request so that it is merged automatically")
+	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "The Namespace of the development environment")
+	cmd.Flags().StringVarP(&options.AppGitURL, "app-git-url", "", "", "The Git URL of the application being rolled back. Only required if using file rules which reference it")
+	cmd.Flags().StringVarP(&options.HelmRepositoryURL, "helm-repo-url", "u", "", "The Helm Repository URL of the App")
+	cmd.Flags().StringVarP(&options.EnvironmentsFile, "environments-file", "", "", "The YAML file defining the Environments to use instead of the Environment resources in the cluster")
+	cmd.Flags().StringVarP(&options.Pipeline, "pipeline", "", "", "The Pipeline string in the form 'folderName/repoName/branch' which is used to update the PipelineActivity. If not specified its defaulted from  the '$BUILD_NUMBER' environment variable")
+	cmd.Flags().StringVarP(&options.Build, "build", "", "", "The Build number which is used to update the PipelineActivity. If not specified its defaulted from  the '$BUILD_NUMBER' environment variable")
+	cmd.Flags().StringVarP(&options.DevEnvContext.GitUsername, "git-user", "", "", "Git username used to clone the development environment. If not specified its loaded from the git credentials file")
+	cmd.Flags().StringVarP(&options.DevEnvContext.GitToken, "git-token", "", "", "Git token used to clone the development environment. If not specified its loaded from the git credentials file")
+	cmd.Flags().BoolVarP(&options.DryRun, "dry-run", "", false, "Prints the changes and the Pull Request which would be created without pushing them")
+	cmd.Flags().BoolVarP(&options.BatchMode, "batch-mode", "b", false, "Enables batch mode which avoids prompting for user input")
+	return cmd, options
+}
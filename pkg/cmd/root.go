@@ -36,7 +36,9 @@ func Main() (*cobra.Command, *promote.Options) {
 		Short:   "Promotes a version of an application to an Environment",
 		Long:    promoteLong,
 		Example: fmt.Sprintf(promoteExample, common.BinaryName),
+		Args:    cobra.ArbitraryArgs,
 		Run: func(cmd *cobra.Command, args []string) {
+			options.Args = args
 			err := options.Run()
 			helper.CheckErr(err)
 		},
@@ -51,5 +53,13 @@ func Main() (*cobra.Command, *promote.Options) {
 
 	options.AddOptions(cmd)
 
+	removeCmd, _ := NewCmdRemove()
+	cmd.AddCommand(removeCmd)
+	statusCmd, _ := NewCmdStatus()
+	cmd.AddCommand(statusCmd)
+	rollbackCmd, _ := NewCmdRollback()
+	cmd.AddCommand(rollbackCmd)
+	applyCmd, _ := NewCmdApply()
+	cmd.AddCommand(applyCmd)
 	return cmd, options
 }
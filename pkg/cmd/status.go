@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x/jx-helpers/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-promote/pkg/common"
+	"github.com/jenkins-x/jx-promote/pkg/promote"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statusLong = templates.LongDesc(`
+		Displays the versions of the applications declared in the git repository of each permanent Environment
+`)
+
+	statusExample = templates.Examples(`
+		# displays the versions of all the applications in each environment
+		%s status
+
+		# displays the versions of some applications as YAML
+		%s status --app myapp --app another -o yaml
+	`)
+)
+
+// NewCmdStatus creates a command object for the status command
+func NewCmdStatus() (*cobra.Command, *promote.StatusOptions) {
+	options := &promote.StatusOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "status",
+		Aliases: []string{"versions"},
+		Short:   "Displays the versions of the applications in each permanent Environment",
+		Long:    statusLong,
+		Example: fmt.Sprintf(statusExample, common.BinaryName, common.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			options.Args = args
+			err := options.RunStatus()
+			helper.CheckErr(err)
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&options.Applications, "app", "a", nil, "The Applications to display. If not specified all the applications found in the Environments are displayed")
+	cmd.Flags().StringVarP(&options.Output, "output", "o", "", "The output format. Supported values are json or yaml")
+	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "The Namespace of the development environment")
+	cmd.Flags().StringVarP(&options.HelmRepositoryURL, "helm-repo-url", "u", "", "The Helm Repository URL of the Applications")
+	cmd.Flags().StringVarP(&options.EnvironmentsFile, "environments-file", "", "", "The YAML file defining the Environments to use instead of the Environment resources in the cluster")
+	cmd.Flags().StringVarP(&options.DevEnvContext.GitUsername, "git-user", "", "", "Git username used to clone the development environment. If not specified its loaded from the git credentials file")
+	cmd.Flags().StringVarP(&options.DevEnvContext.GitToken, "git-token", "", "", "Git token used to clone the development environment. If not specified its loaded from the git credentials file")
+	return cmd, options
+}
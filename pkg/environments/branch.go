@@ -0,0 +1,51 @@
+package environments
+
+import (
+	"os"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/gitclient"
+	"github.com/pkg/errors"
+)
+
+// CloneEnvironment clones the git URL into a temporary directory and checks out the branch, such as the
+// Environment.Spec.Source.Ref, if it is not blank. Returns the directory and the branch checked out which defaults
+// to the default branch of the remote repository. A branch which could be parsed as a git option is rejected
+func CloneEnvironment(gitter gitclient.Interface, gitURL string, branch string) (string, string, error) {
+	if strings.HasPrefix(branch, "-") {
+		return "", "", errors.Errorf("invalid branch %s for %s", branch, gitURL)
+	}
+	dir, err := gitclient.CloneToDir(gitter, gitURL, "")
+	if err != nil {
+		return "", "", err
+	}
+	if branch != "" {
+		_, err = gitter.Command(dir, "checkout", branch, "--")
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", "", errors.Wrapf(err, "failed to checkout branch %s of %s", branch, gitURL)
+		}
+		return dir, branch, nil
+	}
+	branch, err = gitclient.Branch(gitter, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", "", errors.Wrapf(err, "failed to find the default branch of %s", gitURL)
+	}
+	branch = strings.TrimSpace(branch)
+	if branch == "HEAD" {
+		// the clone is detached so lets leave the base branch to the git provider
+		branch = ""
+	}
+	return dir, branch, nil
+}
+
+// RemoteDefaultBranch returns the default branch of the origin remote of the git clone in the directory or an empty
+// string if it is not known
+func RemoteDefaultBranch(gitter gitclient.Interface, dir string) (string, error) {
+	text, err := gitter.Command(dir, "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find the default branch of the origin remote in dir %s", dir)
+	}
+	return strings.TrimPrefix(strings.TrimSpace(text), "origin/"), nil
+}
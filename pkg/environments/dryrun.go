@@ -0,0 +1,49 @@
+package environments
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// printDryRun prints the changes made to the environment git clone since the base commit along with the details of
+// the Pull Request which would have been created
+func (o *EnvironmentPullRequestOptions) printDryRun(dir string, gitURL string, baseSha string, labels []string) error {
+	gitter := o.Git()
+	_, err := gitter.Command(dir, "add", "--all")
+	if err != nil {
+		return errors.Wrapf(err, "failed to add the changes in dir %s", dir)
+	}
+	diff, err := gitter.Command(dir, "diff", "--cached", "--no-color", baseSha)
+	if err != nil {
+		return errors.Wrapf(err, "failed to diff the changes in dir %s", dir)
+	}
+
+	branch := o.BranchName
+	if branch == "" {
+		branch = "pr-<generated>"
+	}
+	title, body, message := o.pullRequestText()
+
+	out := o.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, "dry run: not pushing or creating a Pull Request on %s\n\n", gitURL)
+	fmt.Fprintf(out, "title:  %s\n", title)
+	fmt.Fprintf(out, "branch: %s\n", branch)
+	if o.BaseBranch != "" {
+		fmt.Fprintf(out, "base:   %s\n", o.BaseBranch)
+	}
+	fmt.Fprintf(out, "labels: %s\n", strings.Join(labels, ", "))
+	fmt.Fprintf(out, "body:\n%s\n", strings.TrimSpace(body))
+	fmt.Fprintf(out, "commit message:\n%s\n\n", strings.TrimSpace(message))
+	if strings.TrimSpace(diff) == "" {
+		fmt.Fprintln(out, "no changes")
+		return nil
+	}
+	fmt.Fprintln(out, diff)
+	return nil
+}
@@ -0,0 +1,123 @@
+package environments
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ExistingPullRequestCreate creates a new Pull Request leaving any existing promotion Pull Requests open
+	ExistingPullRequestCreate = "Create"
+
+	// ExistingPullRequestReuse force pushes the changes onto the newest existing promotion Pull Request and closes
+	// any others
+	ExistingPullRequestReuse = "Reuse"
+
+	// ExistingPullRequestSupersede creates a new Pull Request and closes the existing promotion Pull Requests
+	ExistingPullRequestSupersede = "Supersede"
+)
+
+// FindExistingPullRequests returns the open Pull Requests against the base branch whose head branch matches the
+// regular expression with the newest first
+func FindExistingPullRequests(prs []*scm.PullRequest, branchRegex *regexp.Regexp, base string) []*scm.PullRequest {
+	var answer []*scm.PullRequest
+	for _, pr := range prs {
+		if pr == nil || pr.Closed || pr.Merged {
+			continue
+		}
+		if base != "" && pr.Base.Ref != "" && pr.Base.Ref != base {
+			continue
+		}
+		if branchRegex.MatchString(pullRequestBranch(pr)) {
+			answer = append(answer, pr)
+		}
+	}
+	sort.SliceStable(answer, func(i, j int) bool {
+		return answer[i].Number > answer[j].Number
+	})
+	return answer
+}
+
+// pullRequestBranch returns the head branch of the Pull Request
+func pullRequestBranch(pr *scm.PullRequest) string {
+	if pr.Head.Ref != "" {
+		return pr.Head.Ref
+	}
+	return pr.Source
+}
+
+// FindBranchPullRequest returns the newest open Pull Request against the base branch whose head is the branch or nil
+// if there is none
+func FindBranchPullRequest(prs []*scm.PullRequest, branch string, base string) *scm.PullRequest {
+	if branch == "" {
+		return nil
+	}
+	answer := FindExistingPullRequests(prs, regexp.MustCompile("^"+regexp.QuoteMeta(branch)+"$"), base)
+	if len(answer) == 0 {
+		return nil
+	}
+	return answer[0]
+}
+
+// findsExistingPullRequests returns true if the existing promotion Pull Requests are being reused or superseded
+func (o *EnvironmentPullRequestOptions) findsExistingPullRequests() bool {
+	if o.ExistingBranches == nil {
+		return false
+	}
+	switch o.ExistingAction {
+	case ExistingPullRequestReuse, ExistingPullRequestSupersede:
+		return true
+	default:
+		return false
+	}
+}
+
+// listOpenPullRequests lists the open Pull Requests in the repository
+func listOpenPullRequests(ctx context.Context, scmClient *scm.Client, repoFullName string) ([]*scm.PullRequest, error) {
+	var prs []*scm.PullRequest
+	opts := scm.PullRequestListOptions{
+		Open: true,
+		Size: 100,
+	}
+	for {
+		page, res, err := scmClient.PullRequests.List(ctx, repoFullName, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list the open Pull Requests of repository %s", repoFullName)
+		}
+		prs = append(prs, page...)
+		if res == nil || res.Page.Next == 0 || res.Page.Next == opts.Page {
+			break
+		}
+		opts.Page = res.Page.Next
+	}
+	return prs, nil
+}
+
+// supersedePullRequests comments on and closes the existing Pull Requests other than the given Pull Request
+func (o *EnvironmentPullRequestOptions) supersedePullRequests(ctx context.Context, scmClient *scm.Client, repoFullName string, existing []*scm.PullRequest, pr *scm.PullRequest) {
+	for _, old := range existing {
+		if old.Number == pr.Number {
+			continue
+		}
+		comment := &scm.CommentInput{
+			Body: fmt.Sprintf("Superseded by #%d", pr.Number),
+		}
+		_, _, err := scmClient.PullRequests.CreateComment(ctx, repoFullName, old.Number, comment)
+		if err != nil {
+			log.Logger().Warnf("failed to comment on the superseded Pull Request %s: %s", old.Link, err.Error())
+		}
+		_, err = scmClient.PullRequests.Close(ctx, repoFullName, old.Number)
+		if err != nil {
+			log.Logger().Warnf("failed to close the superseded Pull Request %s: %s", old.Link, err.Error())
+			continue
+		}
+		log.Logger().Infof("closed Pull Request %s as it is superseded by %s", termcolor.ColorInfo(old.Link), termcolor.ColorInfo(pr.Link))
+	}
+}
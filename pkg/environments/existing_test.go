@@ -0,0 +1,73 @@
+package environments_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-promote/pkg/environments"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindExistingPullRequests(t *testing.T) {
+	newPR := func(number int, head string, base string) *scm.PullRequest {
+		return &scm.PullRequest{
+			Number: number,
+			Head:   scm.PullRequestBranch{Ref: head},
+			Base:   scm.PullRequestBranch{Ref: base},
+		}
+	}
+	closed := newPR(1, "promote/production/myapp/1.0.0", "main")
+	closed.Closed = true
+	prs := []*scm.PullRequest{
+		closed,
+		newPR(2, "promote/production/myapp/1.1.0", "main"),
+		newPR(3, "promote/production/myapp-ui/1.1.0", "main"),
+		newPR(4, "promote/staging/myapp/1.1.0", "main"),
+		newPR(5, "promote/production/myapp/1.2.0", "production"),
+		newPR(6, "promote/production/myapp/1.2.0", "main"),
+		newPR(7, "pr-c5a2b6e4", "main"),
+	}
+	re := regexp.MustCompile(`^promote/production/myapp/[^/\s]+$`)
+
+	existing := environments.FindExistingPullRequests(prs, re, "main")
+	var numbers []int
+	for _, pr := range existing {
+		numbers = append(numbers, pr.Number)
+	}
+	assert.Equal(t, []int{6, 2}, numbers, "existing Pull Requests on main")
+
+	existing = environments.FindExistingPullRequests(prs, re, "production")
+	if assert.Len(t, existing, 1, "existing Pull Requests on production") {
+		assert.Equal(t, 5, existing[0].Number, "existing Pull Request on production")
+	}
+}
+
+func TestFindBranchPullRequest(t *testing.T) {
+	branch := "promote/production/myapp"
+	first := &scm.PullRequest{
+		Number: 1,
+		Head:   scm.PullRequestBranch{Ref: branch},
+		Base:   scm.PullRequestBranch{Ref: "main"},
+	}
+	other := &scm.PullRequest{
+		Number: 2,
+		Head:   scm.PullRequestBranch{Ref: "promote/production/myapp-ui"},
+		Base:   scm.PullRequestBranch{Ref: "main"},
+	}
+
+	// the first promotion creates the Pull Request of the branch
+	assert.Nil(t, environments.FindBranchPullRequest([]*scm.PullRequest{other}, branch, "main"), "first promotion")
+
+	// promoting again while it is open should update it rather than create another Pull Request for the branch
+	pr := environments.FindBranchPullRequest([]*scm.PullRequest{other, first}, branch, "main")
+	if assert.NotNil(t, pr, "second promotion") {
+		assert.Equal(t, 1, pr.Number, "second promotion should update the open Pull Request")
+	}
+
+	assert.Nil(t, environments.FindBranchPullRequest([]*scm.PullRequest{first}, branch, "production"), "other base branch")
+	assert.Nil(t, environments.FindBranchPullRequest([]*scm.PullRequest{first}, "", "main"), "generated branch")
+
+	first.Merged = true
+	assert.Nil(t, environments.FindBranchPullRequest([]*scm.PullRequest{other, first}, branch, "main"), "promotion after the merge")
+}
@@ -53,12 +53,13 @@ func (o *EnvironmentPullRequestOptions) Create(env *jenkinsv1.Environment, prDir
 	}
 
 	gitURL := env.Spec.Source.URL
-	dir, err := gitclient.CloneToDir(o.Gitter, gitURL, "")
+	dir, baseBranch, err := CloneEnvironment(o.Git(), gitURL, env.Spec.Source.Ref)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to clone environment %s URL %s", env.Spec.Label, gitURL)
 	}
 
 	o.OutDir = dir
+	o.BaseBranch = baseBranch
 	log.Logger().Infof("cloned %s to %s", termcolor.ColorInfo(gitURL), termcolor.ColorInfo(dir))
 
 	// TODO fork if needed?
@@ -95,6 +96,15 @@ func (o *EnvironmentPullRequestOptions) Create(env *jenkinsv1.Environment, prDir
 		}
 	}
 
+	if o.DryRun {
+		for _, l := range pullRequestDetails.Labels {
+			if l != nil {
+				labels = append(labels, l.Name)
+			}
+		}
+		return nil, o.printDryRun(dir, gitURL, currentSha, labels)
+	}
+
 	latestSha, err := gitclient.GetLatestCommitSha(o.Gitter, dir)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not get current latest commit sha")
@@ -520,11 +530,6 @@ func CreateNestedRequirementDir(dir string, requirementName string, requirementD
 	} else {
 		return errors.Wrapf(err, "stat Chart.yaml from %s", requirementDir)
 	}
-	// Need to copy over any referenced files, and their schemas
-	rootValues, err := helm.LoadValuesFile(rootValuesFileName)
-	if err != nil {
-		return err
-	}
 	schemas := make(map[string][]string)
 	possibles := make(map[string]string)
 	if _, err := os.Stat(requirementDir); err == nil {
@@ -590,37 +595,6 @@ func CreateNestedRequirementDir(dir string, requirementName string, requirementD
 	err = ioutil.WriteFile(readmeOutPath, []byte(readme), 0755)
 	if err != nil {
 		return errors.Wrapf(err, "write README.md to %s", appDir)
-
-		if verbose {
-			log.Logger().Infof("Writing README.md to %s", readmeOutPath)
-		}
-		externalFileHandler := func(path string, element map[string]interface{}, key string) error {
-			fileName, _ := filepath.Split(path)
-			err := files.CopyFile(path, filepath.Join(appDir, fileName))
-			if err != nil {
-				return errors.Wrapf(err, "copy %s to %s", path, appDir)
-			}
-			// key for schema is the filename without the extension
-			schemaKey := strings.TrimSuffix(fileName, filepath.Ext(fileName))
-			if schemaPaths, ok := schemas[schemaKey]; ok {
-				for _, schemaPath := range schemaPaths {
-					fileName, _ := filepath.Split(schemaPath)
-					schemaOutPath := filepath.Join(appDir, fileName)
-					err := files.CopyFile(schemaPath, schemaOutPath)
-					if err != nil {
-						return errors.Wrapf(err, "copy %s to %s", schemaPath, appDir)
-					}
-					if verbose {
-						log.Logger().Infof("Writing %s to %s", fileName, schemaOutPath)
-					}
-				}
-			}
-			return nil
-		}
-		err = helm.HandleExternalFileRefs(rootValues, possibles, "", externalFileHandler)
-		if err != nil {
-			return err
-		}
 	}
 	return nil
 }
@@ -0,0 +1,180 @@
+package environments_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-promote/pkg/environments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateDryRun(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	repoDir := filepath.Join(tmpDir, "environment")
+	require.NoError(t, os.MkdirAll(repoDir, files.DefaultDirWritePermissions))
+	writeFile(t, filepath.Join(repoDir, "versions.txt"), "myapp 1.2.3\n")
+	runGit(t, repoDir, "init", "--quiet")
+	runGit(t, repoDir, "add", "-A")
+	runGit(t, repoDir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "initial import")
+
+	out := &bytes.Buffer{}
+	o := &environments.EnvironmentPullRequestOptions{
+		CommandRunner: cmdrunner.QuietCommandRunner,
+		CommitTitle:   "chore: myapp to 1.2.4",
+		CommitMessage: "chore: Promote myapp to version 1.2.4",
+		Labels:        []string{"promote"},
+		DryRun:        true,
+		Out:           out,
+	}
+	o.Function = func() error {
+		writeFile(t, filepath.Join(o.OutDir, "versions.txt"), "myapp 1.2.4\n")
+		writeFile(t, filepath.Join(o.OutDir, "new.txt"), "another 1.0.0\n")
+		return nil
+	}
+	env := &v1.Environment{
+		Spec: v1.EnvironmentSpec{
+			Label: "Staging",
+			Source: v1.EnvironmentRepository{
+				URL: repoDir,
+			},
+		},
+	}
+
+	pr, err := o.Create(env, "", &scm.PullRequest{}, "", true)
+	require.NoError(t, err, "failed to create the dry run")
+	assert.Nil(t, pr, "no Pull Request should be created")
+
+	text := out.String()
+	t.Logf("dry run output:\n%s", text)
+	assert.Contains(t, text, "title:  chore: myapp to 1.2.4")
+	assert.Contains(t, text, "labels: promote, updatebot")
+	assert.Contains(t, text, "commit message:\nchore: Promote myapp to version 1.2.4\n")
+	assert.Contains(t, text, "-myapp 1.2.3\n+myapp 1.2.4")
+	assert.Contains(t, text, "+another 1.0.0")
+
+	branches, err := cmdrunner.QuietCommandRunner(&cmdrunner.Command{
+		Name: "git",
+		Args: []string{"branch", "--list"},
+		Dir:  repoDir,
+	})
+	require.NoError(t, err, "failed to list branches")
+	assert.NotContains(t, branches, "pr-", "no branch should be pushed")
+}
+
+func TestCreateDryRunEnvironmentBranch(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	// lets use a branch per environment in a repository whose default branch is main
+	repoDir := filepath.Join(tmpDir, "environments")
+	require.NoError(t, os.MkdirAll(repoDir, files.DefaultDirWritePermissions))
+	runGit(t, repoDir, "init", "--quiet")
+	runGit(t, repoDir, "symbolic-ref", "HEAD", "refs/heads/main")
+	writeFile(t, filepath.Join(repoDir, "versions.txt"), "myapp 1.0.0\n")
+	runGit(t, repoDir, "add", "-A")
+	runGit(t, repoDir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "initial import")
+	runGit(t, repoDir, "checkout", "--quiet", "-b", "staging")
+	writeFile(t, filepath.Join(repoDir, "versions.txt"), "myapp 1.2.3\n")
+	runGit(t, repoDir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-a", "-m", "staging")
+	runGit(t, repoDir, "checkout", "--quiet", "main")
+
+	for _, ref := range []string{"", "staging"} {
+		out := &bytes.Buffer{}
+		o := &environments.EnvironmentPullRequestOptions{
+			CommandRunner: cmdrunner.QuietCommandRunner,
+			CommitTitle:   "chore: myapp to 1.2.4",
+			DryRun:        true,
+			Out:           out,
+		}
+		o.Function = func() error {
+			writeFile(t, filepath.Join(o.OutDir, "versions.txt"), "myapp 1.2.4\n")
+			return nil
+		}
+		env := &v1.Environment{
+			Spec: v1.EnvironmentSpec{
+				Label: "Staging",
+				Source: v1.EnvironmentRepository{
+					URL: repoDir,
+					Ref: ref,
+				},
+			},
+		}
+
+		_, err = o.Create(env, "", &scm.PullRequest{}, "", true)
+		require.NoError(t, err, "failed to create the dry run for ref '%s'", ref)
+
+		text := out.String()
+		t.Logf("dry run output for ref '%s':\n%s", ref, text)
+		if ref == "" {
+			assert.Equal(t, "main", o.BaseBranch, "base branch should default to the default branch")
+			assert.Contains(t, text, "base:   main\n")
+			assert.Contains(t, text, "-myapp 1.0.0\n+myapp 1.2.4")
+		} else {
+			assert.Equal(t, ref, o.BaseBranch, "base branch should be the environment ref")
+			assert.Contains(t, text, "base:   staging\n")
+			assert.Contains(t, text, "-myapp 1.2.3\n+myapp 1.2.4")
+		}
+	}
+}
+
+func TestCreateRejectsOptionRef(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	repoDir := filepath.Join(tmpDir, "environment")
+	require.NoError(t, os.MkdirAll(repoDir, files.DefaultDirWritePermissions))
+	writeFile(t, filepath.Join(repoDir, "versions.txt"), "myapp 1.2.3\n")
+	runGit(t, repoDir, "init", "--quiet")
+	runGit(t, repoDir, "add", "-A")
+	runGit(t, repoDir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "initial import")
+
+	o := &environments.EnvironmentPullRequestOptions{
+		CommandRunner: cmdrunner.QuietCommandRunner,
+		CommitTitle:   "chore: myapp to 1.2.4",
+		DryRun:        true,
+		Out:           &bytes.Buffer{},
+	}
+	o.Function = func() error {
+		return nil
+	}
+	env := &v1.Environment{
+		Spec: v1.EnvironmentSpec{
+			Label: "Staging",
+			Source: v1.EnvironmentRepository{
+				URL: repoDir,
+				Ref: "--orphan=pwned",
+			},
+		},
+	}
+
+	_, err = o.Create(env, "", &scm.PullRequest{}, "", true)
+	require.Error(t, err, "expected an error for a ref which is a git option")
+	assert.Contains(t, err.Error(), "invalid branch --orphan=pwned")
+}
+
+func writeFile(t *testing.T, file string, text string) {
+	err := ioutil.WriteFile(file, []byte(text), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save file %s", file)
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	_, err := cmdrunner.QuietCommandRunner(&cmdrunner.Command{
+		Name: "git",
+		Args: args,
+		Dir:  dir,
+	})
+	require.NoError(t, err, "failed to run git %v in %s", args, dir)
+}
@@ -39,31 +39,6 @@ func (o *EnvironmentPullRequestOptions) CreatePullRequest(dir string, gitURL str
 		return nil, nil
 	}
 
-	if o.BranchName == "" {
-		o.BranchName, err = gitclient.CreateBranch(gitter, dir)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to create git branch in %s", dir)
-		}
-	}
-
-	commitTitle := strings.TrimSpace(o.CommitTitle)
-	commitBody := o.commitBody.String()
-
-	commitMessageStart := o.CommitMessage
-	if commitMessageStart == "" {
-		commitMessageStart = commitTitle
-	}
-	commitMessage := fmt.Sprintf("%s\n\n%s", commitMessageStart, commitBody)
-	_, err = gitclient.AddAndCommitFiles(gitter, dir, commitMessage)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to commit changes in dir %s", dir)
-	}
-
-	err = gitclient.ForcePushBranch(gitter, dir, o.BranchName, o.BranchName)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to push to branch %s from dir %s", o.BranchName, dir)
-	}
-
 	gitInfo, err := giturl.ParseGitURL(gitURL)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to parse git URL")
@@ -79,38 +54,129 @@ func (o *EnvironmentPullRequestOptions) CreatePullRequest(dir string, gitURL str
 	o.ScmClient = scmClient
 	ctx := context.Background()
 
-	headPrefix := ""
-	if o.Fork {
-		user, _, err := scmClient.Users.Find(ctx)
+	repoFullName := scm.Join(gitInfo.Organisation, gitInfo.Name)
+	base := o.BaseBranch
+	if base == "" {
+		// lets default to the default branch of the repository
+		repo, _, err := scmClient.Repositories.Find(ctx, repoFullName)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to find current SCM user")
+			log.Logger().Warnf("failed to find the default branch of repository %s: %s", repoFullName, err.Error())
+		} else {
+			base = repo.Branch
+		}
+		if base == "" {
+			base = "master"
 		}
+	}
 
-		username := user.Login
-		headPrefix = username + ":"
+	var open, existing []*scm.PullRequest
+	if o.BranchName != "" || o.findsExistingPullRequests() {
+		open, err = listOpenPullRequests(ctx, scmClient, repoFullName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o.findsExistingPullRequests() {
+		existing = FindExistingPullRequests(open, o.ExistingBranches, base)
+	}
+	// the branch is force pushed so lets update any open Pull Request of the branch, such as when the branch template
+	// has no version, rather than failing to create another Pull Request for the same branch
+	reuse := FindBranchPullRequest(open, o.BranchName, base)
+	if reuse == nil && o.ExistingAction == ExistingPullRequestReuse && len(existing) > 0 {
+		reuse = existing[0]
+		o.BranchName = pullRequestBranch(reuse)
 	}
 
-	head := headPrefix + o.BranchName
+	if o.BranchName == "" {
+		o.BranchName, err = gitclient.CreateBranch(gitter, dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create git branch in %s", dir)
+		}
+	} else {
+		_, err = gitter.Command(dir, "checkout", "-B", o.BranchName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to checkout git branch %s in %s", o.BranchName, dir)
+		}
+	}
 
-	pri := &scm.PullRequestInput{
-		Title: commitTitle,
-		Head:  head,
-		Base:  "master",
-		Body:  commitBody,
+	commitTitle, commitBody, commitMessage := o.pullRequestText()
+	_, err = gitclient.AddAndCommitFiles(gitter, dir, commitMessage)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to commit changes in dir %s", dir)
 	}
-	repoFullName := scm.Join(gitInfo.Organisation, gitInfo.Name)
-	pr, _, err := scmClient.PullRequests.Create(ctx, repoFullName, pri)
+
+	err = gitclient.ForcePushBranch(gitter, dir, o.BranchName, o.BranchName)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create PullRequest on %s", gitURL)
+		return nil, errors.Wrapf(err, "failed to push to branch %s from dir %s", o.BranchName, dir)
+	}
+
+	var pr *scm.PullRequest
+	if reuse != nil {
+		pri := &scm.PullRequestInput{
+			Title: commitTitle,
+			Body:  commitBody,
+		}
+		pr, _, err = scmClient.PullRequests.Update(ctx, repoFullName, reuse.Number, pri)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to update PullRequest %s", reuse.Link)
+		}
+	} else {
+		headPrefix := ""
+		if o.Fork {
+			user, _, err := scmClient.Users.Find(ctx)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to find current SCM user")
+			}
+
+			username := user.Login
+			headPrefix = username + ":"
+		}
+
+		head := headPrefix + o.BranchName
+
+		pri := &scm.PullRequestInput{
+			Title: commitTitle,
+			Head:  head,
+			Base:  base,
+			Body:  commitBody,
+		}
+		pr, _, err = scmClient.PullRequests.Create(ctx, repoFullName, pri)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create PullRequest on %s", gitURL)
+		}
 	}
 
 	// the URL should not really end in .diff - fix in go-scm
 	link := strings.TrimSuffix(pr.Link, ".diff")
 	pr.Link = link
-	log.Logger().Infof("created Pull Request %s from dir %s", termcolor.ColorInfo(link), termcolor.ColorInfo(dir))
+	if reuse != nil {
+		log.Logger().Infof("updated Pull Request %s from dir %s", termcolor.ColorInfo(link), termcolor.ColorInfo(dir))
+	} else {
+		log.Logger().Infof("created Pull Request %s from dir %s", termcolor.ColorInfo(link), termcolor.ColorInfo(dir))
+	}
+	o.supersedePullRequests(ctx, scmClient, repoFullName, existing, pr)
 	return pr, nil
 }
 
+// pullRequestText returns the title and body of the Pull Request along with the commit message. The body of the
+// Pull Request defaults to the body of the commit
+func (o *EnvironmentPullRequestOptions) pullRequestText() (string, string, string) {
+	commitTitle := strings.TrimSpace(o.CommitTitle)
+	commitBody := o.commitBody.String()
+
+	commitMessageStart := o.CommitMessage
+	if commitMessageStart == "" {
+		commitMessageStart = commitTitle
+	}
+	commitMessage := fmt.Sprintf("%s\n\n%s", commitMessageStart, commitBody)
+
+	prBody := commitBody
+	if o.PullRequestBody != "" {
+		prBody = o.PullRequestBody
+	}
+	return commitTitle, prBody, commitMessage
+}
+
 // CreateScmClient creates a new scm client
 func (o *EnvironmentPullRequestOptions) CreateScmClient(gitServer, owner, gitKind string) (*scm.Client, string, error) {
 	o.ScmClientFactory.GitServerURL = gitServer
@@ -1,6 +1,8 @@
 package environments
 
 import (
+	"io"
+	"regexp"
 	"strings"
 
 	"github.com/jenkins-x/go-scm/scm"
@@ -46,12 +48,18 @@ type EnvironmentPullRequestOptions struct {
 	ModifyKptFn       ModifyKptFn
 	Labels            []string
 	BranchName        string
+	BaseBranch        string
 	PullRequestNumber int
 	CommitTitle       string
 	CommitMessage     string
+	PullRequestBody   string
+	ExistingAction    string
+	ExistingBranches  *regexp.Regexp
 	ScmClient         *scm.Client
 	BatchMode         bool
 	UseGitHubOAuth    bool
 	Fork              bool
+	DryRun            bool
+	Out               io.Writer
 	commitBody        strings.Builder
 }
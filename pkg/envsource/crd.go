@@ -0,0 +1,32 @@
+package envsource
+
+import (
+	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-api/pkg/client/clientset/versioned"
+	"github.com/jenkins-x/jx-helpers/pkg/kube/jxenv"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// CRDSource loads the Environment custom resources from the team namespace of the cluster
+type CRDSource struct {
+	KubeClient kubernetes.Interface
+	JXClient   versioned.Interface
+	Namespace  string
+}
+
+var _ Source = &CRDSource{}
+
+// GetEnvironments returns the Environments in the team namespace
+func (s *CRDSource) GetEnvironments() (map[string]*v1.Environment, []string, error) {
+	team, _, err := jxenv.GetDevNamespace(s.KubeClient, s.Namespace)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to find the team namespace of %s", s.Namespace)
+	}
+	return jxenv.GetOrderedEnvironments(s.JXClient, team)
+}
+
+// GetDevEnvironment returns the development Environment in the namespace
+func (s *CRDSource) GetDevEnvironment() (*v1.Environment, error) {
+	return jxenv.GetDevEnvironment(s.JXClient, s.Namespace)
+}
@@ -0,0 +1,175 @@
+package envsource
+
+import (
+	"io/ioutil"
+	"strings"
+
+	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-api/pkg/config"
+	"github.com/jenkins-x/jx-helpers/pkg/kube/jxenv"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// EnvironmentsConfig the Environments defined in a YAML file so that promotion can be used without a cluster
+type EnvironmentsConfig struct {
+	// ChartRepository the chart repository the applications are released to
+	ChartRepository string `json:"chartRepository,omitempty"`
+
+	// Environments the Environments
+	Environments []EnvironmentConfig `json:"environments"`
+}
+
+// EnvironmentConfig the configuration of an Environment in a YAML file
+type EnvironmentConfig struct {
+	// Name the name of the Environment
+	Name string `json:"name"`
+
+	// Label the label of the Environment. Defaults to the name
+	Label string `json:"label,omitempty"`
+
+	// Namespace the namespace of the Environment. Defaults to 'jx-' and the name
+	Namespace string `json:"namespace,omitempty"`
+
+	// GitURL the URL of the git repository of the Environment
+	GitURL string `json:"gitURL,omitempty"`
+
+	// Ref the branch of the git repository promoted to such as when using a branch per Environment. Defaults to the
+	// default branch of the git repository
+	Ref string `json:"ref,omitempty"`
+
+	// PromotionStrategy the promotion strategy of the Environment. Defaults to Manual
+	PromotionStrategy v1.PromotionStrategyType `json:"promotionStrategy,omitempty"`
+
+	// Order the order the Environment is promoted to
+	Order int32 `json:"order,omitempty"`
+
+	// RemoteCluster whether the Environment is in a separate cluster to the development Environment
+	RemoteCluster bool `json:"remoteCluster,omitempty"`
+
+	// Kind the kind of the Environment. Defaults to Permanent
+	Kind v1.EnvironmentKindType `json:"kind,omitempty"`
+}
+
+// FileSource loads the Environments from a YAML file
+type FileSource struct {
+	Path string
+}
+
+var _ Source = &FileSource{}
+
+// GetEnvironments returns the Environments in the file
+func (s *FileSource) GetEnvironments() (map[string]*v1.Environment, []string, error) {
+	cfg, err := LoadEnvironmentsConfig(s.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var envs []v1.Environment
+	for i := range cfg.Environments {
+		env, err := cfg.Environments[i].ToEnvironment(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		envs = append(envs, *env)
+	}
+	jxenv.SortEnvironments(envs)
+
+	m := map[string]*v1.Environment{}
+	var names []string
+	for i := range envs {
+		env := &envs[i]
+		m[env.Name] = env
+		names = append(names, env.Name)
+	}
+	return m, names, nil
+}
+
+// GetDevEnvironment returns the development Environment in the file or nil if there is none
+func (s *FileSource) GetDevEnvironment() (*v1.Environment, error) {
+	cfg, err := LoadEnvironmentsConfig(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	for i := range cfg.Environments {
+		env, err := cfg.Environments[i].ToEnvironment(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if env.Spec.Kind == v1.EnvironmentKindTypeDevelopment {
+			return env, nil
+		}
+	}
+	return nil, nil
+}
+
+// LoadEnvironmentsConfig loads the Environments from the YAML file
+func LoadEnvironmentsConfig(path string) (*EnvironmentsConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load file %s", path)
+	}
+	cfg := &EnvironmentsConfig{}
+	err = yaml.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal YAML file %s", path)
+	}
+	names := map[string]bool{}
+	for _, env := range cfg.Environments {
+		if env.Name == "" {
+			return nil, errors.Errorf("missing environment name in file %s", path)
+		}
+		if names[env.Name] {
+			return nil, errors.Errorf("duplicate environment %s in file %s", env.Name, path)
+		}
+		names[env.Name] = true
+	}
+	return cfg, nil
+}
+
+// ToEnvironment converts the configuration into an Environment defaulting any missing values
+func (c *EnvironmentConfig) ToEnvironment(cfg *EnvironmentsConfig) (*v1.Environment, error) {
+	env := &v1.Environment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: c.Name,
+		},
+		Spec: v1.EnvironmentSpec{
+			Label:             c.Label,
+			Namespace:         c.Namespace,
+			PromotionStrategy: c.PromotionStrategy,
+			Order:             c.Order,
+			Kind:              c.Kind,
+			RemoteCluster:     c.RemoteCluster,
+			Source: v1.EnvironmentRepository{
+				Kind: v1.EnvironmentRepositoryTypeGit,
+				URL:  c.GitURL,
+				Ref:  c.Ref,
+			},
+		},
+	}
+	if env.Spec.Label == "" {
+		env.Spec.Label = strings.Title(c.Name)
+	}
+	if env.Spec.Namespace == "" {
+		env.Spec.Namespace = "jx-" + c.Name
+	}
+	if env.Spec.PromotionStrategy == "" {
+		env.Spec.PromotionStrategy = v1.PromotionStrategyTypeManual
+	}
+	if env.Spec.Kind == "" {
+		env.Spec.Kind = v1.EnvironmentKindTypePermanent
+	}
+	if env.Spec.Kind == v1.EnvironmentKindTypeDevelopment {
+		// lets add the requirements the EnvironmentContext needs
+		requirements := config.NewRequirementsConfig()
+		requirements.Cluster.Namespace = env.Spec.Namespace
+		requirements.Cluster.ChartRepository = cfg.ChartRepository
+		data, err := yaml.Marshal(requirements)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to marshal the requirements of environment %s", c.Name)
+		}
+		env.Spec.TeamSettings.BootRequirements = string(data)
+		env.Spec.TeamSettings.AppsRepository = cfg.ChartRepository
+	}
+	return env, nil
+}
@@ -0,0 +1,50 @@
+package envsource_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-promote/pkg/envctx"
+	"github.com/jenkins-x/jx-promote/pkg/envsource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSource(t *testing.T) {
+	source := &envsource.FileSource{Path: filepath.Join("test_data", "environments.yaml")}
+
+	m, names, err := source.GetEnvironments()
+	require.NoError(t, err, "failed to load the environments")
+	assert.Equal(t, []string{"dev", "staging", "production"}, names, "environment names in order")
+
+	staging := m["staging"]
+	require.NotNil(t, staging, "no staging environment")
+	assert.Equal(t, "Staging", staging.Spec.Label, "staging label")
+	assert.Equal(t, "jx-staging", staging.Spec.Namespace, "staging namespace")
+	assert.Equal(t, v1.PromotionStrategyTypeAutomatic, staging.Spec.PromotionStrategy, "staging promotion strategy")
+	assert.Equal(t, v1.EnvironmentKindTypePermanent, staging.Spec.Kind, "staging kind")
+	assert.Equal(t, "", staging.Spec.Source.URL, "staging git URL")
+	assert.False(t, staging.Spec.RemoteCluster, "staging remote cluster")
+
+	production := m["production"]
+	require.NotNil(t, production, "no production environment")
+	assert.Equal(t, "Production", production.Spec.Label, "production label")
+	assert.Equal(t, "jx-production", production.Spec.Namespace, "production namespace")
+	assert.Equal(t, v1.PromotionStrategyTypeManual, production.Spec.PromotionStrategy, "production promotion strategy")
+	assert.Equal(t, int32(200), production.Spec.Order, "production order")
+	assert.Equal(t, "https://github.com/myorg/environment-mycluster-production.git", production.Spec.Source.URL, "production git URL")
+	assert.Equal(t, "production", production.Spec.Source.Ref, "production git ref")
+	assert.True(t, production.Spec.RemoteCluster, "production remote cluster")
+
+	devEnv, err := source.GetDevEnvironment()
+	require.NoError(t, err, "failed to load the dev environment")
+	require.NotNil(t, devEnv, "no dev environment")
+	assert.Equal(t, "dev", devEnv.Name, "dev environment name")
+
+	requirements, err := envctx.GetRequirementsConfigFromTeamSettings(&devEnv.Spec.TeamSettings)
+	require.NoError(t, err, "failed to load the requirements of the dev environment")
+	require.NotNil(t, requirements, "no requirements in the dev environment")
+	assert.Equal(t, "jx", requirements.Cluster.Namespace, "requirements namespace")
+	assert.Equal(t, "https://charts.example.com", requirements.Cluster.ChartRepository, "requirements chart repository")
+}
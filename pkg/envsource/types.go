@@ -0,0 +1,14 @@
+package envsource
+
+import (
+	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
+)
+
+// Source loads the Environments applications are promoted to
+type Source interface {
+	// GetEnvironments returns the Environments indexed by name along with the names in promotion order
+	GetEnvironments() (map[string]*v1.Environment, []string, error)
+
+	// GetDevEnvironment returns the development Environment or nil if there is none
+	GetDevEnvironment() (*v1.Environment, error)
+}
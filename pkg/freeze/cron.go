@@ -0,0 +1,109 @@
+package freeze
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Schedule a parsed cron expression of the form `minute hour day-of-month month day-of-week`
+type Schedule struct {
+	minutes     map[int]bool
+	hours       map[int]bool
+	daysOfMonth map[int]bool
+	months      map[int]bool
+	daysOfWeek  map[int]bool
+
+	// anyDayOfMonth and anyDayOfWeek are used to match either day field as cron does when both are restricted
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+// ParseCron parses the cron expression. Each field supports `*`, values, ranges such as `1-5`, lists such as `1,3`
+// and steps such as `*/15` or `0-30/10`. Sunday is either 0 or 7 in the day-of-week field
+func ParseCron(expression string) (*Schedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("cron expression '%s' should have 5 fields: minute hour day-of-month month day-of-week", expression)
+	}
+	s := &Schedule{
+		anyDayOfMonth: fields[2] == "*",
+		anyDayOfWeek:  fields[4] == "*",
+	}
+	var err error
+	if s.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, errors.Wrapf(err, "invalid minute in cron expression '%s'", expression)
+	}
+	if s.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, errors.Wrapf(err, "invalid hour in cron expression '%s'", expression)
+	}
+	if s.daysOfMonth, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, errors.Wrapf(err, "invalid day-of-month in cron expression '%s'", expression)
+	}
+	if s.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, errors.Wrapf(err, "invalid month in cron expression '%s'", expression)
+	}
+	if s.daysOfWeek, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, errors.Wrapf(err, "invalid day-of-week in cron expression '%s'", expression)
+	}
+	if s.daysOfWeek[7] {
+		s.daysOfWeek[0] = true
+	}
+	return s, nil
+}
+
+// Matches returns true if the minute of the time matches the schedule
+func (s *Schedule) Matches(t time.Time) bool {
+	if !s.minutes[t.Minute()] || !s.hours[t.Hour()] || !s.months[int(t.Month())] {
+		return false
+	}
+	dom := s.daysOfMonth[t.Day()]
+	dow := s.daysOfWeek[int(t.Weekday())]
+	if !s.anyDayOfMonth && !s.anyDayOfWeek {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+func parseCronField(field string, min int, max int) (map[int]bool, error) {
+	answer := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		idx := strings.Index(part, "/")
+		if idx >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step <= 0 {
+				return nil, errors.Errorf("invalid step in '%s'", part)
+			}
+			part = part[:idx]
+		}
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, errors.Errorf("invalid value '%s'", bounds[0])
+			}
+			to = from
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, errors.Errorf("invalid value '%s'", bounds[1])
+				}
+			} else if idx >= 0 {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, errors.Errorf("'%s' is not within %d-%d", field, min, max)
+		}
+		for i := from; i <= to; i += step {
+			answer[i] = true
+		}
+	}
+	return answer, nil
+}
@@ -0,0 +1,160 @@
+package freeze
+
+import (
+	"time"
+
+	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// AnnotationFreezeWindows the annotation on an Environment containing the YAML or JSON list of freeze windows
+	AnnotationFreezeWindows = "promote.jenkins-x.io/freeze-windows"
+
+	// ActionFail fails the promotion during a freeze
+	ActionFail = "Fail"
+
+	// ActionSkip skips the promotion during a freeze
+	ActionSkip = "Skip"
+
+	// ActionQueue waits for the freeze to end before promoting
+	ActionQueue = "Queue"
+
+	// maxRecurringDuration the maximum duration of a recurring freeze so that finding the start is bounded
+	maxRecurringDuration = 31 * 24 * time.Hour
+
+	localTimeLayout = "2006-01-02 15:04"
+)
+
+// Active an active freeze window
+type Active struct {
+	Window v1alpha1.FreezeWindow
+	End    time.Time
+}
+
+// Action returns the action of the freeze defaulting to fail
+func (a *Active) Action() string {
+	if a.Window.Action == "" {
+		return ActionFail
+	}
+	return a.Window.Action
+}
+
+// Name returns the name of the freeze for messages
+func (a *Active) Name() string {
+	if a.Window.Name != "" {
+		return a.Window.Name
+	}
+	if a.Window.Cron != "" {
+		return a.Window.Cron
+	}
+	return a.Window.Start + " to " + a.Window.End
+}
+
+// EnvironmentWindows returns the freeze windows in the annotation of the Environment
+func EnvironmentWindows(env *v1.Environment) ([]v1alpha1.FreezeWindow, error) {
+	text := env.Annotations[AnnotationFreezeWindows]
+	if text == "" {
+		return nil, nil
+	}
+	var windows []v1alpha1.FreezeWindow
+	err := yaml.Unmarshal([]byte(text), &windows)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the %s annotation on environment %s", AnnotationFreezeWindows, env.Name)
+	}
+	return windows, nil
+}
+
+// FindActive returns the active freeze window for the Environment at the given time or nil if there is none. If
+// multiple windows are active the one ending last is returned
+func FindActive(windows []v1alpha1.FreezeWindow, envName string, now time.Time) (*Active, error) {
+	var answer *Active
+	for _, w := range windows {
+		if len(w.Environments) > 0 && stringhelpers.StringArrayIndex(w.Environments, envName) < 0 {
+			continue
+		}
+		switch w.Action {
+		case "", ActionFail, ActionSkip, ActionQueue:
+		default:
+			return nil, errors.Errorf("unsupported freeze action %s: supported values are %s, %s or %s", w.Action, ActionFail, ActionSkip, ActionQueue)
+		}
+		end, err := activeEnd(w, now)
+		if err != nil {
+			return nil, err
+		}
+		if end != nil && (answer == nil || end.After(answer.End)) {
+			answer = &Active{
+				Window: w,
+				End:    *end,
+			}
+		}
+	}
+	return answer, nil
+}
+
+// activeEnd returns the end of the window if it is active at the given time
+func activeEnd(w v1alpha1.FreezeWindow, now time.Time) (*time.Time, error) {
+	loc := time.UTC
+	if w.TimeZone != "" {
+		var err error
+		loc, err = time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load the time zone %s", w.TimeZone)
+		}
+	}
+	now = now.In(loc)
+
+	if w.Cron != "" {
+		schedule, err := ParseCron(w.Cron)
+		if err != nil {
+			return nil, err
+		}
+		duration, err := time.ParseDuration(w.Duration)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid duration '%s' of the freeze with cron '%s'", w.Duration, w.Cron)
+		}
+		if duration <= 0 || duration > maxRecurringDuration {
+			return nil, errors.Errorf("the duration %s of the freeze with cron '%s' must be positive and at most %s", w.Duration, w.Cron, maxRecurringDuration.String())
+		}
+		// lets find the latest start of the freeze within the duration before now
+		minute := now.Truncate(time.Minute)
+		for t := minute; now.Sub(t) < duration; t = t.Add(-time.Minute) {
+			if schedule.Matches(t) {
+				end := t.Add(duration)
+				return &end, nil
+			}
+		}
+		return nil, nil
+	}
+
+	if w.Start == "" || w.End == "" {
+		return nil, errors.Errorf("the freeze %s must specify either a cron expression and duration or a start and end", w.Name)
+	}
+	start, err := parseTime(w.Start, loc)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseTime(w.End, loc)
+	if err != nil {
+		return nil, err
+	}
+	if !now.Before(start) && now.Before(end) {
+		return &end, nil
+	}
+	return nil, nil
+}
+
+func parseTime(text string, loc *time.Location) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, text)
+	if err == nil {
+		return t, nil
+	}
+	t, err = time.ParseInLocation(localTimeLayout, text, loc)
+	if err != nil {
+		return t, errors.Errorf("invalid time '%s': should be in RFC 3339 format or '%s'", text, localTimeLayout)
+	}
+	return t, nil
+}
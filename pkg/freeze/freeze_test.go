@@ -0,0 +1,119 @@
+package freeze_test
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x/jx-promote/pkg/freeze"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseCron(t *testing.T) {
+	testCases := []struct {
+		cron     string
+		time     string
+		expected bool
+	}{
+		{cron: "0 18 * * 5", time: "2020-07-03T18:00:00Z", expected: true},
+		{cron: "0 18 * * 5", time: "2020-07-03T18:01:00Z", expected: false},
+		{cron: "0 18 * * 5", time: "2020-07-04T18:00:00Z", expected: false},
+		{cron: "*/15 9-17 * * 1-5", time: "2020-07-01T09:45:00Z", expected: true},
+		{cron: "*/15 9-17 * * 1-5", time: "2020-07-01T09:50:00Z", expected: false},
+		{cron: "0 0 25 12 *", time: "2020-12-25T00:00:00Z", expected: true},
+		{cron: "0 0 1 * 0", time: "2020-07-05T00:00:00Z", expected: true},
+		{cron: "0 0 1 * 7", time: "2020-07-01T00:00:00Z", expected: true},
+		{cron: "0 0 1 * 7", time: "2020-07-02T00:00:00Z", expected: false},
+		{cron: "0,30 12 * 6,7 *", time: "2020-07-02T12:30:00Z", expected: true},
+	}
+	for _, tc := range testCases {
+		schedule, err := freeze.ParseCron(tc.cron)
+		require.NoError(t, err, "failed to parse cron %s", tc.cron)
+		now, err := time.Parse(time.RFC3339, tc.time)
+		require.NoError(t, err, "failed to parse time %s", tc.time)
+		assert.Equal(t, tc.expected, schedule.Matches(now), "cron %s at %s", tc.cron, tc.time)
+	}
+
+	for _, cron := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		_, err := freeze.ParseCron(cron)
+		require.Error(t, err, "expected an error for cron '%s'", cron)
+	}
+}
+
+func TestFindActive(t *testing.T) {
+	weekend := v1alpha1.FreezeWindow{
+		Name:     "weekend",
+		Cron:     "0 18 * * 5",
+		Duration: "62h",
+		TimeZone: "America/New_York",
+		Action:   freeze.ActionSkip,
+	}
+	holiday := v1alpha1.FreezeWindow{
+		Name:         "holiday",
+		Start:        "2020-12-24 00:00",
+		End:          "2020-12-27 00:00",
+		TimeZone:     "Europe/London",
+		Environments: []string{"production"},
+	}
+	windows := []v1alpha1.FreezeWindow{weekend, holiday}
+
+	testCases := []struct {
+		env      string
+		time     string
+		expected string
+		end      string
+	}{
+		{env: "staging", time: "2020-07-03T21:59:00Z", expected: ""},
+		{env: "staging", time: "2020-07-03T22:00:00Z", expected: "weekend", end: "2020-07-06T12:00:00Z"},
+		{env: "staging", time: "2020-07-06T11:59:00Z", expected: "weekend", end: "2020-07-06T12:00:00Z"},
+		{env: "staging", time: "2020-07-06T12:00:00Z", expected: ""},
+		{env: "staging", time: "2020-12-24T12:00:00Z", expected: ""},
+		{env: "production", time: "2020-12-24T12:00:00Z", expected: "holiday", end: "2020-12-27T00:00:00Z"},
+		{env: "production", time: "2020-12-26T23:59:00Z", expected: "weekend", end: "2020-12-28T13:00:00Z"},
+		{env: "production", time: "2020-12-28T13:00:00Z", expected: ""},
+	}
+	for _, tc := range testCases {
+		now, err := time.Parse(time.RFC3339, tc.time)
+		require.NoError(t, err, "failed to parse time %s", tc.time)
+		active, err := freeze.FindActive(windows, tc.env, now)
+		require.NoError(t, err, "failed to find the active freeze at %s", tc.time)
+		if tc.expected == "" {
+			assert.Nil(t, active, "no freeze expected for %s at %s", tc.env, tc.time)
+			continue
+		}
+		require.NotNil(t, active, "expected a freeze for %s at %s", tc.env, tc.time)
+		assert.Equal(t, tc.expected, active.Name(), "freeze for %s at %s", tc.env, tc.time)
+		assert.Equal(t, tc.end, active.End.UTC().Format(time.RFC3339), "end of freeze for %s at %s", tc.env, tc.time)
+	}
+
+	active, err := freeze.FindActive([]v1alpha1.FreezeWindow{holiday}, "production", time.Date(2020, 12, 25, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err, "failed to find the active freeze")
+	require.NotNil(t, active, "expected the holiday freeze")
+	assert.Equal(t, freeze.ActionFail, active.Action(), "default action")
+
+	_, err = freeze.FindActive([]v1alpha1.FreezeWindow{{Cron: "0 18 * * 5"}}, "staging", time.Now())
+	require.Error(t, err, "expected an error for a cron without a duration")
+}
+
+func TestEnvironmentWindows(t *testing.T) {
+	env := &v1.Environment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "production",
+			Annotations: map[string]string{
+				freeze.AnnotationFreezeWindows: `- name: incident
+  start: "2020-07-01T00:00:00Z"
+  end: "2020-07-02T00:00:00Z"
+  action: Queue
+`,
+			},
+		},
+	}
+	windows, err := freeze.EnvironmentWindows(env)
+	require.NoError(t, err, "failed to parse the annotation")
+	require.Len(t, windows, 1, "freeze windows")
+	assert.Equal(t, "incident", windows[0].Name, "name")
+	assert.Equal(t, freeze.ActionQueue, windows[0].Action, "action")
+}
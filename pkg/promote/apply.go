@@ -0,0 +1,76 @@
+package promote
+
+import (
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/options"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-helpers/pkg/versionstream"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultVersionStreamDir the dir of the version stream in an environment git repository
+	DefaultVersionStreamDir = "versionStream"
+)
+
+// ApplyOptions the options for promoting an application into a local environment git repository directory without
+// needing a kubernetes cluster or git server
+type ApplyOptions struct {
+	Options
+
+	VersionStreamDir string
+}
+
+// RunApply runs the promote rules against the local directory without committing or pushing the changes
+func (o *ApplyOptions) RunApply() error {
+	if !o.hasApplicationFlag() && o.hasArgs() {
+		o.setApplicationNameFromArgs()
+	}
+	if o.Application == "" {
+		return options.MissingOption(optionApplication)
+	}
+	if o.Version == "" {
+		return options.MissingOption("version")
+	}
+	if o.OutDir == "" {
+		o.OutDir = "."
+	}
+	exists, err := files.DirExists(o.OutDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if dir exists %s", o.OutDir)
+	}
+	if !exists {
+		return errors.Errorf("dir %s does not exist", o.OutDir)
+	}
+
+	if o.DevEnvContext.VersionResolver == nil {
+		versionsDir := o.VersionStreamDir
+		if versionsDir == "" {
+			versionsDir = filepath.Join(o.OutDir, DefaultVersionStreamDir)
+		}
+		exists, err = files.DirExists(versionsDir)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check if version stream exists %s", versionsDir)
+		}
+		if !exists {
+			return errors.Errorf("version stream dir %s does not exist. Please specify it via --version-stream-dir", versionsDir)
+		}
+		o.DevEnvContext.VersionResolver = &versionstream.VersionResolver{
+			VersionsDir: versionsDir,
+		}
+	}
+
+	rule, r, err := o.newRule(o.Namespace)
+	if err != nil {
+		return err
+	}
+	err = rule.Apply(r)
+	if err != nil {
+		return errors.Wrapf(err, "failed to promote app %s to version %s in dir %s", o.Application, o.Version, o.OutDir)
+	}
+	log.Logger().Infof("promoted app %s to version %s in dir %s", termcolor.ColorInfo(o.Application), termcolor.ColorInfo(o.Version), termcolor.ColorInfo(o.OutDir))
+	return nil
+}
@@ -0,0 +1,49 @@
+// +build unit
+
+package promote_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-promote/pkg/promote"
+	"github.com/jenkins-x/jx-promote/pkg/rules/helmfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApply(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	file := filepath.Join(tmpDir, "helmfile.yaml")
+	err = ioutil.WriteFile(file, []byte("releases: []\n"), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save the helmfile")
+
+	for _, version := range []string{"1.2.3", "1.2.4"} {
+		o := &promote.ApplyOptions{
+			VersionStreamDir: filepath.Join("test_data", "jenkins-x-versions"),
+		}
+		o.OutDir = tmpDir
+		o.Application = "myapp"
+		o.Version = version
+		o.Namespace = "jx-staging"
+		o.HelmRepositoryURL = "http://chartmuseum-jx.34.78.195.22.nip.io"
+
+		err = o.RunApply()
+		require.NoError(t, err, "failed to apply version %s", version)
+
+		helmState, err := helmfile.LoadHelmfile(file)
+		require.NoError(t, err, "failed to load the helmfile")
+		require.Len(t, helmState.Releases, 1, "releases")
+		release := helmState.Releases[0]
+		assert.Equal(t, "myapp", release.Name, "release name")
+		assert.Equal(t, "jx-staging", release.Namespace, "release namespace")
+		assert.Equal(t, version, release.Version, "release version")
+	}
+	assert.NoDirExists(t, filepath.Join(tmpDir, ".git"), "no git repository should be created")
+}
@@ -0,0 +1,182 @@
+package promote
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/pkg/kube/activities"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// AnnotationApprovals the annotation on an Environment for the number of approving reviews required before the
+	// promotion Pull Request is merged
+	AnnotationApprovals = "promote.jenkins-x.io/approvals"
+
+	// AnnotationApprovalTeam the annotation on an Environment for the team the approvers must be members of
+	AnnotationApprovalTeam = "promote.jenkins-x.io/approval-team"
+
+	// teamRoleAll lists both the members and maintainers of a team
+	teamRoleAll = "all"
+)
+
+// ApprovalGate the approving reviews required before a promotion Pull Request is merged
+type ApprovalGate struct {
+	Approvals int
+	Team      string
+}
+
+// String returns a description of the gate
+func (g *ApprovalGate) String() string {
+	text := fmt.Sprintf("%d approvals", g.Approvals)
+	if g.Approvals == 1 {
+		text = "1 approval"
+	}
+	if g.Team != "" {
+		text += " from team " + g.Team
+	}
+	return text
+}
+
+// GetApprovalGate returns the approval gate of the Environment from the options or the annotations on the Environment.
+// Environments using the Manual promotion strategy require a single approval by default. Returns nil if no approvals
+// are required
+func (o *Options) GetApprovalGate(env *v1.Environment) (*ApprovalGate, error) {
+	if o.NoApprovalGate || env == nil {
+		return nil, nil
+	}
+	gate := &ApprovalGate{
+		Approvals: o.RequiredApprovals,
+		Team:      o.ApprovalTeam,
+	}
+	if gate.Approvals == 0 {
+		text := env.Annotations[AnnotationApprovals]
+		if text != "" {
+			var err error
+			gate.Approvals, err = strconv.Atoi(text)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid %s annotation '%s' on environment %s", AnnotationApprovals, text, env.Name)
+			}
+		} else if env.Spec.PromotionStrategy == v1.PromotionStrategyTypeManual {
+			gate.Approvals = 1
+		}
+	}
+	if gate.Team == "" {
+		gate.Team = env.Annotations[AnnotationApprovalTeam]
+	}
+	if gate.Approvals <= 0 {
+		return nil, nil
+	}
+	return gate, nil
+}
+
+// GetApprovers returns the sorted logins of the users whose latest review approves the Pull Request. The author of the
+// Pull Request and users who are not members of the team of the gate are ignored
+func (o *Options) GetApprovers(ctx context.Context, scmClient *scm.Client, pr *scm.PullRequest, gate *ApprovalGate) ([]string, error) {
+	fullName := pr.Repository().FullName
+	reviews, _, err := scmClient.Reviews.List(ctx, fullName, pr.Number, scm.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the reviews of Pull Request %s", pr.Link)
+	}
+	sort.SliceStable(reviews, func(i, j int) bool {
+		return reviews[i].Created.Before(reviews[j].Created)
+	})
+
+	// lets use the latest review state of each user ignoring comments
+	states := map[string]string{}
+	for _, review := range reviews {
+		login := review.Author.Login
+		state := strings.ToUpper(review.State)
+		if login == "" || state == scm.ReviewStateCommented || state == scm.ReviewStatePending {
+			continue
+		}
+		states[login] = state
+	}
+
+	var members map[string]bool
+	if gate.Team != "" {
+		members, err = teamMembers(ctx, scmClient, pr.Repository().Namespace, gate.Team)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var approvers []string
+	for login, state := range states {
+		if state != scm.ReviewStateApproved || login == pr.Author.Login {
+			continue
+		}
+		if members != nil && !members[login] {
+			continue
+		}
+		approvers = append(approvers, login)
+	}
+	sort.Strings(approvers)
+	return approvers, nil
+}
+
+// teamMembers returns the logins of the members of the team in the organisation with the given name or slug
+func teamMembers(ctx context.Context, scmClient *scm.Client, org string, name string) (map[string]bool, error) {
+	teams, _, err := scmClient.Organizations.ListTeams(ctx, org, scm.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the teams of organisation %s", org)
+	}
+	for _, team := range teams {
+		if team.Name != name && team.Slug != name {
+			continue
+		}
+		members, _, err := scmClient.Organizations.ListTeamMembers(ctx, team.ID, teamRoleAll, scm.ListOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list the members of team %s", name)
+		}
+		answer := map[string]bool{}
+		for _, m := range members {
+			answer[m.Login] = true
+		}
+		return answer, nil
+	}
+	return nil, errors.Errorf("no team %s found in organisation %s", name, org)
+}
+
+// approvalGateSatisfied returns true if the approval gate of the Environment is satisfied. The state of the gate is
+// logged and recorded in the PipelineActivity whenever it changes
+func (o *Options) approvalGateSatisfied(ctx context.Context, scmClient *scm.Client, env *v1.Environment, pr *scm.PullRequest, promoteKey *activities.PromoteStepActivityKey, lastState *string) bool {
+	gate, err := o.GetApprovalGate(env)
+	if err != nil {
+		log.Logger().Warnf("failed to find the approval gate of environment %s: %s", env.Name, err.Error())
+		return false
+	}
+	if gate == nil {
+		return true
+	}
+	approvers, err := o.GetApprovers(ctx, scmClient, pr, gate)
+	if err != nil {
+		log.Logger().Warnf("failed to check the approvals of Pull Request %s: %s", pr.Link, err.Error())
+		return false
+	}
+	satisfied := len(approvers) >= gate.Approvals
+	description := fmt.Sprintf("%d of %s on %s", len(approvers), gate.String(), pr.Link)
+	if len(approvers) > 0 {
+		description += " by " + strings.Join(approvers, ", ")
+	}
+	if description != *lastState {
+		*lastState = description
+		status := v1.ActivityStatusTypePending
+		if satisfied {
+			status = v1.ActivityStatusTypeSucceeded
+		}
+		log.Logger().Infof("approval gate of environment %s: %s", termcolor.ColorInfo(env.Name), description)
+		recordErr := o.recordStage(promoteKey, "Approval "+env.Name, status, description)
+		if recordErr != nil {
+			log.Logger().Warnf("Failed to record the approval gate in the PipelineActivity: %s", recordErr)
+		}
+	}
+	return satisfied
+}
@@ -0,0 +1,98 @@
+// +build unit
+
+package promote_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-promote/pkg/promote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetApprovalGate(t *testing.T) {
+	env := createPermanentEnvironment("production", "jx-production", "")
+	env.Spec.PromotionStrategy = v1.PromotionStrategyTypeManual
+
+	o := &promote.Options{}
+	gate, err := o.GetApprovalGate(env)
+	require.NoError(t, err, "failed to get the approval gate")
+	assert.Equal(t, &promote.ApprovalGate{Approvals: 1}, gate, "default gate for a Manual environment")
+
+	env.Annotations = map[string]string{
+		promote.AnnotationApprovals:    "2",
+		promote.AnnotationApprovalTeam: "Leads",
+	}
+	gate, err = o.GetApprovalGate(env)
+	require.NoError(t, err, "failed to get the approval gate")
+	assert.Equal(t, &promote.ApprovalGate{Approvals: 2, Team: "Leads"}, gate, "gate from the annotations")
+
+	o.RequiredApprovals = 3
+	o.ApprovalTeam = "Admins"
+	gate, err = o.GetApprovalGate(env)
+	require.NoError(t, err, "failed to get the approval gate")
+	assert.Equal(t, &promote.ApprovalGate{Approvals: 3, Team: "Admins"}, gate, "gate from the options")
+
+	o.NoApprovalGate = true
+	gate, err = o.GetApprovalGate(env)
+	require.NoError(t, err, "failed to get the approval gate")
+	assert.Nil(t, gate, "gate should be disabled")
+
+	staging := createPermanentEnvironment("staging", "jx-staging", "")
+	staging.Spec.PromotionStrategy = v1.PromotionStrategyTypeAutomatic
+	gate, err = (&promote.Options{}).GetApprovalGate(staging)
+	require.NoError(t, err, "failed to get the approval gate")
+	assert.Nil(t, gate, "no gate for an Auto environment")
+}
+
+func TestGetApprovers(t *testing.T) {
+	scmClient, data := fake.NewDefault()
+	pr := &scm.PullRequest{
+		Number: 1,
+		Link:   "https://github.com/myorg/environment-production/pull/1",
+		Author: scm.User{Login: "jenkins-x-bot"},
+		Base: scm.PullRequestBranch{
+			Repo: scm.Repository{
+				Namespace: "myorg",
+				Name:      "environment-production",
+				FullName:  "myorg/environment-production",
+			},
+		},
+	}
+	start := time.Now()
+	review := func(login string, state string, minutes int) *scm.Review {
+		return &scm.Review{
+			Author:  scm.User{Login: login},
+			State:   state,
+			Created: start.Add(time.Duration(minutes) * time.Minute),
+		}
+	}
+	data.Reviews[1] = []*scm.Review{
+		review("jenkins-x-bot", scm.ReviewStateApproved, 0),
+		review("sig-lead", scm.ReviewStateApproved, 1),
+		review("sig-lead", scm.ReviewStateCommented, 2),
+		review("alice", scm.ReviewStateChangesRequested, 4),
+		review("alice", scm.ReviewStateApproved, 3),
+		review("bob", scm.ReviewStateApproved, 5),
+		review("bob", scm.ReviewStateDismissed, 6),
+		review("default-sig-lead", scm.ReviewStateApproved, 7),
+	}
+
+	ctx := context.Background()
+	o := &promote.Options{}
+	approvers, err := o.GetApprovers(ctx, scmClient, pr, &promote.ApprovalGate{Approvals: 2})
+	require.NoError(t, err, "failed to get the approvers")
+	assert.Equal(t, []string{"default-sig-lead", "sig-lead"}, approvers, "approvers")
+
+	approvers, err = o.GetApprovers(ctx, scmClient, pr, &promote.ApprovalGate{Approvals: 2, Team: "Leads"})
+	require.NoError(t, err, "failed to get the approvers")
+	assert.Equal(t, []string{"sig-lead"}, approvers, "approvers from team Leads")
+
+	_, err = o.GetApprovers(ctx, scmClient, pr, &promote.ApprovalGate{Approvals: 1, Team: "doesnotexist"})
+	require.Error(t, err, "expected an error for an unknown team")
+}
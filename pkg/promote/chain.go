@@ -0,0 +1,208 @@
+package promote
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/environments"
+	"github.com/pkg/errors"
+)
+
+// CheckPromotionChain returns an error if the version of the app, or a newer version, has not been merged into every
+// permanent Environment with a lower order than the Environment. If the soak time is specified the version must have
+// been declared in those Environments for at least the soak time since the merge commit
+func (o *Options) CheckPromotionChain(env *v1.Environment, app string, version string, soakTime time.Duration) error {
+	m, names, err := o.GetEnvironments()
+	if err != nil {
+		return errors.Wrapf(err, "failed to load the Environments")
+	}
+	dirs := map[string]string{}
+	defer func() {
+		for _, dir := range dirs {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	for _, name := range names {
+		lower := m[name]
+		if lower == nil || lower.Name == env.Name || lower.Spec.Kind != v1.EnvironmentKindTypePermanent || lower.Spec.Order >= env.Spec.Order {
+			continue
+		}
+		gitURL, sharedDevRepository := o.environmentGitURL(lower)
+		if gitURL == "" {
+			return errors.Errorf("no source repository URL available on environment %s", lower.Name)
+		}
+		ref := lower.Spec.Source.Ref
+		key := gitURL + "#" + ref
+		dir := dirs[key]
+		if dir == "" {
+			dir, _, err = environments.CloneEnvironment(o.Git(), gitURL, ref)
+			if err != nil {
+				return errors.Wrapf(err, "failed to clone environment %s URL %s", lower.Name, gitURL)
+			}
+			dirs[key] = dir
+		}
+		promoteNS := ""
+		if sharedDevRepository {
+			promoteNS = lower.Spec.Namespace
+		}
+		er, err := o.environmentRule(lower, dir, promoteNS)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read the app versions of environment %s", lower.Name)
+		}
+		if er.rule == nil {
+			return errors.Errorf("no promote rule found for environment %s", lower.Name)
+		}
+
+		declared := er.read(app)
+		if declared == "" {
+			return errors.Errorf("version %s has not been promoted to environment %s yet", version, lower.Name)
+		}
+		if !isVersionAtLeast(declared, version) {
+			return errors.Errorf("version %s has not been promoted to environment %s yet which has version %s", version, lower.Name, declared)
+		}
+		log.Logger().Infof("environment %s has version %s of app %s", termcolor.ColorInfo(lower.Name), termcolor.ColorInfo(declared), termcolor.ColorInfo(app))
+
+		if soakTime <= 0 {
+			continue
+		}
+		since, err := o.declaredSince(er, app, version)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find when version %s was merged into environment %s", version, lower.Name)
+		}
+		soaked := time.Since(since)
+		if soaked < soakTime {
+			return errors.Errorf("version %s has only been in environment %s for %s which is less than the soak time %s", version, lower.Name, soaked.Round(time.Second).String(), soakTime.String())
+		}
+	}
+	return nil
+}
+
+// declaredSince returns the timestamp of the oldest merge commit since which the version, or a newer version, has
+// been continuously declared in the environment git clone. The history is read in a copy of the clone into which
+// only the files changed by each commit are written so that the clone itself is never checked out
+func (o *Options) declaredSince(er *environmentRule, app string, version string) (time.Time, error) {
+	var since time.Time
+	dir := er.r.Dir
+	gitter := o.Git()
+	text, err := gitter.Command(dir, "log", "--first-parent", "--format=%H %ct")
+	if err != nil {
+		return since, errors.Wrapf(err, "failed to list the commits in dir %s", dir)
+	}
+
+	historyDir, err := ioutil.TempDir("", "jx-promote-history-")
+	if err != nil {
+		return since, errors.Wrap(err, "failed to create a temporary dir")
+	}
+	defer os.RemoveAll(historyDir)
+	err = files.CopyDirOverwrite(dir, historyDir)
+	if err != nil {
+		return since, errors.Wrapf(err, "failed to copy dir %s to %s", dir, historyDir)
+	}
+	err = os.RemoveAll(filepath.Join(historyDir, ".git"))
+	if err != nil {
+		return since, errors.Wrapf(err, "failed to remove the git metadata from %s", historyDir)
+	}
+
+	// lets read the version at each commit rather than the versions listed when the repository was cloned
+	rc := *er.r
+	rc.AppName = app
+	rc.Dir = historyDir
+	previous := ""
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if previous != "" {
+			err = showChanges(gitter, dir, historyDir, previous, fields[0])
+			if err != nil {
+				break
+			}
+		}
+		previous = fields[0]
+		declared, readErr := er.rule.Read(&rc)
+		if readErr != nil && since.IsZero() {
+			err = errors.Wrapf(readErr, "failed to read the version of app %s at commit %s", app, fields[0])
+			break
+		}
+		if readErr != nil || declared == "" || !isVersionAtLeast(declared, version) {
+			break
+		}
+		seconds, parseErr := strconv.ParseInt(fields[1], 10, 64)
+		if parseErr != nil {
+			err = parseErr
+			break
+		}
+		since = time.Unix(seconds, 0)
+	}
+	if err != nil {
+		return since, errors.Wrapf(err, "failed to read the history of dir %s", dir)
+	}
+	if since.IsZero() {
+		// lets fail closed so that the soak time is never satisfied by a version we could not find
+		return since, errors.Errorf("version %s of app %s is not declared at the head of the history of dir %s", version, app, dir)
+	}
+	return since, nil
+}
+
+// showChanges updates the files in the history dir from the commit to the parent commit by writing the contents of
+// each file changed between them via git show
+func showChanges(gitter gitclient.Interface, dir string, historyDir string, commit string, parent string) error {
+	text, err := gitter.Command(dir, "diff", "--name-status", "--no-renames", "-z", commit, parent)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the changes between commits %s and %s", commit, parent)
+	}
+	fields := strings.Split(strings.Trim(text, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		name := fields[i+1]
+		file := filepath.Join(historyDir, filepath.FromSlash(name))
+		if fields[i] == "D" {
+			err = os.Remove(file)
+			if err != nil && !os.IsNotExist(err) {
+				return errors.Wrapf(err, "failed to remove file %s", file)
+			}
+			continue
+		}
+		data, err := gitter.Command(dir, "show", parent+":"+name)
+		if err != nil {
+			return errors.Wrapf(err, "failed to show file %s at commit %s", name, parent)
+		}
+		err = os.MkdirAll(filepath.Dir(file), files.DefaultDirWritePermissions)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create dir for file %s", file)
+		}
+		err = ioutil.WriteFile(file, []byte(data+"\n"), files.DefaultFileWritePermissions)
+		if err != nil {
+			return errors.Wrapf(err, "failed to save file %s", file)
+		}
+	}
+	return nil
+}
+
+// isVersionAtLeast returns true if the declared version is the same or newer than the version. Versions which are not
+// semantic versions must be equal
+func isVersionAtLeast(declared string, version string) bool {
+	if declared == version {
+		return true
+	}
+	dv, err := semver.ParseTolerant(declared)
+	if err != nil {
+		return false
+	}
+	sv, err := semver.ParseTolerant(version)
+	if err != nil {
+		return false
+	}
+	return dv.GTE(sv)
+}
@@ -0,0 +1,163 @@
+// +build unit
+
+package promote_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-promote/pkg/envsource"
+	"github.com/jenkins-x/jx-promote/pkg/jxtesthelpers"
+	"github.com/jenkins-x/jx-promote/pkg/promote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const chainHelmfile = `releases:
+- chart: dev/myapp
+  version: %s
+  name: myapp
+  namespace: jx-staging
+`
+
+func TestCheckPromotionChain(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	staging := createEnvironmentRepository(t, tmpDir, "staging", fmt.Sprintf(chainHelmfile, "1.2.2"))
+	twoDaysAgo := time.Now().Add(-48 * time.Hour).Format(time.RFC3339)
+	commitHelmfile(t, staging, "1.2.3", twoDaysAgo)
+	commitHelmfile(t, staging, "1.2.5", "")
+
+	envFile := filepath.Join(tmpDir, "environments.yaml")
+	err = ioutil.WriteFile(envFile, []byte(fmt.Sprintf(`environments:
+- name: dev
+  kind: Development
+  namespace: jx
+  gitURL: https://github.com/jenkins-x/default-environment-helmfile.git
+- name: staging
+  order: 100
+  gitURL: %s
+- name: production
+  order: 200
+  gitURL: https://github.com/myorg/environment-mycluster-production.git
+`, staging)), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save the environments file")
+
+	testCases := []struct {
+		version  string
+		soakTime time.Duration
+		err      bool
+	}{
+		{version: "1.2.5"},
+		{version: "1.2.3"},
+		{version: "1.2.6", err: true},
+		{version: "1.2.3", soakTime: 24 * time.Hour},
+		{version: "1.2.5", soakTime: 24 * time.Hour, err: true},
+		{version: "1.2.3", soakTime: 72 * time.Hour, err: true},
+	}
+	for _, tc := range testCases {
+		o := &promote.Options{
+			EnvSource: &envsource.FileSource{Path: envFile},
+		}
+		o.DevEnvContext = *jxtesthelpers.CreateTestDevEnvironmentContext(t, "jx")
+		o.CommandRunner = cmdrunner.QuietCommandRunner
+		o.HelmRepositoryURL = "http://chartmuseum-jx.34.78.195.22.nip.io"
+
+		m, _, err := o.GetEnvironments()
+		require.NoError(t, err, "failed to load the environments")
+		err = o.CheckPromotionChain(m["production"], "myapp", tc.version, tc.soakTime)
+		if tc.err {
+			require.Error(t, err, "expected an error for version %s soak time %s", tc.version, tc.soakTime)
+			t.Logf("got expected error for version %s soak time %s: %s", tc.version, tc.soakTime, err.Error())
+		} else {
+			require.NoError(t, err, "failed to check the promotion chain for version %s soak time %s", tc.version, tc.soakTime)
+		}
+	}
+}
+
+func TestPromotionChainCannotBeForced(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	staging := createEnvironmentRepository(t, tmpDir, "staging", fmt.Sprintf(chainHelmfile, "1.2.2"))
+	production := createEnvironmentRepository(t, tmpDir, "production", fmt.Sprintf(chainHelmfile, "1.2.1"))
+	require.NoError(t, os.MkdirAll(filepath.Join(production, ".jx"), files.DefaultDirWritePermissions))
+	err = ioutil.WriteFile(filepath.Join(production, ".jx", "promote.yaml"), []byte(`apiVersion: promote.jenkins-x.io/v1alpha1
+kind: Promote
+metadata:
+  name: production
+spec:
+  helmfileRule:
+    path: helmfile.yaml
+  policy:
+    requirePromotionChain: true
+`), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save the promote config")
+	runGit(t, production, "add", "-A")
+	runGit(t, production, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "add policy")
+
+	envFile := filepath.Join(tmpDir, "environments.yaml")
+	err = ioutil.WriteFile(envFile, []byte(fmt.Sprintf(`environments:
+- name: dev
+  kind: Development
+  namespace: jx
+  gitURL: https://github.com/jenkins-x/default-environment-helmfile.git
+- name: staging
+  order: 100
+  gitURL: %s
+- name: production
+  order: 200
+  gitURL: %s
+`, staging, production)), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save the environments file")
+
+	o := &promote.Options{
+		EnvSource: &envsource.FileSource{Path: envFile},
+	}
+	o.DevEnvContext = *jxtesthelpers.CreateTestDevEnvironmentContext(t, "jx")
+	o.CommandRunner = cmdrunner.QuietCommandRunner
+	o.HelmRepositoryURL = "http://chartmuseum-jx.34.78.195.22.nip.io"
+	o.Application = "myapp"
+	o.Version = "1.2.3"
+	o.Namespace = "jx"
+	o.DryRun = true
+	o.Out = &bytes.Buffer{}
+	o.Force = true
+
+	m, _, err := o.GetEnvironments()
+	require.NoError(t, err, "failed to load the environments")
+	err = o.PromoteViaPullRequest(m["production"], &promote.ReleaseInfo{})
+	require.Error(t, err, "the promotion chain should not be overridden via --force")
+	assert.Contains(t, err.Error(), "the promotion chain of environment production refuses to promote app myapp to version 1.2.3")
+	assert.Contains(t, err.Error(), "version 1.2.3 has not been promoted to environment staging yet")
+}
+
+// commitHelmfile commits the version of the app to the helmfile optionally with the given commit date
+func commitHelmfile(t *testing.T, dir string, version string, date string) {
+	err := ioutil.WriteFile(filepath.Join(dir, "helmfile.yaml"), []byte(fmt.Sprintf(chainHelmfile, version)), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save the helmfile")
+	runGit(t, dir, "add", "-A")
+
+	env := map[string]string{}
+	if date != "" {
+		env["GIT_AUTHOR_DATE"] = date
+		env["GIT_COMMITTER_DATE"] = date
+	}
+	_, err = cmdrunner.QuietCommandRunner(&cmdrunner.Command{
+		Name: "git",
+		Args: []string{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "promote " + version},
+		Dir:  dir,
+		Env:  env,
+	})
+	require.NoError(t, err, "failed to commit version %s", version)
+}
@@ -0,0 +1,33 @@
+// +build unit
+
+package promote_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-promote/pkg/promote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvironmentsFile(t *testing.T) {
+	o := &promote.Options{
+		EnvironmentsFile: filepath.Join("..", "envsource", "test_data", "environments.yaml"),
+	}
+	err := o.Validate()
+	require.NoError(t, err, "failed to validate")
+	assert.Nil(t, o.KubeClient, "should not create a kube client")
+	assert.Nil(t, o.JXClient, "should not create a jx client")
+	assert.Equal(t, "jx", o.Namespace, "namespace defaults to the dev environment")
+	require.NotNil(t, o.DevEnvContext.DevEnv, "no dev environment")
+
+	targetNS, env, err := o.GetTargetNamespace(o.Namespace, "production")
+	require.NoError(t, err, "failed to get the target namespace")
+	require.NotNil(t, env, "no environment found")
+	assert.Equal(t, "jx-production", targetNS, "target namespace")
+	assert.True(t, env.Spec.RemoteCluster, "remote cluster")
+
+	_, _, err = o.GetTargetNamespace(o.Namespace, "doesnotexist")
+	require.Error(t, err, "should fail for an unknown environment")
+}
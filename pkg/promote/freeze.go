@@ -0,0 +1,78 @@
+package promote
+
+import (
+	"fmt"
+	"time"
+
+	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/freeze"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/pkg/errors"
+)
+
+// errFreezeSkipped is returned by the change function if the promotion is skipped due to a freeze
+var errFreezeSkipped = errors.New("promotion skipped due to a freeze")
+
+// freezeQueuedError is returned by the change function if the change is queued until a freeze ends. The environment
+// git repository is cloned again once the freeze ends so that the change is made to its latest commit
+type freezeQueuedError struct {
+	wait    time.Duration
+	message string
+}
+
+func (e *freezeQueuedError) Error() string {
+	return e.message
+}
+
+// checkFreeze checks the freeze windows in the annotation of the Environment and the environment git repository.
+// If a freeze is active and --break-glass is specified the override is returned so that it can be audited. The change
+// describes what is frozen such as a promotion or a removal
+func (o *Options) checkFreeze(env *v1.Environment, r *rules.PromoteRule, change string) (string, error) {
+	windows, err := freeze.EnvironmentWindows(env)
+	if err != nil {
+		return "", err
+	}
+	windows = append(windows, r.Config.Spec.FreezeWindows...)
+	if len(windows) == 0 {
+		return "", nil
+	}
+	active, err := freeze.FindActive(windows, env.Name, time.Now())
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to check the freeze windows of environment %s", env.Name)
+	}
+	if active == nil {
+		return "", nil
+	}
+	message := fmt.Sprintf("environment %s is frozen by %s until %s", env.Name, active.Name(), active.End.Format(time.RFC3339))
+	if o.BreakGlass {
+		override := fmt.Sprintf("Freeze of environment %s overridden via --break-glass", env.Name)
+		if o.DevEnvContext.GitUsername != "" {
+			override += " by " + o.DevEnvContext.GitUsername
+		}
+		override += ": " + message
+		log.Logger().Warnf("%s", termcolor.ColorWarning(override))
+		return override, nil
+	}
+
+	switch active.Action() {
+	case freeze.ActionSkip:
+		log.Logger().Warnf("skipping the %s of app %s as %s", change, termcolor.ColorInfo(r.AppName), message)
+		return "", errFreezeSkipped
+	case freeze.ActionQueue:
+		if o.TimeoutDuration == nil {
+			return "", errors.Errorf("%s. Use --break-glass to override the freeze", message)
+		}
+		wait := time.Until(active.End)
+		if wait > *o.TimeoutDuration {
+			return "", errors.Errorf("%s which is after the --%s. Use --break-glass to override the freeze", message, optionTimeout)
+		}
+		return "", &freezeQueuedError{
+			wait:    wait,
+			message: fmt.Sprintf("the %s of app %s is queued as %s", change, r.AppName, message),
+		}
+	default:
+		return "", errors.Errorf("%s. Use --break-glass to override the freeze", message)
+	}
+}
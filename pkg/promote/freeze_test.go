@@ -0,0 +1,168 @@
+// +build unit
+
+package promote_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/versionstream"
+	"github.com/jenkins-x/jx-promote/pkg/freeze"
+	"github.com/jenkins-x/jx-promote/pkg/jxtesthelpers"
+	"github.com/jenkins-x/jx-promote/pkg/promote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromoteFreeze(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	dir := createEnvironmentRepository(t, tmpDir, "production", "releases: []\n")
+
+	now := time.Now().UTC()
+	testCases := []struct {
+		action     string
+		breakGlass bool
+		err        bool
+		skipped    bool
+	}{
+		{action: freeze.ActionFail, err: true},
+		{action: freeze.ActionSkip, skipped: true},
+		{action: freeze.ActionFail, breakGlass: true},
+	}
+	for _, tc := range testCases {
+		env := createPermanentEnvironment("production", "jx-production", dir)
+		env.Annotations = map[string]string{
+			freeze.AnnotationFreezeWindows: fmt.Sprintf(`[{"name": "incident", "start": "%s", "end": "%s", "action": "%s"}]`,
+				now.Add(-time.Hour).Format(time.RFC3339), now.Add(time.Hour).Format(time.RFC3339), tc.action),
+		}
+
+		out := &bytes.Buffer{}
+		o := createFreezeOptions(t, out)
+		o.BreakGlass = tc.breakGlass
+
+		releaseInfo, err := o.Promote("jx-production", env, false)
+		if tc.err {
+			require.Error(t, err, "the promotion should fail for action %s", tc.action)
+			assert.Contains(t, err.Error(), "environment production is frozen by incident until")
+			continue
+		}
+		require.NoError(t, err, "failed to promote for action %s break glass %v", tc.action, tc.breakGlass)
+		require.NotNil(t, releaseInfo, "no release info for action %s", tc.action)
+		if tc.skipped {
+			assert.Empty(t, out.String(), "nothing should be promoted when skipped")
+			continue
+		}
+		assert.Contains(t, releaseInfo.FreezeOverride, "Freeze of environment production overridden via --break-glass")
+		assert.Contains(t, out.String(), releaseInfo.FreezeOverride, "the override should be in the commit message")
+	}
+}
+
+func TestPromoteFreezeQueue(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	dir := createEnvironmentRepository(t, tmpDir, "production", "releases: []\n")
+
+	now := time.Now().UTC()
+	env := createPermanentEnvironment("production", "jx-production", dir)
+	env.Annotations = map[string]string{
+		freeze.AnnotationFreezeWindows: fmt.Sprintf(`[{"name": "deploy", "start": "%s", "end": "%s", "action": "Queue"}]`,
+			now.Add(-time.Hour).Format(time.RFC3339), now.Add(3*time.Second).Format(time.RFC3339)),
+	}
+
+	// lets change the environment git repository while the promotion is queued
+	changed := make(chan error, 1)
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		err := ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("# changed during the freeze\n"), files.DefaultFileWritePermissions)
+		for _, args := range [][]string{
+			{"add", "-A"},
+			{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "change during the freeze"},
+		} {
+			if err == nil {
+				_, err = cmdrunner.QuietCommandRunner(&cmdrunner.Command{Name: "git", Args: args, Dir: dir})
+			}
+		}
+		changed <- err
+	}()
+
+	out := &bytes.Buffer{}
+	o := createFreezeOptions(t, out)
+	timeout := time.Minute
+	o.TimeoutDuration = &timeout
+
+	_, err = o.Promote("jx-production", env, false)
+	require.NoError(t, err, "failed to promote once the freeze ended")
+	require.NoError(t, <-changed, "failed to change the environment git repository")
+
+	// the README was only added during the freeze so the promotion should have been made on a new clone
+	require.NotEmpty(t, o.OutDir, "no clone of the environment git repository")
+	assert.FileExists(t, filepath.Join(o.OutDir, "README.md"), "the promotion should be made to the latest commit")
+	assert.Contains(t, out.String(), "myapp", "the app should be promoted")
+}
+
+func TestRemoveFreeze(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	helmfile := `releases:
+- chart: dev/myapp
+  version: 1.2.3
+  name: myapp
+  namespace: jx
+`
+	dir := createEnvironmentRepository(t, tmpDir, "production", helmfile)
+
+	now := time.Now().UTC()
+	env := createPermanentEnvironment("production", "jx-production", dir)
+	env.Annotations = map[string]string{
+		freeze.AnnotationFreezeWindows: fmt.Sprintf(`[{"name": "incident", "start": "%s", "end": "%s"}]`,
+			now.Add(-time.Hour).Format(time.RFC3339), now.Add(time.Hour).Format(time.RFC3339)),
+	}
+
+	out := &bytes.Buffer{}
+	o := createFreezeOptions(t, out)
+	err = o.RemoveViaPullRequest(env, &promote.ReleaseInfo{})
+	require.Error(t, err, "the removal should fail during a freeze")
+	assert.Contains(t, err.Error(), "environment production is frozen by incident until")
+	assert.Empty(t, out.String(), "nothing should be removed during a freeze")
+
+	o.BreakGlass = true
+	releaseInfo := &promote.ReleaseInfo{}
+	err = o.RemoveViaPullRequest(env, releaseInfo)
+	require.NoError(t, err, "failed to remove via --break-glass")
+	assert.Contains(t, releaseInfo.FreezeOverride, "Freeze of environment production overridden via --break-glass")
+	assert.Contains(t, out.String(), releaseInfo.FreezeOverride, "the override should be in the commit message")
+	assert.Contains(t, out.String(), "-  name: myapp", "the app should be removed")
+}
+
+// createFreezeOptions creates the options to promote myapp in dry run mode
+func createFreezeOptions(t *testing.T, out *bytes.Buffer) *promote.Options {
+	o := &promote.Options{}
+	o.DevEnvContext = *jxtesthelpers.CreateTestDevEnvironmentContext(t, "jx")
+	o.DevEnvContext.VersionResolver = &versionstream.VersionResolver{
+		VersionsDir: filepath.Join("test_data", "jenkins-x-versions"),
+	}
+	o.CommandRunner = cmdrunner.QuietCommandRunner
+	o.HelmRepositoryURL = "http://chartmuseum-jx.34.78.195.22.nip.io"
+	o.Application = "myapp"
+	o.Version = "1.2.3"
+	o.Namespace = "jx"
+	o.BatchMode = true
+	o.IgnoreLocalFiles = true
+	o.DryRun = true
+	o.Out = out
+	return o
+}
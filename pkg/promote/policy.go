@@ -0,0 +1,156 @@
+package promote
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blang/semver"
+	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/pkg/options"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/pkg/errors"
+)
+
+const (
+	// UpgradePatch only allows patch upgrades
+	UpgradePatch = "patch"
+
+	// UpgradeMinor allows minor and patch upgrades
+	UpgradeMinor = "minor"
+
+	// UpgradeMajor allows any upgrade
+	UpgradeMajor = "major"
+)
+
+// GetPolicy returns the policy for the Environment or nil if there is none
+func GetPolicy(spec *v1alpha1.PromoteSpec, envName string) *v1alpha1.PolicySpec {
+	if spec.EnvironmentPolicies != nil {
+		policy, ok := spec.EnvironmentPolicies[envName]
+		if ok {
+			return &policy
+		}
+	}
+	return spec.Policy
+}
+
+// CheckPolicy returns an error describing why the policy refuses promoting the version into the Environment
+// which currently declares the current version. The current version is blank if the app is not declared yet
+func CheckPolicy(policy *v1alpha1.PolicySpec, env *v1.Environment, current string, version string) error {
+	if policy == nil {
+		return nil
+	}
+	sv, err := semver.ParseTolerant(version)
+	if err != nil {
+		return errors.Errorf("version %s is not a semantic version", version)
+	}
+	if policy.DenyPrerelease && len(sv.Pre) > 0 {
+		return errors.Errorf("version %s is a prerelease version", version)
+	}
+	if policy.Constraint != "" {
+		constraint, err := semver.ParseRange(policy.Constraint)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse the version constraint %s", policy.Constraint)
+		}
+		if !constraint(sv) {
+			return errors.Errorf("version %s does not satisfy the constraint %s", version, policy.Constraint)
+		}
+	}
+	if current == "" {
+		return nil
+	}
+	cv, err := semver.ParseTolerant(current)
+	if err != nil {
+		log.Logger().Debugf("ignoring the current version %s as it is not a semantic version: %s", current, err.Error())
+		return nil
+	}
+	if policy.PreventDowngrade && sv.LT(cv) {
+		return errors.Errorf("version %s is a downgrade from the current version %s", version, current)
+	}
+	if env != nil && env.Spec.PromotionStrategy == v1.PromotionStrategyTypeAutomatic && sv.GT(cv) {
+		switch policy.AutomaticUpgrade {
+		case "", UpgradeMajor:
+		case UpgradeMinor:
+			if sv.Major != cv.Major {
+				return errors.Errorf("version %s is a major upgrade from the current version %s", version, current)
+			}
+		case UpgradePatch:
+			if sv.Major != cv.Major || sv.Minor != cv.Minor {
+				return errors.Errorf("version %s is more than a patch upgrade from the current version %s", version, current)
+			}
+		default:
+			return errors.Errorf("unsupported automaticUpgrade %s: supported values are %s, %s or %s", policy.AutomaticUpgrade, UpgradePatch, UpgradeMinor, UpgradeMajor)
+		}
+	}
+	return nil
+}
+
+// promotionChainPolicy returns whether the promotion chain is required and the soak time from the options or policy
+func (o *Options) promotionChainPolicy(policy *v1alpha1.PolicySpec) (bool, time.Duration, error) {
+	requireChain := o.RequirePromotionChain
+	var soakTime time.Duration
+	if o.SoakTime != "" {
+		duration, err := time.ParseDuration(o.SoakTime)
+		if err != nil {
+			return false, soakTime, options.InvalidOptionf("soak-time", o.SoakTime, "invalid duration: %s", err.Error())
+		}
+		soakTime = duration
+	}
+	if policy != nil {
+		requireChain = requireChain || policy.RequirePromotionChain
+		if policy.SoakTime != "" {
+			duration, err := time.ParseDuration(policy.SoakTime)
+			if err != nil {
+				return false, soakTime, errors.Wrapf(err, "invalid soakTime %s in the policy", policy.SoakTime)
+			}
+			if duration > soakTime {
+				soakTime = duration
+			}
+		}
+	}
+	return requireChain || soakTime > 0, soakTime, nil
+}
+
+// checkPolicy checks the policy of the environment git repository against the currently declared version of the app.
+// If the promotion is refused and --force is specified the override is returned so that it can be audited. The
+// promotion chain is checked separately as it cannot be overridden via --force
+func (o *Options) checkPolicy(env *v1.Environment, rule rules.Rule, r *rules.PromoteRule) (string, error) {
+	policy := GetPolicy(&r.Config.Spec, env.Name)
+	requireChain, soakTime, err := o.promotionChainPolicy(policy)
+	if err != nil {
+		return "", err
+	}
+	if policy == nil && !requireChain {
+		return "", nil
+	}
+	if r.Version == "" {
+		log.Logger().Warnf("cannot check the promotion policy of environment %s as no version is specified", env.Name)
+		return "", nil
+	}
+	current, err := rule.Read(r)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read the current version of app %s", r.AppName)
+	}
+	override := ""
+	err = CheckPolicy(policy, env, current, r.Version)
+	if err != nil {
+		if !o.Force {
+			return "", errors.Errorf("the policy of environment %s refuses to promote app %s to version %s: %s. Use --force to override the policy", env.Name, r.AppName, r.Version, err.Error())
+		}
+		override = fmt.Sprintf("Policy of environment %s overridden via --force", env.Name)
+		if o.DevEnvContext.GitUsername != "" {
+			override += " by " + o.DevEnvContext.GitUsername
+		}
+		override += ": " + err.Error()
+		log.Logger().Warnf("%s", termcolor.ColorWarning(override))
+	}
+	if requireChain {
+		err = o.CheckPromotionChain(env, r.AppName, r.Version, soakTime)
+		if err != nil {
+			return "", errors.Errorf("the promotion chain of environment %s refuses to promote app %s to version %s: %s", env.Name, r.AppName, r.Version, err.Error())
+		}
+	}
+	return override, nil
+}
@@ -0,0 +1,132 @@
+// +build unit
+
+package promote_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/versionstream"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x/jx-promote/pkg/jxtesthelpers"
+	"github.com/jenkins-x/jx-promote/pkg/promote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPolicy(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   v1alpha1.PolicySpec
+		strategy v1.PromotionStrategyType
+		current  string
+		version  string
+		err      bool
+	}{
+		{name: "no-policy", current: "2.0.0", version: "1.0.0"},
+		{name: "constraint", policy: v1alpha1.PolicySpec{Constraint: ">=1.0.0 <2.0.0"}, version: "1.5.0"},
+		{name: "constraint-refused", policy: v1alpha1.PolicySpec{Constraint: ">=1.0.0 <2.0.0"}, version: "2.0.0", err: true},
+		{name: "not-semver", policy: v1alpha1.PolicySpec{PreventDowngrade: true}, version: "abc", err: true},
+		{name: "downgrade", policy: v1alpha1.PolicySpec{PreventDowngrade: true}, current: "1.2.4", version: "1.2.3", err: true},
+		{name: "upgrade", policy: v1alpha1.PolicySpec{PreventDowngrade: true}, current: "1.2.3", version: "1.2.4"},
+		{name: "new-app", policy: v1alpha1.PolicySpec{PreventDowngrade: true}, version: "0.0.1"},
+		{name: "prerelease", policy: v1alpha1.PolicySpec{DenyPrerelease: true}, version: "1.2.3-rc.1", err: true},
+		{name: "release", policy: v1alpha1.PolicySpec{DenyPrerelease: true}, version: "1.2.3"},
+		{name: "auto-patch", policy: v1alpha1.PolicySpec{AutomaticUpgrade: promote.UpgradePatch}, strategy: v1.PromotionStrategyTypeAutomatic, current: "1.2.3", version: "1.2.9"},
+		{name: "auto-patch-refused", policy: v1alpha1.PolicySpec{AutomaticUpgrade: promote.UpgradePatch}, strategy: v1.PromotionStrategyTypeAutomatic, current: "1.2.3", version: "1.3.0", err: true},
+		{name: "manual-patch", policy: v1alpha1.PolicySpec{AutomaticUpgrade: promote.UpgradePatch}, strategy: v1.PromotionStrategyTypeManual, current: "1.2.3", version: "1.3.0"},
+		{name: "auto-minor", policy: v1alpha1.PolicySpec{AutomaticUpgrade: promote.UpgradeMinor}, strategy: v1.PromotionStrategyTypeAutomatic, current: "1.2.3", version: "1.3.0"},
+		{name: "auto-minor-refused", policy: v1alpha1.PolicySpec{AutomaticUpgrade: promote.UpgradeMinor}, strategy: v1.PromotionStrategyTypeAutomatic, current: "1.2.3", version: "2.0.0", err: true},
+	}
+	for _, tc := range testCases {
+		env := createPermanentEnvironment("staging", "jx-staging", "")
+		env.Spec.PromotionStrategy = tc.strategy
+		var policy *v1alpha1.PolicySpec
+		if tc.name != "no-policy" {
+			policy = &tc.policy
+		}
+		err := promote.CheckPolicy(policy, env, tc.current, tc.version)
+		if tc.err {
+			require.Error(t, err, "expected an error for %s", tc.name)
+			t.Logf("got expected error for %s: %s", tc.name, err.Error())
+		} else {
+			require.NoError(t, err, "unexpected error for %s", tc.name)
+		}
+	}
+}
+
+func TestGetPolicy(t *testing.T) {
+	spec := &v1alpha1.PromoteSpec{
+		Policy: &v1alpha1.PolicySpec{PreventDowngrade: true},
+		EnvironmentPolicies: map[string]v1alpha1.PolicySpec{
+			"production": {DenyPrerelease: true},
+		},
+	}
+	assert.Equal(t, &v1alpha1.PolicySpec{PreventDowngrade: true}, promote.GetPolicy(spec, "staging"), "staging policy")
+	assert.Equal(t, &v1alpha1.PolicySpec{DenyPrerelease: true}, promote.GetPolicy(spec, "production"), "production policy")
+	assert.Nil(t, promote.GetPolicy(&v1alpha1.PromoteSpec{}, "staging"), "no policy")
+}
+
+func TestPromotePolicyForce(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	dir := createEnvironmentRepository(t, tmpDir, "production", `releases:
+- chart: dev/myapp
+  version: 1.2.4
+  name: myapp
+  namespace: jx-production
+`)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".jx"), files.DefaultDirWritePermissions))
+	err = ioutil.WriteFile(filepath.Join(dir, ".jx", "promote.yaml"), []byte(`apiVersion: promote.jenkins-x.io/v1alpha1
+kind: Promote
+metadata:
+  name: production
+spec:
+  helmfileRule:
+    path: helmfile.yaml
+    namespace: jx-production
+  policy:
+    preventDowngrade: true
+`), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save the promote config")
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "add policy")
+
+	env := createPermanentEnvironment("production", "jx-production", dir)
+	for _, force := range []bool{false, true} {
+		out := &bytes.Buffer{}
+		o := &promote.Options{}
+		o.DevEnvContext = *jxtesthelpers.CreateTestDevEnvironmentContext(t, "jx")
+		o.DevEnvContext.VersionResolver = &versionstream.VersionResolver{
+			VersionsDir: filepath.Join("test_data", "jenkins-x-versions"),
+		}
+		o.CommandRunner = cmdrunner.QuietCommandRunner
+		o.HelmRepositoryURL = "http://chartmuseum-jx.34.78.195.22.nip.io"
+		o.Application = "myapp"
+		o.Version = "1.2.3"
+		o.Namespace = "jx"
+		o.DryRun = true
+		o.Out = out
+		o.Force = force
+
+		releaseInfo := &promote.ReleaseInfo{}
+		err = o.PromoteViaPullRequest(env, releaseInfo)
+		if !force {
+			require.Error(t, err, "the downgrade should be refused")
+			assert.Contains(t, err.Error(), "version 1.2.3 is a downgrade from the current version 1.2.4")
+			continue
+		}
+		require.NoError(t, err, "failed to promote with --force")
+		assert.Contains(t, releaseInfo.PolicyOverride, "Policy of environment production overridden via --force")
+		assert.Contains(t, out.String(), releaseInfo.PolicyOverride, "the override should be in the commit message")
+		assert.Contains(t, out.String(), "+  version: 1.2.3")
+	}
+}
@@ -3,6 +3,7 @@ package promote
 import (
 	"fmt"
 	"path/filepath"
+	"time"
 
 	"github.com/jenkins-x/go-scm/scm"
 	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
@@ -10,6 +11,8 @@ import (
 	"github.com/jenkins-x/jx-helpers/pkg/gitclient"
 	"github.com/jenkins-x/jx-helpers/pkg/gitclient/gitconfig"
 	"github.com/jenkins-x/jx-helpers/pkg/yaml2s"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
 	"github.com/jenkins-x/jx-promote/pkg/promoteconfig"
 	"github.com/jenkins-x/jx-promote/pkg/rules"
 	"github.com/jenkins-x/jx-promote/pkg/rules/factory"
@@ -33,14 +36,91 @@ func (o *Options) PromoteViaPullRequest(env *v1.Environment, releaseInfo *Releas
 		Body:   fmt.Sprintf("chore: Promote %s to version %s", app, versionName),
 	}
 
-	o.EnvironmentPullRequestOptions.CommitTitle = details.Title
-	o.EnvironmentPullRequestOptions.CommitMessage = details.Body
+	if releaseInfo.PullRequestInfo != nil {
+		o.PullRequestNumber = releaseInfo.PullRequestInfo.Number
+	}
+	return o.createPullRequest(env, &details, true, releaseInfo, func(rule rules.Rule, r *rules.PromoteRule) error {
+		freezeOverride, err := o.checkFreeze(env, r, "promotion")
+		if err != nil {
+			return err
+		}
+		policyOverride, err := o.checkPolicy(env, rule, r)
+		if err != nil {
+			return err
+		}
+		err = o.applyPullRequestTemplates(env, rule, r, &details, releaseInfo)
+		if err != nil {
+			return err
+		}
+		// lets record any overrides in the commit so they are audited in the environment git repository
+		for _, override := range []string{freezeOverride, policyOverride} {
+			if override != "" {
+				o.EnvironmentPullRequestOptions.CommitMessage += "\n\n" + override
+			}
+		}
+		releaseInfo.FreezeOverride = freezeOverride
+		releaseInfo.PolicyOverride = policyOverride
+		return rule.Apply(r)
+	})
+}
+
+// RemoveViaPullRequest creates a Pull Request on the environment git repository to remove the application
+func (o *Options) RemoveViaPullRequest(env *v1.Environment, releaseInfo *ReleaseInfo) error {
+	app := o.Application
+
+	// lets use a new branch for each removal so that it does not clash with any earlier removal Pull Request
+	branch := fmt.Sprintf("remove/%s/%s/%s", env.Name, app, time.Now().Format("20060102150405"))
+	details := scm.PullRequest{
+		Source: branch,
+		Title:  "chore: remove " + app,
+		Body:   fmt.Sprintf("chore: Remove %s from environment %s", app, env.Name),
+	}
+	return o.createPullRequest(env, &details, true, releaseInfo, func(rule rules.Rule, r *rules.PromoteRule) error {
+		freezeOverride, err := o.checkFreeze(env, r, "removal")
+		if err != nil {
+			return err
+		}
+		if freezeOverride != "" {
+			o.EnvironmentPullRequestOptions.CommitMessage += "\n\n" + freezeOverride
+		}
+		releaseInfo.FreezeOverride = freezeOverride
+		o.EnvironmentPullRequestOptions.BranchName = branch
+		return rule.Remove(r)
+	})
+}
+
+// createPullRequest clones the environment git repository, invokes the function with the rule discovered in the
+// clone and then creates the Pull Request with the changes. The title and body of the details are used for the commit
+// unless the function changes them. If the function queues the change until a freeze ends the repository is cloned
+// again once the freeze is over
+func (o *Options) createPullRequest(env *v1.Environment, details *scm.PullRequest, autoMerge bool, releaseInfo *ReleaseInfo, fn func(rules.Rule, *rules.PromoteRule) error) error {
+	promoteNS, err := o.promoteNamespace(env, o.Application)
+	if err != nil {
+		return err
+	}
+	o.Function = o.ruleFunction(promoteNS, fn)
+
+	for {
+		o.EnvironmentPullRequestOptions.CommitTitle = details.Title
+		o.EnvironmentPullRequestOptions.CommitMessage = details.Body
+		o.EnvironmentPullRequestOptions.PullRequestBody = ""
+		o.EnvironmentPullRequestOptions.BranchName = ""
+		o.EnvironmentPullRequestOptions.ExistingAction = ""
+		o.EnvironmentPullRequestOptions.ExistingBranches = nil
 
-	envDir := ""
-	if o.CloneDir != "" {
-		envDir = o.CloneDir
+		info, err := o.Create(env, o.CloneDir, details, "", autoMerge)
+		if queued, ok := errors.Cause(err).(*freezeQueuedError); ok {
+			log.Logger().Infof("waiting %s as %s", queued.wait.Round(time.Second).String(), queued.message)
+			time.Sleep(queued.wait)
+			continue
+		}
+		releaseInfo.PullRequestInfo = info
+		return err
 	}
+}
 
+// promoteNamespace returns the namespace the app is promoted to in the environment git repository
+func (o *Options) promoteNamespace(env *v1.Environment, app string) (string, error) {
 	promoteNS := ""
 	if o.DevEnvContext.DevEnv != nil && o.DevEnvContext.DevEnv.Spec.Source.URL == env.Spec.Source.URL {
 		promoteNS = env.Spec.Namespace
@@ -48,67 +128,88 @@ func (o *Options) PromoteViaPullRequest(env *v1.Environment, releaseInfo *Releas
 	if env.Spec.RemoteCluster == true {
 		ns, err := getRemoteNamespace(o, env, app)
 		if err != nil {
-			return err
+			return "", err
 		}
 		if ns != nil {
 			promoteNS = *ns
 		}
 	}
+	return promoteNS, nil
+}
 
-	o.Function = func() error {
-		configureDependencyMatrix()
-
-		dir := o.OutDir
-		promoteConfig, _, err := promoteconfig.Discover(dir, promoteNS)
+// ruleFunction returns a function which discovers the rule in the environment git clone, validates it and then
+// invokes the given method of the rule
+func (o *Options) ruleFunction(promoteNS string, fn func(rules.Rule, *rules.PromoteRule) error) func() error {
+	return func() error {
+		rule, r, err := o.newRule(promoteNS)
 		if err != nil {
-			return errors.Wrapf(err, "failed to discover the PromoteConfig in dir %s", dir)
+			return err
 		}
+		return fn(rule, r)
+	}
+}
 
-		r := &rules.PromoteRule{
-			TemplateContext: rules.TemplateContext{
-				GitURL:            "",
-				Version:           o.Version,
-				AppName:           o.Application,
-				ChartAlias:        o.Alias,
-				Namespace:         o.Namespace,
-				HelmRepositoryURL: o.HelmRepositoryURL,
-			},
-			Dir:           dir,
-			Config:        *promoteConfig,
-			DevEnvContext: &o.DevEnvContext,
-		}
+// newRule discovers the rule in the environment git clone and validates it
+func (o *Options) newRule(promoteNS string) (rules.Rule, *rules.PromoteRule, error) {
+	configureDependencyMatrix()
+
+	dir := o.OutDir
+	promoteConfig, _, err := promoteconfig.Discover(dir, promoteNS)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to discover the PromoteConfig in dir %s", dir)
+	}
+
+	r := &rules.PromoteRule{
+		TemplateContext: rules.TemplateContext{
+			GitURL:            "",
+			Version:           o.Version,
+			AppName:           o.Application,
+			ChartAlias:        o.Alias,
+			Namespace:         o.Namespace,
+			HelmRepositoryURL: o.HelmRepositoryURL,
+		},
+		Dir:           dir,
+		Config:        *promoteConfig,
+		DevEnvContext: &o.DevEnvContext,
+	}
 
-		// lets check if we need the apps git URL
-		if promoteConfig.Spec.FileRule != nil || promoteConfig.Spec.KptRule != nil {
+	// lets check if we need the apps git URL
+	if requiresAppGitURL(&promoteConfig.Spec) {
+		if o.AppGitURL == "" {
+			_, gitConf, err := gitclient.FindGitConfigDir("")
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to find git config dir")
+			}
+			o.AppGitURL, err = gitconfig.DiscoverUpstreamGitURL(gitConf)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to discover application git URL")
+			}
 			if o.AppGitURL == "" {
-				_, gitConf, err := gitclient.FindGitConfigDir("")
-				if err != nil {
-					return errors.Wrapf(err, "failed to find git config dir")
-				}
-				o.AppGitURL, err = gitconfig.DiscoverUpstreamGitURL(gitConf)
-				if err != nil {
-					return errors.Wrapf(err, "failed to discover application git URL")
-				}
-				if o.AppGitURL == "" {
-					return errors.Errorf("could not to discover application git URL")
-				}
+				return nil, nil, errors.Errorf("could not to discover application git URL")
 			}
-			r.TemplateContext.GitURL = o.AppGitURL
 		}
+		r.TemplateContext.GitURL = o.AppGitURL
+	}
 
-		fn := factory.NewFunction(r)
-		if fn == nil {
-			return errors.Errorf("could not create rule function ")
-		}
-		return fn(r)
+	rule := factory.NewRule(r)
+	if rule == nil {
+		return nil, nil, errors.Errorf("could not create rule")
+	}
+	err = rule.Validate(r)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid promote rule in dir %s", dir)
 	}
+	return rule, r, nil
+}
 
-	if releaseInfo.PullRequestInfo != nil {
-		o.PullRequestNumber = releaseInfo.PullRequestInfo.Number
+// requiresAppGitURL returns true if any of the rules need the git URL of the application
+func requiresAppGitURL(spec *v1alpha1.PromoteSpec) bool {
+	for _, rule := range factory.RuleSpecs(spec) {
+		if rule.FileRule != nil || rule.KptRule != nil {
+			return true
+		}
 	}
-	info, err := o.Create(env, envDir, &details, "", true)
-	releaseInfo.PullRequestInfo = info
-	return err
+	return false
 }
 
 func configureDependencyMatrix() {
@@ -119,19 +220,28 @@ func configureDependencyMatrix() {
 
 func getRemoteNamespace(o *Options, env *v1.Environment, app string) (*string, error) {
 	var promoteNS *string = nil
-	// 1. Load helmfile
-	hf := filepath.Join(o.OutDir, "helmfile.yaml")
-	helmfile, err := helmfile.LoadHelmfile(hf)
+	// 1. Load helmfile and any nested helmfiles
+	hf, err := findHelmfilePath(o.OutDir)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to load helmfile for %s environment", env.GetName())
+		return nil, err
+	}
+	helmfiles, err := helmfile.FindHelmfiles(hf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find helmfiles for %s environment", env.GetName())
 	}
 
 	// 2. Check if app exists
 	foundApp := false
-	for i := range helmfile.Releases {
-		release := &helmfile.Releases[i]
-		if release.Name == app {
-			foundApp = true
+	for _, f := range helmfiles {
+		state, err := helmfile.LoadHelmfile(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load helmfile for %s environment", env.GetName())
+		}
+		for i := range state.Releases {
+			release := &state.Releases[i]
+			if release.Name == app {
+				foundApp = true
+			}
 		}
 	}
 
@@ -151,6 +261,22 @@ func getRemoteNamespace(o *Options, env *v1.Environment, app string) (*string, e
 	return promoteNS, nil
 }
 
+// findHelmfilePath returns the path of the root helmfile using the helmfile rule if there is one
+func findHelmfilePath(dir string) (string, error) {
+	config, _, err := promoteconfig.LoadPromote(dir, false)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to load Promote configuration from %s", dir)
+	}
+	if config != nil {
+		for _, rule := range factory.RuleSpecs(&config.Spec) {
+			if rule.HelmfileRule != nil && rule.HelmfileRule.Path != "" {
+				return filepath.Join(dir, rule.HelmfileRule.Path), nil
+			}
+		}
+	}
+	return filepath.Join(dir, helmfile.DefaultPath), nil
+}
+
 func getNamespaceFromRequirements(outdir string) (*string, error) {
 	path := filepath.Join(outdir, "jx-requirements.yml")
 	state := api_config.RequirementsConfig{}
@@ -159,4 +285,4 @@ func getNamespaceFromRequirements(outdir string) (*string, error) {
 		return nil, err
 	}
 	return &state.Cluster.Namespace, nil
-}
\ No newline at end of file
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/jenkins-x/jx-helpers/pkg/stringhelpers"
 	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
 	"github.com/jenkins-x/jx-promote/pkg/environments"
+	"github.com/jenkins-x/jx-promote/pkg/envsource"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/jenkins-x/jx-helpers/pkg/cobras/helper"
@@ -86,16 +87,27 @@ type Options struct {
 	NoWaitAfterMerge        bool
 	IgnoreLocalFiles        bool
 	NoWaitForUpdatePipeline bool
+	Force                   bool
+	BreakGlass              bool
+	NoApprovalGate          bool
+	RequiredApprovals       int
+	ApprovalTeam            string
+	MergeMethod             string
+	ExistingPullRequests    string
+	RequirePromotionChain   bool
+	SoakTime                string
 	DisableGitConfig        bool //  to disable git init in unit tests
 	Timeout                 string
 	PullRequestPollTime     string
 	Filter                  string
 	Alias                   string
+	EnvironmentsFile        string
 
 	KubeClient kubernetes.Interface
 	JXClient   versioned.Interface
 	Helmer     helm.Helmer
 	Input      input.Interface
+	EnvSource  envsource.Source
 
 	// calculated fields
 	TimeoutDuration         *time.Duration
@@ -111,10 +123,14 @@ type Options struct {
 }
 
 type ReleaseInfo struct {
-	ReleaseName     string
-	FullAppName     string
-	Version         string
-	PullRequestInfo *scm.PullRequest
+	ReleaseName      string
+	FullAppName      string
+	Version          string
+	PullRequestInfo  *scm.PullRequest
+	PolicyOverride   string
+	FreezeOverride   string
+	MergeMethod      string
+	MergeCommitTitle string
 }
 
 var (
@@ -181,6 +197,7 @@ func (o *Options) AddOptions(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&o.Version, "version", "v", "", "The Version to promote. If no version is specified it defaults to $VERSION which is usually populated in a pipeline. If no value can be found you will be prompted to pick the version")
 	cmd.Flags().StringVarP(&o.LocalHelmRepoName, "helm-repo-name", "r", kube.LocalHelmRepoName, "The name of the helm repository that contains the app")
 	cmd.Flags().StringVarP(&o.HelmRepositoryURL, "helm-repo-url", "u", "", "The Helm Repository URL to use for the App")
+	cmd.Flags().StringVarP(&o.EnvironmentsFile, "environments-file", "", "", "The YAML file defining the Environments to use instead of the Environment resources in the cluster")
 	cmd.Flags().StringVarP(&o.ReleaseName, "release", "", "", "The name of the helm release")
 	cmd.Flags().StringVarP(&o.Timeout, optionTimeout, "t", "1h", "The timeout to wait for the promotion to succeed in the underlying Environment. The command fails if the timeout is exceeded or the promotion does not complete")
 	cmd.Flags().StringVarP(&o.PullRequestPollTime, optionPullRequestPollTime, "", "20s", "Poll time when waiting for a Pull Request to merge")
@@ -190,7 +207,17 @@ func (o *Options) AddOptions(cmd *cobra.Command) {
 
 	cmd.Flags().BoolVarP(&o.NoHelmUpdate, "no-helm-update", "", false, "Allows the 'helm repo update' command if you are sure your local helm cache is up to date with the version you wish to promote")
 	cmd.Flags().BoolVarP(&o.NoMergePullRequest, "no-merge", "", false, "Disables automatic merge of promote Pull Requests")
-
+	cmd.Flags().StringVarP(&o.ExistingPullRequests, optionExistingPullRequests, "", "", "What to do with existing open promotion Pull Requests of the app. Either 'Create' to leave them open, 'Reuse' to force push onto the newest one or 'Supersede' to close them. Defaults to the annotation on the Environment or 'Create'")
+	cmd.Flags().StringVarP(&o.MergeMethod, optionMergeMethod, "", "", "How promote Pull Requests are merged. Either 'merge', 'squash' or 'rebase'. Defaults to the pullRequest configuration in the environment git repository or the default of the git provider")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", false, "Prints the changes and the Pull Request which would be created without pushing them")
+	cmd.Flags().BoolVarP(&o.RequirePromotionChain, "require-promotion-chain", "", false, "Refuses to promote unless the version, or a newer version, has been merged into every permanent Environment with a lower order")
+	cmd.Flags().StringVarP(&o.SoakTime, "soak-time", "", "", "The minimum duration such as '24h' the version must have been merged into every permanent Environment with a lower order. Implies --require-promotion-chain")
+	cmd.Flags().BoolVarP(&o.BreakGlass, "break-glass", "", false, "Promotes even if the Environment is frozen. The override is recorded in the commit message and PipelineActivity")
+	cmd.Flags().BoolVarP(&o.Force, "force", "", false, "Promotes even if the version is refused by the policy of the Environment. The override is recorded in the commit message and PipelineActivity. The promotion chain cannot be overridden")
+
+	cmd.Flags().IntVarP(&o.RequiredApprovals, "approvals", "", 0, "The number of approving reviews required before the promotion Pull Request is merged. Defaults to the annotation on the Environment or 1 for Environments using the Manual promotion strategy")
+	cmd.Flags().StringVarP(&o.ApprovalTeam, "approval-team", "", "", "The team the approving reviewers must be members of. Defaults to the annotation on the Environment")
+	cmd.Flags().BoolVarP(&o.NoApprovalGate, "no-approval-gate", "", false, "Disables waiting for approving reviews before merging the promotion Pull Request")
 	cmd.Flags().BoolVarP(&o.NoPoll, "no-poll", "", false, "Disables polling for Pull Request or Pipeline status")
 	cmd.Flags().BoolVarP(&o.NoWaitAfterMerge, "no-wait", "", false, "Disables waiting for completing promotion after the Pull request is merged")
 	cmd.Flags().BoolVarP(&o.IgnoreLocalFiles, "ignore-local-file", "", false, "Ignores the local file system when deducing the Git repository")
@@ -277,6 +304,9 @@ func (o *Options) Validate() error {
 	if o.Input == nil {
 		o.Input = survey.NewInput()
 	}
+	if o.EnvironmentsFile != "" {
+		return o.validateEnvironmentsFile()
+	}
 	var err error
 	o.KubeClient, o.Namespace, err = kube.LazyCreateKubeClientAndNamespace(o.KubeClient, o.Namespace)
 	if err != nil {
@@ -289,6 +319,39 @@ func (o *Options) Validate() error {
 	return nil
 }
 
+// validateEnvironmentsFile uses the Environments defined in the file rather than connecting to a cluster
+func (o *Options) validateEnvironmentsFile() error {
+	source := &envsource.FileSource{Path: o.EnvironmentsFile}
+	devEnv, err := source.GetDevEnvironment()
+	if err != nil {
+		return errors.Wrapf(err, "failed to load the Environments file %s", o.EnvironmentsFile)
+	}
+	if devEnv == nil {
+		return errors.Errorf("no Environment of kind %s in file %s", v1.EnvironmentKindTypeDevelopment, o.EnvironmentsFile)
+	}
+	o.EnvSource = source
+	if o.DevEnvContext.DevEnv == nil {
+		o.DevEnvContext.DevEnv = devEnv
+	}
+	if o.Namespace == "" {
+		o.Namespace = devEnv.Spec.Namespace
+	}
+	return nil
+}
+
+// GetEnvironments returns the Environments indexed by name along with the names in promotion order.
+// Defaults to the Environment resources in the cluster
+func (o *Options) GetEnvironments() (map[string]*v1.Environment, []string, error) {
+	if o.EnvSource == nil {
+		o.EnvSource = &envsource.CRDSource{
+			KubeClient: o.KubeClient,
+			JXClient:   o.JXClient,
+			Namespace:  o.Namespace,
+		}
+	}
+	return o.EnvSource.GetEnvironments()
+}
+
 // Run implements this command
 func (o *Options) Run() error {
 	err := o.Validate()
@@ -316,20 +379,9 @@ func (o *Options) Run() error {
 	}
 
 	ns := o.Namespace
-	if ns == "" {
-		return errors.Errorf("no namespace defined")
-	}
-	jxClient := o.JXClient
-	err = o.DevEnvContext.LazyLoad(o.JXClient, o.Namespace, o.Git())
+	err = o.initEnvironmentContext()
 	if err != nil {
-		return errors.Wrap(err, "failed to lazy load the EnvironmentContext")
-	}
-
-	if kube.IsInCluster() && !o.DisableGitConfig {
-		err = o.InitGitConfigAndUser()
-		if err != nil {
-			return errors.Wrapf(err, "failed to init git")
-		}
+		return err
 	}
 
 	prow := true
@@ -340,28 +392,9 @@ func (o *Options) Run() error {
 		o.NoWaitForUpdatePipeline = true
 	}
 
-	if o.HelmRepositoryURL == "" {
-		o.HelmRepositoryURL, err = o.ResolveChartRepositoryURL()
-		if err != nil {
-			return errors.Wrapf(err, "failed to resolve helm repository URL")
-		}
-	}
-	if o.Environment == "" && !o.BatchMode {
-		names := []string{}
-		m, allEnvNames, err := jxenv.GetOrderedEnvironments(jxClient, ns)
-		if err != nil {
-			return err
-		}
-		for _, n := range allEnvNames {
-			env := m[n]
-			if env.Spec.Kind == v1.EnvironmentKindTypePermanent {
-				names = append(names, n)
-			}
-		}
-		o.Environment, err = o.Input.PickNameWithDefault(names, "Pick environment:", "", "please select an Environment name")
-		if err != nil {
-			return errors.Wrapf(err, "failed to pick an Environment name")
-		}
+	err = o.pickEnvironment()
+	if err != nil {
+		return err
 	}
 
 	if o.PullRequestPollTime != "" {
@@ -384,7 +417,9 @@ func (o *Options) Run() error {
 		return err
 	}
 
-	o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+	if o.JXClient != nil {
+		o.Activities = o.JXClient.JenkinsV1().PipelineActivities(ns)
+	}
 
 	releaseName := o.ReleaseName
 	if releaseName == "" {
@@ -407,10 +442,11 @@ func (o *Options) Run() error {
 		if o.Environment == "" {
 			return options.MissingOption(optionEnvironment)
 		}
-		env, err := jxClient.JenkinsV1().Environments(ns).Get(o.Environment, metav1.GetOptions{})
+		m, _, err := o.GetEnvironments()
 		if err != nil {
 			return err
 		}
+		env = m[o.Environment]
 		if env == nil {
 			return fmt.Errorf("Could not find an Environment called %s", o.Environment)
 		}
@@ -430,6 +466,90 @@ func (o *Options) Run() error {
 	return err
 }
 
+// initEnvironmentContext loads the context of the dev environment, configures git and resolves the helm repository
+// URL used by the commands which modify an environment git repository
+func (o *Options) initEnvironmentContext() error {
+	if o.Namespace == "" {
+		return errors.Errorf("no namespace defined")
+	}
+	err := o.DevEnvContext.LazyLoad(o.JXClient, o.Namespace, o.Git())
+	if err != nil {
+		return errors.Wrap(err, "failed to lazy load the EnvironmentContext")
+	}
+
+	if kube.IsInCluster() && !o.DisableGitConfig {
+		err = o.InitGitConfigAndUser()
+		if err != nil {
+			return errors.Wrapf(err, "failed to init git")
+		}
+	}
+
+	if o.HelmRepositoryURL == "" {
+		o.HelmRepositoryURL, err = o.ResolveChartRepositoryURL()
+		if err != nil {
+			return errors.Wrapf(err, "failed to resolve helm repository URL")
+		}
+	}
+	return nil
+}
+
+// pickEnvironment lets the user pick the permanent Environment if none is specified and not in batch mode
+func (o *Options) pickEnvironment() error {
+	if o.Environment != "" || o.BatchMode {
+		return nil
+	}
+	names := []string{}
+	m, allEnvNames, err := o.GetEnvironments()
+	if err != nil {
+		return err
+	}
+	for _, n := range allEnvNames {
+		env := m[n]
+		if env.Spec.Kind == v1.EnvironmentKindTypePermanent {
+			names = append(names, n)
+		}
+	}
+	o.Environment, err = o.Input.PickNameWithDefault(names, "Pick environment:", "", "please select an Environment name")
+	if err != nil {
+		return errors.Wrapf(err, "failed to pick an Environment name")
+	}
+	return nil
+}
+
+// getPermanentEnvironment returns the permanent Environment to modify picking it if none is specified. The action
+// describes what is being done to the app for the error if the Environment is not permanent
+func (o *Options) getPermanentEnvironment(action string) (*v1.Environment, error) {
+	err := o.pickEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	if o.Environment == "" {
+		return nil, options.MissingOption(optionEnvironment)
+	}
+	_, env, err := o.GetTargetNamespace(o.Namespace, o.Environment)
+	if err != nil {
+		return nil, err
+	}
+	if !env.Spec.Kind.IsPermanent() {
+		return nil, errors.Errorf("cannot %s Environment %s which is not a permanent Environment", action, env.Name)
+	}
+	source := o.defaultEnvironmentSource(env)
+	if source.URL == "" {
+		return nil, fmt.Errorf("no source repository URL available on environment %s", env.Name)
+	}
+	return env, nil
+}
+
+// defaultEnvironmentSource returns the source of the Environment defaulting to the git repository of the dev
+// environment as we are sharing the git repository across multiple namespaces
+func (o *Options) defaultEnvironmentSource(env *v1.Environment) *v1.EnvironmentRepository {
+	source := &env.Spec.Source
+	if source.URL == "" && !env.Spec.RemoteCluster && o.DevEnvContext.DevEnv != nil {
+		source.URL = o.DevEnvContext.DevEnv.Spec.Source.URL
+	}
+	return source
+}
+
 func Contains(a []string, x string) bool {
 	for _, n := range a {
 		if x == n {
@@ -494,26 +614,18 @@ func (o *Options) DefaultChartRepositoryURL() string {
 }
 
 func (o *Options) PromoteAll(pred func(*v1.Environment) bool) error {
-	kubeClient := o.KubeClient
-	currentNs := o.Namespace
-	team, _, err := jxenv.GetDevNamespace(kubeClient, currentNs)
-	if err != nil {
-		return err
-	}
-	jxClient := o.JXClient
-	envs, err := jxClient.JenkinsV1().Environments(team).List(metav1.ListOptions{})
+	m, names, err := o.GetEnvironments()
 	if err != nil {
 		log.Logger().Warnf("No Environments found: %s/n", err)
 		return nil
 	}
-	environments := envs.Items
-	if len(environments) == 0 {
-		log.Logger().Warnf("No Environments have been created yet in team %s. Please create some via 'jx create env'", team)
+	if len(names) == 0 {
+		log.Logger().Warnf("No Environments have been created yet in namespace %s. Please create some via 'jx create env'", o.Namespace)
 		return nil
 	}
-	jxenv.SortEnvironments(environments)
 
-	for _, env := range environments {
+	for _, name := range names {
+		env := *m[name]
 		if pred(&env) {
 			ns := env.Spec.Namespace
 			if ns == "" {
@@ -581,14 +693,15 @@ func (o *Options) Promote(targetNS string, env *v1.Environment, warnIfAuto bool)
 		if !env.Spec.Kind.IsPermanent() {
 			return nil, errors.Errorf("cannot promote to Environment which is not a permanent Environment")
 		}
-		source := &env.Spec.Source
-		if source.URL == "" && !env.Spec.RemoteCluster && o.DevEnvContext.DevEnv != nil {
-			// lets default to the git repository of the dev environment as we are sharing the git repository across multiple namespaces
-			source.URL = o.DevEnvContext.DevEnv.Spec.Source.URL
-		}
-
+		source := o.defaultEnvironmentSource(env)
 		if source.URL != "" {
 			err := o.PromoteViaPullRequest(env, releaseInfo)
+			if err != nil && errors.Cause(err) == errFreezeSkipped {
+				return releaseInfo, nil
+			}
+			if err == nil && o.DryRun {
+				return releaseInfo, nil
+			}
 			if err == nil {
 				startPromotePR := func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromotePullRequestStep) error {
 					activities.StartPromotionPullRequest(a, s, ps, p)
@@ -605,6 +718,18 @@ func (o *Options) Promote(targetNS string, env *v1.Environment, warnIfAuto bool)
 				if err != nil {
 					log.Logger().Warnf("Failed to update PipelineActivity: %s", err)
 				}
+				if releaseInfo.FreezeOverride != "" {
+					recordErr := o.recordStage(promoteKey, "Freeze override "+env.Name, v1.ActivityStatusTypeSucceeded, releaseInfo.FreezeOverride)
+					if recordErr != nil {
+						log.Logger().Warnf("Failed to record the freeze override in the PipelineActivity: %s", recordErr)
+					}
+				}
+				if releaseInfo.PolicyOverride != "" {
+					recordErr := o.recordStage(promoteKey, "Policy override "+env.Name, v1.ActivityStatusTypeSucceeded, releaseInfo.PolicyOverride)
+					if recordErr != nil {
+						log.Logger().Warnf("Failed to record the policy override in the PipelineActivity: %s", recordErr)
+					}
+				}
 				// lets sleep a little before we try poll for the PR status
 				time.Sleep(waitAfterPullRequestCreated)
 			}
@@ -620,6 +745,14 @@ func (o *Options) ResolveChartRepositoryURL() (string, error) {
 	kubeClient := o.KubeClient
 	jxClient := o.JXClient
 	ns := o.Namespace
+	if kubeClient == nil {
+		// lets use the requirements of the dev environment when not connected to a cluster
+		requirements := o.DevEnvContext.Requirements
+		if requirements != nil && requirements.Cluster.ChartRepository != "" {
+			return requirements.Cluster.ChartRepository, nil
+		}
+		return "", errors.Errorf("no chart repository defined. Please specify it via --helm-repo-url")
+	}
 	answer, err := services.FindServiceURL(kubeClient, ns, kube.ServiceChartMuseum)
 	if err != nil && apierrors.IsNotFound(err) {
 		err = nil
@@ -658,22 +791,12 @@ func (o *Options) ResolveChartRepositoryURL() (string, error) {
 func (o *Options) GetTargetNamespace(ns string, env string) (string, *v1.Environment, error) {
 	kubeClient := o.KubeClient
 	currentNs := o.Namespace
-	team, _, err := jxenv.GetDevNamespace(kubeClient, currentNs)
-	if err != nil {
-		return "", nil, err
-	}
-
-	jxClient := o.JXClient
-	if err != nil {
-		return "", nil, err
-	}
-
-	m, envNames, err := jxenv.GetEnvironments(jxClient, team)
+	m, envNames, err := o.GetEnvironments()
 	if err != nil {
 		return "", nil, err
 	}
 	if len(envNames) == 0 {
-		return "", nil, fmt.Errorf("No Environments have been created yet in team %s. Please create some via 'jx create env'", team)
+		return "", nil, fmt.Errorf("No Environments have been created yet in namespace %s. Please create some via 'jx create env'", currentNs)
 	}
 
 	var envResource *v1.Environment
@@ -691,11 +814,13 @@ func (o *Options) GetTargetNamespace(ns string, env string) (string, *v1.Environ
 		targetNS = ns
 	}
 
-	labels := map[string]string{}
-	annotations := map[string]string{}
-	err = jxenv.EnsureNamespaceCreated(kubeClient, targetNS, labels, annotations)
-	if err != nil {
-		return "", nil, err
+	if kubeClient != nil {
+		labels := map[string]string{}
+		annotations := map[string]string{}
+		err = jxenv.EnsureNamespaceCreated(kubeClient, targetNS, labels, annotations)
+		if err != nil {
+			return "", nil, err
+		}
 	}
 	return targetNS, envResource, nil
 }
@@ -709,6 +834,9 @@ func (o *Options) WaitForPromotion(ns string, env *v1.Environment, releaseInfo *
 		log.Logger().Infof("No --%s option specified on the 'jx alpha promote' command so not waiting for the promotion to succeed", optionPullRequestPollTime)
 		return nil
 	}
+	if o.JXClient == nil {
+		log.Logger().Infof("not connected to a cluster so the promotion will not be recorded in a PipelineActivity")
+	}
 	duration := *o.TimeoutDuration
 	end := time.Now().Add(duration)
 
@@ -738,15 +866,12 @@ func (o *Options) waitForGitOpsPullRequest(ns string, env *v1.Environment, relea
 	logNoMergeStatuses := false
 	urlStatusMap := map[string]scm.State{}
 	urlStatusTargetURLMap := map[string]string{}
+	approvalState := ""
 
+	// the clients are nil when not connected to a cluster in which case the promoteKey is not valid and
+	// no PipelineActivity is updated
 	jxClient := o.JXClient
-	if jxClient == nil {
-		return errors.Errorf("no jx client")
-	}
 	kubeClient := o.KubeClient
-	if kubeClient == nil {
-		return errors.Errorf("no kube client")
-	}
 
 	scmClient := o.ScmClient
 	if scmClient == nil {
@@ -893,7 +1018,7 @@ func (o *Options) waitForGitOpsPullRequest(ns string, env *v1.Environment, relea
 						log.Logger().Info("The build for the Pull Request last commit is currently in progress.")
 					} else {
 						if status.State == scm.StateSuccess {
-							if !(o.NoMergePullRequest) {
+							if !o.NoMergePullRequest && o.approvalGateSatisfied(ctx, scmClient, env, pr, promoteKey, &approvalState) {
 								tideMerge := false
 								// Now check if tide is running or not
 								commitStatues, _, err := scmClient.Repositories.ListStatus(ctx, fullName, prLastCommitSha, scm.ListOptions{})
@@ -909,7 +1034,11 @@ func (o *Options) waitForGitOpsPullRequest(ns string, env *v1.Environment, relea
 								}
 								if !tideMerge {
 									prMergeOptions := &scm.PullRequestMergeOptions{
-										CommitTitle: "jx alpha promote automatically merged promotion PR",
+										CommitTitle: releaseInfo.MergeCommitTitle,
+										MergeMethod: releaseInfo.MergeMethod,
+									}
+									if prMergeOptions.CommitTitle == "" {
+										prMergeOptions.CommitTitle = defaultMergeCommitTitle
 									}
 									_, err = scmClient.PullRequests.Merge(ctx, fullName, prNumber, prMergeOptions)
 									// TODO
@@ -929,7 +1058,7 @@ func (o *Options) waitForGitOpsPullRequest(ns string, env *v1.Environment, relea
 						}
 					}
 				}
-				if !pr.Mergeable {
+				if !pr.Merged && !pr.Mergeable {
 					log.Logger().Info("Rebasing PullRequest due to conflict")
 
 					err = o.PromoteViaPullRequest(env, releaseInfo)
@@ -1033,6 +1162,12 @@ func (o *Options) Helm() helm.Helmer {
 }
 
 func (o *Options) CreatePromoteKey(env *v1.Environment) *activities.PromoteStepActivityKey {
+	if o.JXClient == nil {
+		// there are no PipelineActivity resources to update without a cluster
+		return &activities.PromoteStepActivityKey{
+			Environment: env.Name,
+		}
+	}
 	pipeline := o.Pipeline
 	if o.Build == "" {
 		o.Build = builds.GetBuildNumber()
@@ -1126,24 +1261,48 @@ func (o *Options) GetLatestPipelineBuildByCRD(pipeline string) (string, error) {
 	return "1", nil
 }
 
+// pipelineHasBranch returns true if the pipeline name ends with the app name and one of the branches
+func pipelineHasBranch(pipeline string, appName string, branches []string) bool {
+	for _, branch := range branches {
+		if strings.HasSuffix(pipeline, appName+"/"+branch) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetPipelineName return the pipeline name
 func (o *Options) GetPipelineName(gitInfo *giturl.GitRepository, pipeline string, build string, appName string) (string, string) {
 	if build == "" {
 		build = builds.GetBuildNumber()
 	}
+	defaultBranch := ""
+	if pipeline == "" {
+		var err error
+		defaultBranch, err = environments.RemoteDefaultBranch(o.Git(), ".")
+		if err != nil {
+			log.Logger().Debugf("Could not find the default branch: %s", err)
+		}
+	}
 	if gitInfo != nil && pipeline == "" {
 		// lets default the pipeline name from the Git repo
 		branch, err := gitclient.Branch(o.Git(), ".")
 		if err != nil {
 			log.Logger().Warnf("Could not find the branch name: %s", err)
 		}
+		if branch == "" || branch == "HEAD" {
+			branch = defaultBranch
+		}
 		if branch == "" {
 			branch = "master"
 		}
 		pipeline = stringhelpers.UrlJoin(gitInfo.Organisation, gitInfo.Name, branch)
 	}
 	if pipeline == "" && appName != "" {
-		suffix := appName + "/master"
+		branches := []string{defaultBranch}
+		if defaultBranch == "" {
+			branches = []string{"master", "main"}
+		}
 
 		// lets try deduce the pipeline name via the app name
 		jxClient := o.JXClient
@@ -1152,7 +1311,7 @@ func (o *Options) GetPipelineName(gitInfo *giturl.GitRepository, pipeline string
 		if err == nil {
 			for _, pipelineResource := range pipelineList.Items {
 				pipelineName := pipelineResource.Spec.Pipeline
-				if strings.HasSuffix(pipelineName, suffix) {
+				if pipelineHasBranch(pipelineName, appName, branches) {
 					pipeline = pipelineName
 					break
 				}
@@ -1217,6 +1376,10 @@ func (o *Options) CommentOnIssues(targetNS string, environment *v1.Environment,
 		log.Logger().Warnf("No GitInfo discovered so cannot comment on issues that they are now in %s", envName)
 		return nil
 	}
+	if o.JXClient == nil || o.KubeClient == nil {
+		log.Logger().Infof("not connected to a cluster so cannot comment on issues that they are now in %s", envName)
+		return nil
+	}
 
 	var err error
 	releaseName := naming.ToValidNameWithDots(app + "-" + version)
@@ -5,12 +5,16 @@ package promote_test
 import (
 	"sort"
 	"testing"
+	"time"
 
+	"github.com/jenkins-x/go-scm/scm"
+	scmfake "github.com/jenkins-x/go-scm/scm/driver/fake"
 	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
 	"github.com/jenkins-x/jx-helpers/pkg/input/fake"
 	"github.com/jenkins-x/jx-helpers/pkg/testhelpers"
 	"github.com/jenkins-x/jx-promote/pkg/promote"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -221,3 +225,44 @@ func TestGetEnvChartValues(t *testing.T) {
 		assert.Equal(t, valueStrings, test.valueStrings)
 	}
 }
+
+func TestWaitForPromotionWithoutCluster(t *testing.T) {
+	scmClient, data := scmfake.NewDefault()
+	pr := &scm.PullRequest{
+		Number:    1,
+		Link:      "https://github.com/myorg/environment-staging/pull/1",
+		Mergeable: true,
+		MergeSha:  "def456",
+		Head: scm.PullRequestBranch{
+			Sha: "abc123",
+		},
+		Base: scm.PullRequestBranch{
+			Repo: scm.Repository{
+				Namespace: "myorg",
+				Name:      "environment-staging",
+				FullName:  "myorg/environment-staging",
+			},
+		},
+	}
+	data.PullRequests[1] = pr
+	data.Statuses["abc123"] = []*scm.Status{{Label: "pr-build", State: scm.StateSuccess}}
+	data.Statuses["def456"] = []*scm.Status{{Label: "release", State: scm.StateSuccess, Target: "https://example.com/release"}}
+
+	env := createPermanentEnvironment("staging", "jx-staging", "")
+	env.Spec.PromotionStrategy = v1.PromotionStrategyTypeAutomatic
+
+	timeout := 10 * time.Second
+	poll := 10 * time.Millisecond
+	o := &promote.Options{
+		Application:             "myapp",
+		Version:                 "1.2.3",
+		TimeoutDuration:         &timeout,
+		PullRequestPollDuration: &poll,
+	}
+	o.ScmClient = scmClient
+	releaseInfo := &promote.ReleaseInfo{PullRequestInfo: pr}
+
+	err := o.WaitForPromotion("jx", env, releaseInfo)
+	require.NoError(t, err, "failed to wait for the promotion")
+	assert.True(t, pr.Merged, "the Pull Request should be merged")
+}
@@ -0,0 +1,249 @@
+package promote
+
+import (
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/jenkins-x/go-scm/scm"
+	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/pkg/options"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x/jx-promote/pkg/environments"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/pkg/errors"
+)
+
+const (
+	// MergeMethodMerge merges the Pull Request with a merge commit
+	MergeMethodMerge = "merge"
+
+	// MergeMethodSquash squashes the commits of the Pull Request into a single commit
+	MergeMethodSquash = "squash"
+
+	// MergeMethodRebase rebases the commits of the Pull Request onto the base branch
+	MergeMethodRebase = "rebase"
+
+	// AnnotationExistingPullRequests the annotation on an Environment for what to do with existing open promotion
+	// Pull Requests of the app. Either `Create`, `Reuse` or `Supersede`
+	AnnotationExistingPullRequests = "promote.jenkins-x.io/existing-pull-requests"
+
+	optionMergeMethod          = "merge-method"
+	optionExistingPullRequests = "existing-pull-requests"
+
+	// defaultMergeCommitTitle the title of the merge commit if there is no template
+	defaultMergeCommitTitle = "jx alpha promote automatically merged promotion PR"
+
+	// defaultExistingBranchTemplate the branch template used to find the existing Pull Requests of an app if there
+	// is no branch template
+	defaultExistingBranchTemplate = "promote/{{.Environment}}/{{.AppName}}/{{.Version}}"
+
+	// branchVersionMarker a placeholder version used to find where the version is in the branch name
+	branchVersionMarker = "JX_PROMOTE_VERSION_MARKER"
+)
+
+// PullRequestTemplateContext the expressions used in the templates of the promotion Pull Request and commit
+type PullRequestTemplateContext struct {
+	rules.TemplateContext
+
+	// Environment the name of the Environment
+	Environment string
+
+	// EnvironmentLabel the label of the Environment
+	EnvironmentLabel string
+
+	// EnvironmentNamespace the namespace of the Environment
+	EnvironmentNamespace string
+
+	// PreviousVersion the version of the app currently declared in the Environment or blank if it is a new app
+	PreviousVersion string
+
+	// Title the Pull Request title which is useful in the body and commit message templates
+	Title string
+
+	// User the git user promoting the app
+	User string
+}
+
+// applyPullRequestTemplates evaluates the Pull Request templates of the environment git repository, if any, to
+// create the title, body, branch and commit message of the promotion along with how the Pull Request is merged
+func (o *Options) applyPullRequestTemplates(env *v1.Environment, rule rules.Rule, r *rules.PromoteRule, details *scm.PullRequest, releaseInfo *ReleaseInfo) error {
+	spec := r.Config.Spec.PullRequest
+	mergeMethod := o.MergeMethod
+	if mergeMethod != "" {
+		if !isMergeMethod(mergeMethod) {
+			return options.InvalidOptionf(optionMergeMethod, mergeMethod, "supported values are %s, %s or %s", MergeMethodMerge, MergeMethodSquash, MergeMethodRebase)
+		}
+	} else if spec != nil {
+		mergeMethod = spec.MergeMethod
+		if !isMergeMethod(mergeMethod) {
+			return errors.Errorf("unsupported mergeMethod %s: supported values are %s, %s or %s", mergeMethod, MergeMethodMerge, MergeMethodSquash, MergeMethodRebase)
+		}
+	}
+	releaseInfo.MergeMethod = mergeMethod
+	releaseInfo.MergeCommitTitle = defaultMergeCommitTitle
+
+	existingAction, err := o.GetExistingPullRequestAction(env)
+	if err != nil {
+		return err
+	}
+	o.EnvironmentPullRequestOptions.ExistingAction = existingAction
+	if spec == nil {
+		if existingAction == environments.ExistingPullRequestCreate {
+			if mergeMethod == MergeMethodSquash {
+				releaseInfo.MergeCommitTitle = details.Title
+			}
+			return nil
+		}
+		spec = &v1alpha1.PullRequestSpec{}
+	}
+
+	current, err := rule.Read(r)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the current version of app %s", r.AppName)
+	}
+	ctx := &PullRequestTemplateContext{
+		TemplateContext:      r.TemplateContext,
+		Environment:          env.Name,
+		EnvironmentLabel:     env.Spec.Label,
+		EnvironmentNamespace: env.Spec.Namespace,
+		PreviousVersion:      current,
+		User:                 o.DevEnvContext.GitUsername,
+	}
+
+	title, err := evaluatePullRequestTemplate("title", spec.Title, ctx)
+	if err != nil {
+		return err
+	}
+	if title != "" {
+		details.Title = title
+		o.EnvironmentPullRequestOptions.CommitTitle = title
+	}
+	ctx.Title = details.Title
+
+	body, err := evaluatePullRequestTemplate("body", spec.Body, ctx)
+	if err != nil {
+		return err
+	}
+	if body != "" {
+		details.Body = body
+		o.EnvironmentPullRequestOptions.PullRequestBody = body
+	}
+
+	commitMessage, err := evaluatePullRequestTemplate("commitMessage", spec.CommitMessage, ctx)
+	if err != nil {
+		return err
+	}
+	if commitMessage != "" {
+		o.EnvironmentPullRequestOptions.CommitMessage = commitMessage
+	}
+
+	branchTemplate := spec.Branch
+	if branchTemplate == "" && existingAction != environments.ExistingPullRequestCreate {
+		branchTemplate = defaultExistingBranchTemplate
+	}
+	branch, err := evaluatePullRequestTemplate("branch", branchTemplate, ctx)
+	if err != nil {
+		return err
+	}
+	if branch != "" {
+		if strings.ContainsAny(branch, " \t\n~^:?*[\\") {
+			return errors.Errorf("the branch template %s evaluated to an invalid branch name '%s'", branchTemplate, branch)
+		}
+		details.Source = branch
+		o.EnvironmentPullRequestOptions.BranchName = branch
+	}
+	if existingAction != environments.ExistingPullRequestCreate {
+		o.EnvironmentPullRequestOptions.ExistingBranches, err = existingBranchRegex(branchTemplate, ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	mergeCommitTitle, err := evaluatePullRequestTemplate("mergeCommitTitle", spec.MergeCommitTitle, ctx)
+	if err != nil {
+		return err
+	}
+	if mergeCommitTitle != "" {
+		releaseInfo.MergeCommitTitle = mergeCommitTitle
+	} else if mergeMethod == MergeMethodSquash {
+		releaseInfo.MergeCommitTitle = details.Title
+	}
+	return nil
+}
+
+// GetExistingPullRequestAction returns what to do with the existing open promotion Pull Requests of the app from the
+// options or the annotation on the Environment. Defaults to creating a new Pull Request
+func (o *Options) GetExistingPullRequestAction(env *v1.Environment) (string, error) {
+	action := o.ExistingPullRequests
+	if action != "" {
+		if !isExistingPullRequestAction(action) {
+			return "", options.InvalidOptionf(optionExistingPullRequests, action, "supported values are %s, %s or %s", environments.ExistingPullRequestCreate, environments.ExistingPullRequestReuse, environments.ExistingPullRequestSupersede)
+		}
+		return action, nil
+	}
+	action = env.Annotations[AnnotationExistingPullRequests]
+	if action == "" {
+		return environments.ExistingPullRequestCreate, nil
+	}
+	if !isExistingPullRequestAction(action) {
+		return "", errors.Errorf("invalid %s annotation '%s' on environment %s: supported values are %s, %s or %s", AnnotationExistingPullRequests, action, env.Name, environments.ExistingPullRequestCreate, environments.ExistingPullRequestReuse, environments.ExistingPullRequestSupersede)
+	}
+	return action, nil
+}
+
+func isExistingPullRequestAction(action string) bool {
+	switch action {
+	case environments.ExistingPullRequestCreate, environments.ExistingPullRequestReuse, environments.ExistingPullRequestSupersede:
+		return true
+	default:
+		return false
+	}
+}
+
+// existingBranchRegex returns the regular expression matching the branches created by the branch template for any
+// version of the app
+func existingBranchRegex(branchTemplate string, ctx *PullRequestTemplateContext) (*regexp.Regexp, error) {
+	c := *ctx
+	c.Version = branchVersionMarker
+	text, err := evaluatePullRequestTemplate("branch", branchTemplate, &c)
+	if err != nil {
+		return nil, err
+	}
+	var parts []string
+	for _, part := range strings.Split(text, branchVersionMarker) {
+		parts = append(parts, regexp.QuoteMeta(part))
+	}
+	re, err := regexp.Compile("^" + strings.Join(parts, `[^/\s]+`) + "$")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create the regular expression for the branch template %s", branchTemplate)
+	}
+	return re, nil
+}
+
+// isMergeMethod returns true if the merge method is blank or supported
+func isMergeMethod(mergeMethod string) bool {
+	switch mergeMethod {
+	case "", MergeMethodMerge, MergeMethodSquash, MergeMethodRebase:
+		return true
+	default:
+		return false
+	}
+}
+
+// evaluatePullRequestTemplate evaluates the go template with the context returning the trimmed text
+func evaluatePullRequestTemplate(name string, templateText string, ctx *PullRequestTemplateContext) (string, error) {
+	if templateText == "" {
+		return "", nil
+	}
+	tmpl, err := template.New(name).Parse(templateText)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse the Pull Request %s template: %s", name, templateText)
+	}
+	buf := &strings.Builder{}
+	err = tmpl.Execute(buf, ctx)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to evaluate the Pull Request %s template: %s", name, templateText)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
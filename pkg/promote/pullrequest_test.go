@@ -0,0 +1,150 @@
+// +build unit
+
+package promote_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-helpers/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/versionstream"
+	"github.com/jenkins-x/jx-promote/pkg/environments"
+	"github.com/jenkins-x/jx-promote/pkg/jxtesthelpers"
+	"github.com/jenkins-x/jx-promote/pkg/promote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromotePullRequestTemplates(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	dir := createEnvironmentRepository(t, tmpDir, "production", `releases:
+- chart: dev/myapp
+  version: 1.2.3
+  name: myapp
+  namespace: jx-production
+`)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".jx"), files.DefaultDirWritePermissions))
+	err = ioutil.WriteFile(filepath.Join(dir, ".jx", "promote.yaml"), []byte(`apiVersion: promote.jenkins-x.io/v1alpha1
+kind: Promote
+metadata:
+  name: production
+spec:
+  helmfileRule:
+    path: helmfile.yaml
+    namespace: jx-production
+  pullRequest:
+    title: "chore(deps): promote {{.AppName}} to {{.Version}} in {{.Environment}}"
+    body: "Upgrades {{.AppName}} from {{.PreviousVersion}} to {{.Version}}"
+    branch: "promote/{{.Environment}}/{{.AppName}}"
+    commitMessage: "{{.Title}}"
+    mergeMethod: squash
+`), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save the promote config")
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "add pull request templates")
+
+	env := createPermanentEnvironment("production", "jx-production", dir)
+	for _, mergeMethod := range []string{"", "rebase", "fast-forward"} {
+		out := &bytes.Buffer{}
+		o := &promote.Options{}
+		o.DevEnvContext = *jxtesthelpers.CreateTestDevEnvironmentContext(t, "jx")
+		o.DevEnvContext.VersionResolver = &versionstream.VersionResolver{
+			VersionsDir: filepath.Join("test_data", "jenkins-x-versions"),
+		}
+		o.CommandRunner = cmdrunner.QuietCommandRunner
+		o.HelmRepositoryURL = "http://chartmuseum-jx.34.78.195.22.nip.io"
+		o.Application = "myapp"
+		o.Version = "1.2.4"
+		o.Namespace = "jx"
+		o.DryRun = true
+		o.Out = out
+		o.MergeMethod = mergeMethod
+
+		releaseInfo := &promote.ReleaseInfo{}
+		err = o.PromoteViaPullRequest(env, releaseInfo)
+		if mergeMethod == "fast-forward" {
+			require.Error(t, err, "expected an error for an unsupported merge method")
+			continue
+		}
+		require.NoError(t, err, "failed to promote")
+
+		text := out.String()
+		t.Logf("%s\n", text)
+		title := "chore(deps): promote myapp to 1.2.4 in production"
+		assert.Contains(t, text, "title:  "+title)
+		assert.Contains(t, text, "branch: promote/production/myapp")
+		assert.Contains(t, text, "body:\nUpgrades myapp from 1.2.3 to 1.2.4\n")
+		assert.Contains(t, text, "commit message:\n"+title+"\n")
+
+		if mergeMethod == "" {
+			assert.Equal(t, promote.MergeMethodSquash, releaseInfo.MergeMethod, "merge method from the template")
+			assert.Equal(t, title, releaseInfo.MergeCommitTitle, "squash merge commit title")
+		} else {
+			assert.Equal(t, mergeMethod, releaseInfo.MergeMethod, "merge method from the option")
+		}
+	}
+}
+
+func TestPromoteExistingPullRequests(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	dir := createEnvironmentRepository(t, tmpDir, "production", `releases:
+- chart: dev/myapp
+  version: 1.2.3
+  name: myapp
+  namespace: jx-production
+`)
+	env := createPermanentEnvironment("production", "jx-production", dir)
+	env.Annotations = map[string]string{
+		promote.AnnotationExistingPullRequests: environments.ExistingPullRequestSupersede,
+	}
+
+	for _, action := range []string{"", environments.ExistingPullRequestCreate, "Close"} {
+		out := &bytes.Buffer{}
+		o := &promote.Options{}
+		o.DevEnvContext = *jxtesthelpers.CreateTestDevEnvironmentContext(t, "jx")
+		o.DevEnvContext.VersionResolver = &versionstream.VersionResolver{
+			VersionsDir: filepath.Join("test_data", "jenkins-x-versions"),
+		}
+		o.CommandRunner = cmdrunner.QuietCommandRunner
+		o.HelmRepositoryURL = "http://chartmuseum-jx.34.78.195.22.nip.io"
+		o.Application = "myapp"
+		o.Version = "1.2.4"
+		o.Namespace = "jx-production"
+		o.DryRun = true
+		o.Out = out
+		o.ExistingPullRequests = action
+
+		err = o.PromoteViaPullRequest(env, &promote.ReleaseInfo{})
+		if action == "Close" {
+			require.Error(t, err, "expected an error for an unsupported action")
+			continue
+		}
+		require.NoError(t, err, "failed to promote")
+
+		text := out.String()
+		if action == environments.ExistingPullRequestCreate {
+			assert.Equal(t, environments.ExistingPullRequestCreate, o.ExistingAction, "action from the option")
+			assert.Nil(t, o.ExistingBranches, "existing Pull Requests should not be looked up")
+			assert.NotContains(t, text, "branch: promote/")
+			continue
+		}
+
+		assert.Equal(t, environments.ExistingPullRequestSupersede, o.ExistingAction, "action from the annotation")
+		assert.Contains(t, text, "branch: promote/production/myapp/1.2.4\n")
+		require.NotNil(t, o.ExistingBranches, "existing branches regex")
+		assert.True(t, o.ExistingBranches.MatchString("promote/production/myapp/1.2.3"), "should match an older version")
+		assert.True(t, o.ExistingBranches.MatchString("promote/production/myapp/1.2.4-rc.1"), "should match a prerelease")
+		assert.False(t, o.ExistingBranches.MatchString("promote/production/myapp-ui/1.2.3"), "should not match another app")
+		assert.False(t, o.ExistingBranches.MatchString("promote/staging/myapp/1.2.3"), "should not match another environment")
+	}
+}
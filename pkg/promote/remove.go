@@ -0,0 +1,49 @@
+package promote
+
+import (
+	"github.com/jenkins-x/jx-helpers/pkg/options"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// RunRemove creates a Pull Request to remove the application from an Environment
+func (o *Options) RunRemove() error {
+	err := o.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate options")
+	}
+
+	if !o.hasApplicationFlag() && o.hasArgs() {
+		o.setApplicationNameFromArgs()
+	}
+	if o.Application == "" {
+		return options.MissingOption(optionApplication)
+	}
+
+	err = o.initEnvironmentContext()
+	if err != nil {
+		return err
+	}
+	env, err := o.getPermanentEnvironment("remove an app from")
+	if err != nil {
+		return err
+	}
+
+	log.Logger().Infof("Removing app %s from environment %s", termcolor.ColorInfo(o.Application), termcolor.ColorInfo(env.Name))
+
+	releaseInfo := &ReleaseInfo{}
+	err = o.RemoveViaPullRequest(env, releaseInfo)
+	if errors.Cause(err) == errFreezeSkipped {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to create Pull Request to remove app %s from environment %s", o.Application, env.Name)
+	}
+	o.ReleaseInfo = releaseInfo
+	pr := releaseInfo.PullRequestInfo
+	if pr != nil && pr.Link != "" {
+		log.Logger().Infof("created Pull Request %s", termcolor.ColorInfo(pr.Link))
+	}
+	return nil
+}
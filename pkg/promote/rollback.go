@@ -0,0 +1,227 @@
+package promote
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/pkg/kube/activities"
+	"github.com/jenkins-x/jx-helpers/pkg/options"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RollbackOptions the options for rolling back an application in an Environment to a previous version
+type RollbackOptions struct {
+	Options
+
+	To        string
+	Steps     int
+	AutoMerge bool
+
+	// calculated fields
+	FromVersion string
+}
+
+// RunRollback creates a Pull Request to restore a previous version of the application in an Environment
+func (o *RollbackOptions) RunRollback() error {
+	if o.To != "" && o.Steps > 0 {
+		return errors.Errorf("cannot specify both --to and --steps")
+	}
+	if o.Steps < 0 {
+		return options.InvalidOptionf("steps", o.Steps, "must be a positive number")
+	}
+	if o.To == "" && o.Steps == 0 {
+		o.Steps = 1
+	}
+
+	err := o.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate options")
+	}
+
+	if !o.hasApplicationFlag() && o.hasArgs() {
+		o.setApplicationNameFromArgs()
+	}
+	if o.Application == "" {
+		return options.MissingOption(optionApplication)
+	}
+
+	err = o.initEnvironmentContext()
+	if err != nil {
+		return err
+	}
+	env, err := o.getPermanentEnvironment("rollback an app in")
+	if err != nil {
+		return err
+	}
+
+	releaseInfo := &ReleaseInfo{}
+	err = o.RollbackViaPullRequest(env, releaseInfo)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create Pull Request to rollback app %s in environment %s", o.Application, env.Name)
+	}
+	o.ReleaseInfo = releaseInfo
+	pr := releaseInfo.PullRequestInfo
+	if pr == nil {
+		return nil
+	}
+	log.Logger().Infof("created Pull Request %s to rollback app %s from version %s to %s", termcolor.ColorInfo(pr.Link),
+		termcolor.ColorInfo(o.Application), termcolor.ColorInfo(o.FromVersion), termcolor.ColorInfo(o.Version))
+
+	err = o.recordRollback(env, pr)
+	if err != nil {
+		log.Logger().Warnf("Failed to update PipelineActivity: %s", err)
+	}
+	return nil
+}
+
+// RollbackViaPullRequest creates a Pull Request on the environment git repository to restore a previous version of
+// the application found in the history of the repository. Freeze windows are not checked as a rollback restores a
+// version which was already released and is the remedy during an incident freeze
+func (o *RollbackOptions) RollbackViaPullRequest(env *v1.Environment, releaseInfo *ReleaseInfo) error {
+	app := o.Application
+
+	details := scm.PullRequest{}
+	return o.createPullRequest(env, &details, o.AutoMerge, releaseInfo, func(rule rules.Rule, r *rules.PromoteRule) error {
+		from, to, err := o.FindRollbackVersion(rule, r)
+		if err != nil {
+			return err
+		}
+		o.FromVersion = from
+		o.Version = to
+		r.Version = to
+		releaseInfo.Version = to
+
+		details.Source = fmt.Sprintf("rollback/%s/%s/%s", env.Name, app, to)
+		details.Title = "chore: rollback " + app + " to " + to
+		details.Body = fmt.Sprintf("chore: Rollback %s from version %s to %s in environment %s", app, from, to, env.Name)
+		o.EnvironmentPullRequestOptions.BranchName = details.Source
+		o.EnvironmentPullRequestOptions.CommitTitle = details.Title
+		o.EnvironmentPullRequestOptions.CommitMessage = details.Body
+
+		log.Logger().Infof("Rolling back app %s from version %s to %s in environment %s", termcolor.ColorInfo(app),
+			termcolor.ColorInfo(from), termcolor.ColorInfo(to), termcolor.ColorInfo(env.Name))
+		return rule.Apply(r)
+	})
+}
+
+// FindRollbackVersion returns the current version of the app and the version to rollback to by reading the version
+// declared in each commit of the environment git repository history
+func (o *RollbackOptions) FindRollbackVersion(rule rules.Rule, r *rules.PromoteRule) (string, string, error) {
+	dir := r.Dir
+	current, err := rule.Read(r)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to read the version of app %s in dir %s", r.AppName, dir)
+	}
+	if current == "" {
+		return "", "", errors.Errorf("app %s is not declared in the environment git repository", r.AppName)
+	}
+	if o.To == current {
+		return "", "", errors.Errorf("app %s is already at version %s", r.AppName, current)
+	}
+
+	gitter := o.Git()
+	text, err := gitter.Command(dir, "log", "--first-parent", "--format=%H")
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to list the commits in dir %s", dir)
+	}
+	shas := strings.Fields(text)
+
+	// lets read the older versions in a separate worktree so that the clone is left at the current commit
+	tmpDir, err := ioutil.TempDir("", "jx-promote-rollback-")
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(tmpDir)
+	worktree := filepath.Join(tmpDir, "history")
+	_, err = gitter.Command(dir, "worktree", "add", "--quiet", "--detach", worktree, "HEAD")
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to create a worktree of dir %s", dir)
+	}
+	defer func() {
+		_, err := gitter.Command(dir, "worktree", "remove", "--force", worktree)
+		if err != nil {
+			log.Logger().Warnf("failed to remove the worktree %s of dir %s: %s", worktree, dir, err.Error())
+		}
+	}()
+
+	historyRule := *r
+	historyRule.Dir = worktree
+	to, err := o.findPreviousVersion(rule, &historyRule, current, shas)
+	if err != nil {
+		return "", "", err
+	}
+	return current, to, nil
+}
+
+// findPreviousVersion checks out each of the older commits in the dir of the rule until the version to rollback to
+// is found
+func (o *RollbackOptions) findPreviousVersion(rule rules.Rule, r *rules.PromoteRule, current string, shas []string) (string, error) {
+	dir := r.Dir
+	versions := []string{current}
+	for i := 1; i < len(shas); i++ {
+		sha := shas[i]
+		_, err := o.Git().Command(dir, "checkout", "--quiet", sha)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to checkout commit %s in dir %s", sha, dir)
+		}
+		version, err := rule.Read(r)
+		if err != nil {
+			log.Logger().Debugf("could not read the version of app %s at commit %s: %s", r.AppName, sha, err.Error())
+			continue
+		}
+		if version == "" || version == versions[len(versions)-1] {
+			continue
+		}
+		versions = append(versions, version)
+		if o.To != "" && version == o.To {
+			return version, nil
+		}
+		if o.To == "" && len(versions) > o.Steps {
+			return versions[o.Steps], nil
+		}
+	}
+	if o.To != "" {
+		return "", errors.Errorf("version %s of app %s was not found in the history of the environment git repository", o.To, r.AppName)
+	}
+	return "", errors.Errorf("only found %d previous versions of app %s in the history of the environment git repository", len(versions)-1, r.AppName)
+}
+
+// recordRollback records the rollback as a step in the PipelineActivity
+func (o *RollbackOptions) recordRollback(env *v1.Environment, pr *scm.PullRequest) error {
+	promoteKey := o.CreatePromoteKey(env)
+	description := fmt.Sprintf("Rollback %s from version %s to %s via %s", o.Application, o.FromVersion, o.Version, pr.Link)
+	return o.recordStage(promoteKey, "Rollback "+env.Name, v1.ActivityStatusTypeSucceeded, description)
+}
+
+// recordStage records a stage with the status and description in the PipelineActivity
+func (o *Options) recordStage(promoteKey *activities.PromoteStepActivityKey, name string, status v1.ActivityStatusType, description string) error {
+	if !promoteKey.IsValid() {
+		return nil
+	}
+	a, _, err := promoteKey.GetOrCreate(o.JXClient, o.Namespace)
+	if err != nil {
+		return err
+	}
+	_, stage, _ := activities.GetOrCreateStage(a, name)
+	now := metav1.NewTime(time.Now())
+	if stage.StartedTimestamp == nil {
+		stage.StartedTimestamp = &now
+	}
+	if status == v1.ActivityStatusTypeSucceeded || status == v1.ActivityStatusTypeFailed {
+		stage.CompletedTimestamp = &now
+	}
+	stage.Status = status
+	stage.Description = description
+	_, err = o.JXClient.JenkinsV1().PipelineActivities(o.Namespace).PatchUpdate(a)
+	return err
+}
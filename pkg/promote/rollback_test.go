@@ -0,0 +1,105 @@
+// +build unit
+
+package promote_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-helpers/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-promote/pkg/jxtesthelpers"
+	"github.com/jenkins-x/jx-promote/pkg/promote"
+	"github.com/jenkins-x/jx-promote/pkg/promoteconfig"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/rules/factory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rollbackHelmfile = `releases:
+- chart: dev/myapp
+  version: %s
+  name: myapp
+  namespace: jx-production
+`
+
+func TestFindRollbackVersion(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	dir := createEnvironmentRepository(t, tmpDir, "production", fmt.Sprintf(rollbackHelmfile, "1.2.2"))
+	for i, version := range []string{"1.2.3", "1.2.3", "1.2.4"} {
+		err = ioutil.WriteFile(filepath.Join(dir, "helmfile.yaml"), []byte(fmt.Sprintf(rollbackHelmfile, version)), files.DefaultFileWritePermissions)
+		require.NoError(t, err, "failed to save the helmfile")
+		err = ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte(fmt.Sprintf("# commit %d\n", i)), files.DefaultFileWritePermissions)
+		require.NoError(t, err, "failed to save the README")
+		runGit(t, dir, "add", "-A")
+		runGit(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "promote "+version)
+	}
+	// lets detach the clone as there is no branch to checkout again in a detached clone
+	runGit(t, dir, "checkout", "--quiet", "--detach")
+
+	testCases := []struct {
+		to       string
+		steps    int
+		expected string
+		err      bool
+	}{
+		{steps: 1, expected: "1.2.3"},
+		{steps: 2, expected: "1.2.2"},
+		{steps: 3, err: true},
+		{to: "1.2.2", expected: "1.2.2"},
+		{to: "1.2.1", err: true},
+		{to: "1.2.4", err: true},
+	}
+	for _, tc := range testCases {
+		o := &promote.RollbackOptions{
+			To:    tc.to,
+			Steps: tc.steps,
+		}
+		o.CommandRunner = cmdrunner.QuietCommandRunner
+
+		cfg, _, err := promoteconfig.Discover(dir, "")
+		require.NoError(t, err, "failed to discover the config in dir %s", dir)
+		r := &rules.PromoteRule{
+			TemplateContext: rules.TemplateContext{
+				AppName:           "myapp",
+				Namespace:         "jx-production",
+				HelmRepositoryURL: "http://chartmuseum-jx.34.78.195.22.nip.io",
+			},
+			Dir:           dir,
+			Config:        *cfg,
+			DevEnvContext: jxtesthelpers.CreateTestDevEnvironmentContext(t, "jx"),
+		}
+		rule := factory.NewRule(r)
+		require.NotNil(t, rule, "no rule found in dir %s", dir)
+
+		from, to, err := o.FindRollbackVersion(rule, r)
+		if tc.err {
+			require.Error(t, err, "expected an error for --to %s --steps %d", tc.to, tc.steps)
+			t.Logf("got expected error for --to %s --steps %d: %s", tc.to, tc.steps, err.Error())
+		} else {
+			require.NoError(t, err, "failed to find the rollback version for --to %s --steps %d", tc.to, tc.steps)
+			assert.Equal(t, "1.2.4", from, "from version for --to %s --steps %d", tc.to, tc.steps)
+			assert.Equal(t, tc.expected, to, "rollback version for --to %s --steps %d", tc.to, tc.steps)
+		}
+
+		version, err := rule.Read(r)
+		require.NoError(t, err, "failed to read the version after finding the rollback version")
+		assert.Equal(t, "1.2.4", version, "the clone should be left at the current commit")
+	}
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	_, err := cmdrunner.QuietCommandRunner(&cmdrunner.Command{
+		Name: "git",
+		Args: args,
+		Dir:  dir,
+	})
+	require.NoError(t, err, "failed to run git %v in %s", args, dir)
+}
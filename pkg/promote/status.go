@@ -0,0 +1,276 @@
+package promote
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/pkg/table"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/environments"
+	"github.com/jenkins-x/jx-promote/pkg/promoteconfig"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/rules/factory"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// StatusOptions the options for viewing the versions of apps in each permanent Environment
+type StatusOptions struct {
+	Options
+
+	Applications []string
+	Output       string
+	Out          io.Writer
+}
+
+// Status the versions of the apps declared in each Environment
+type Status struct {
+	Environments []string     `json:"environments"`
+	Applications []AppVersion `json:"applications"`
+}
+
+// AppVersion the versions of an app indexed by Environment name
+type AppVersion struct {
+	Name     string            `json:"name"`
+	Versions map[string]string `json:"versions"`
+}
+
+// RunStatus displays the versions of the apps declared in the git repository of each permanent Environment
+func (o *StatusOptions) RunStatus() error {
+	switch o.Output {
+	case "", "json", "yaml":
+	default:
+		return errors.Errorf("unsupported output format %s: supported values are json or yaml", o.Output)
+	}
+	err := o.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate options")
+	}
+	ns := o.Namespace
+	if ns == "" {
+		return errors.Errorf("no namespace defined")
+	}
+	err = o.DevEnvContext.LazyLoad(o.JXClient, ns, o.Git())
+	if err != nil {
+		return errors.Wrap(err, "failed to lazy load the EnvironmentContext")
+	}
+	if o.HelmRepositoryURL == "" {
+		o.HelmRepositoryURL, err = o.ResolveChartRepositoryURL()
+		if err != nil {
+			return errors.Wrapf(err, "failed to resolve helm repository URL")
+		}
+	}
+
+	m, names, err := o.GetEnvironments()
+	if err != nil {
+		return errors.Wrapf(err, "failed to load the Environments in namespace %s", ns)
+	}
+	var envs []*v1.Environment
+	for _, name := range names {
+		env := m[name]
+		if env != nil && env.Spec.Kind.IsPermanent() {
+			envs = append(envs, env)
+		}
+	}
+
+	status, err := o.Status(envs)
+	if err != nil {
+		return err
+	}
+	return o.WriteStatus(status)
+}
+
+// Status clones the git repository of each Environment and reads the versions of the apps declared in it
+func (o *StatusOptions) Status(envs []*v1.Environment) (*Status, error) {
+	status := &Status{}
+	envRules := map[string]*environmentRule{}
+	dirs := map[string]string{}
+	defer func() {
+		for _, dir := range dirs {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	for _, env := range envs {
+		gitURL, sharedDevRepository := o.environmentGitURL(env)
+		if gitURL == "" {
+			log.Logger().Warnf("no source repository URL available on environment %s", env.Name)
+			continue
+		}
+
+		ref := env.Spec.Source.Ref
+		key := gitURL + "#" + ref
+		dir := dirs[key]
+		if dir == "" {
+			var err error
+			dir, _, err = environments.CloneEnvironment(o.Git(), gitURL, ref)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to clone environment %s URL %s", env.Name, gitURL)
+			}
+			dirs[key] = dir
+			log.Logger().Debugf("cloned %s to %s", termcolor.ColorInfo(gitURL), termcolor.ColorInfo(dir))
+		}
+
+		promoteNS := ""
+		if sharedDevRepository {
+			promoteNS = env.Spec.Namespace
+		}
+		er, err := o.environmentRule(env, dir, promoteNS)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the app versions of environment %s", env.Name)
+		}
+		status.Environments = append(status.Environments, env.Name)
+		envRules[env.Name] = er
+	}
+
+	apps := o.Applications
+	if len(apps) == 0 {
+		found := map[string]bool{}
+		for _, er := range envRules {
+			for app := range er.versions {
+				if !found[app] {
+					found[app] = true
+					apps = append(apps, app)
+				}
+			}
+		}
+		sort.Strings(apps)
+	}
+	for _, app := range apps {
+		av := AppVersion{
+			Name:     app,
+			Versions: map[string]string{},
+		}
+		for _, envName := range status.Environments {
+			version := envRules[envName].read(app)
+			if version != "" {
+				av.Versions[envName] = version
+			}
+		}
+		status.Applications = append(status.Applications, av)
+	}
+	return status, nil
+}
+
+// environmentGitURL returns the git URL of the Environment and whether its shared with the dev environment
+func (o *Options) environmentGitURL(env *v1.Environment) (string, bool) {
+	gitURL := env.Spec.Source.URL
+	devEnv := o.DevEnvContext.DevEnv
+	if devEnv != nil && !env.Spec.RemoteCluster && (gitURL == "" || gitURL == devEnv.Spec.Source.URL) {
+		// lets default to the git repository of the dev environment as we are sharing the git repository across multiple namespaces
+		return devEnv.Spec.Source.URL, true
+	}
+	return gitURL, false
+}
+
+// environmentRule the rule of an environment git clone along with the versions of the apps it can list
+type environmentRule struct {
+	env      *v1.Environment
+	rule     rules.Rule
+	r        *rules.PromoteRule
+	versions map[string]string
+}
+
+// environmentRule discovers the rule in the environment git clone and lists the apps declared if the rule supports it
+func (o *Options) environmentRule(env *v1.Environment, dir string, promoteNS string) (*environmentRule, error) {
+	promoteConfig, _, err := promoteconfig.Discover(dir, promoteNS)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to discover the PromoteConfig in dir %s", dir)
+	}
+	r := &rules.PromoteRule{
+		TemplateContext: rules.TemplateContext{
+			Namespace:         env.Spec.Namespace,
+			HelmRepositoryURL: o.HelmRepositoryURL,
+		},
+		Dir:           dir,
+		Config:        *promoteConfig,
+		DevEnvContext: &o.DevEnvContext,
+		CommandRunner: o.CommandRunner,
+	}
+	answer := &environmentRule{
+		env:      env,
+		rule:     factory.NewRule(r),
+		r:        r,
+		versions: map[string]string{},
+	}
+	if answer.rule == nil {
+		return answer, nil
+	}
+	err = answer.rule.Validate(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid promote rule in dir %s", dir)
+	}
+	if lister, ok := answer.rule.(rules.Lister); ok {
+		versions, err := lister.List(r)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list the apps in dir %s", dir)
+		}
+		for app, version := range versions {
+			answer.versions[app] = version
+		}
+	}
+	return answer, nil
+}
+
+// read returns the version of the app listed by the rule otherwise the version read by the rule. Apps the rules cannot
+// list, such as those in file rules, are only found if they are given as options or listed in another environment
+func (e *environmentRule) read(app string) string {
+	version := e.versions[app]
+	if version != "" || e.rule == nil {
+		return version
+	}
+	rc := *e.r
+	rc.AppName = app
+	version, err := e.rule.Read(&rc)
+	if err != nil {
+		log.Logger().Warnf("failed to read the version of app %s in environment %s: %s", app, e.env.Name, err.Error())
+		return ""
+	}
+	return version
+}
+
+// WriteStatus writes the status as a table or in the output format
+func (o *StatusOptions) WriteStatus(status *Status) error {
+	out := o.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	switch o.Output {
+	case "json":
+		data, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal status to JSON")
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	case "yaml":
+		data, err := yaml.Marshal(status)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal status to YAML")
+		}
+		_, err = out.Write(data)
+		return err
+	}
+
+	t := table.CreateTable(out)
+	header := []string{"APPLICATION"}
+	for _, envName := range status.Environments {
+		header = append(header, strings.ToUpper(envName))
+	}
+	t.AddRow(header...)
+	for _, app := range status.Applications {
+		row := []string{app.Name}
+		for _, envName := range status.Environments {
+			row = append(row, app.Versions[envName])
+		}
+		t.AddRow(row...)
+	}
+	t.Render()
+	return nil
+}
@@ -0,0 +1,122 @@
+// +build unit
+
+package promote_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1 "github.com/jenkins-x/jx-api/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-promote/pkg/jxtesthelpers"
+	"github.com/jenkins-x/jx-promote/pkg/promote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStatus(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	staging := createEnvironmentRepository(t, tmpDir, "staging", `releases:
+- chart: dev/myapp
+  version: 1.2.4
+  name: myapp
+  namespace: jx-staging
+- chart: dev/other
+  version: 0.0.1
+  name: other
+  namespace: jx-other
+`)
+	production := createEnvironmentRepository(t, tmpDir, "production", `releases:
+- chart: dev/myapp
+  version: 1.2.3
+  name: myapp
+  namespace: jx-production
+- chart: dev/another
+  version: 2.0.0
+  name: another
+  namespace: jx-production
+`)
+
+	ns := "jx"
+	o := &promote.StatusOptions{}
+	o.DevEnvContext = *jxtesthelpers.CreateTestDevEnvironmentContext(t, ns)
+	o.CommandRunner = cmdrunner.QuietCommandRunner
+	o.HelmRepositoryURL = "http://chartmuseum-jx.34.78.195.22.nip.io"
+
+	status, err := o.Status([]*v1.Environment{
+		createPermanentEnvironment("staging", "jx-staging", staging),
+		createPermanentEnvironment("production", "jx-production", production),
+	})
+	require.NoError(t, err, "failed to get the status")
+
+	assert.Equal(t, []string{"staging", "production"}, status.Environments, "environments")
+	assert.Equal(t, []promote.AppVersion{
+		{
+			Name:     "another",
+			Versions: map[string]string{"production": "2.0.0"},
+		},
+		{
+			Name:     "myapp",
+			Versions: map[string]string{"staging": "1.2.4", "production": "1.2.3"},
+		},
+	}, status.Applications, "applications")
+
+	buf := &bytes.Buffer{}
+	o.Out = buf
+	err = o.WriteStatus(status)
+	require.NoError(t, err, "failed to write the status")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3, "table rows: %s", buf.String())
+	assert.Equal(t, []string{"APPLICATION", "STAGING", "PRODUCTION"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"another", "2.0.0"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"myapp", "1.2.4", "1.2.3"}, strings.Fields(lines[2]))
+
+	buf.Reset()
+	o.Output = "json"
+	err = o.WriteStatus(status)
+	require.NoError(t, err, "failed to write the status as JSON")
+	actual := &promote.Status{}
+	err = json.Unmarshal(buf.Bytes(), actual)
+	require.NoError(t, err, "failed to parse the JSON %s", buf.String())
+	assert.Equal(t, status, actual, "status parsed from JSON")
+}
+
+func createPermanentEnvironment(name string, ns string, gitURL string) *v1.Environment {
+	return &v1.Environment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1.EnvironmentSpec{
+			Label:     strings.Title(name),
+			Namespace: ns,
+			Kind:      v1.EnvironmentKindTypePermanent,
+			Source: v1.EnvironmentRepository{
+				Kind: v1.EnvironmentRepositoryTypeGit,
+				URL:  gitURL,
+			},
+		},
+	}
+}
+
+// createEnvironmentRepository creates a git repository containing the helmfile
+func createEnvironmentRepository(t *testing.T, tmpDir string, name string, helmfile string) string {
+	dir := filepath.Join(tmpDir, name)
+	require.NoError(t, os.MkdirAll(dir, files.DefaultDirWritePermissions))
+	err := ioutil.WriteFile(filepath.Join(dir, "helmfile.yaml"), []byte(helmfile), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save the helmfile")
+
+	runGit(t, dir, "init", "--quiet")
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "initial import")
+	return dir
+}
@@ -15,7 +15,7 @@ import (
 // Discover discovers the promote configuration.
 //
 // if an explicit configuration is found (in a current or parent directory of '.jx/promote.yaml' then that is used.
-// otherwise the env/Chart.yaml or 'jx-apps.yaml' are detected
+// otherwise the env/Chart.yaml, 'jx-apps.yaml', 'helmfile.yaml' or 'kustomization.yaml' are detected
 func Discover(dir string, promoteNamespace string) (*v1alpha1.Promote, string, error) {
 	config, fileName, err := LoadPromote(dir, false)
 	if err != nil {
@@ -36,8 +36,10 @@ func Discover(dir string, promoteNamespace string) (*v1alpha1.Promote, string, e
 				Name: "generated",
 			},
 			Spec: v1alpha1.PromoteSpec{
-				HelmRule: &v1alpha1.HelmRule{
-					Path: "env",
+				RuleSpec: v1alpha1.RuleSpec{
+					HelmRule: &v1alpha1.HelmRule{
+						Path: "env",
+					},
 				},
 			},
 		}
@@ -54,9 +56,11 @@ func Discover(dir string, promoteNamespace string) (*v1alpha1.Promote, string, e
 				Name: "generated",
 			},
 			Spec: v1alpha1.PromoteSpec{
-				AppsRule: &v1alpha1.AppsRule{
-					Path:      "jx-apps.yml",
-					Namespace: promoteNamespace,
+				RuleSpec: v1alpha1.RuleSpec{
+					AppsRule: &v1alpha1.AppsRule{
+						Path:      "jx-apps.yml",
+						Namespace: promoteNamespace,
+					},
 				},
 			},
 		}
@@ -73,15 +77,38 @@ func Discover(dir string, promoteNamespace string) (*v1alpha1.Promote, string, e
 				Name: "generated",
 			},
 			Spec: v1alpha1.PromoteSpec{
-				HelmfileRule: &v1alpha1.HelmfileRule{
-					Path:      "helmfile.yaml",
-					Namespace: promoteNamespace,
+				RuleSpec: v1alpha1.RuleSpec{
+					HelmfileRule: &v1alpha1.HelmfileRule{
+						Path:      "helmfile.yaml",
+						Namespace: promoteNamespace,
+					},
 				},
 			},
 		}
 		return &config, "", nil
 	}
-	return nil, "", errors.Errorf("no '.jx/promote.yaml' file found and could not discover env/Chart.yaml in directory %s", dir)
+	kf := filepath.Join(dir, "kustomization.yaml")
+	exists, err = files.FileExists(kf)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to check if file exists %s", kf)
+	}
+	if exists {
+		config := v1alpha1.Promote{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "generated",
+			},
+			Spec: v1alpha1.PromoteSpec{
+				RuleSpec: v1alpha1.RuleSpec{
+					KustomizeRule: &v1alpha1.KustomizeRule{
+						Path:      "kustomization.yaml",
+						Namespace: promoteNamespace,
+					},
+				},
+			},
+		}
+		return &config, "", nil
+	}
+	return nil, "", errors.Errorf("no '.jx/promote.yaml' file found and could not discover env/Chart.yaml, jx-apps.yml, helmfile.yaml or kustomization.yaml in directory %s", dir)
 }
 
 // LoadPromote loads the boot config from the given directory
@@ -1,6 +1,8 @@
 package promoteconfig_test
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -52,3 +54,26 @@ func TestDiscoverPromoteConfigHelmfile(t *testing.T) {
 
 	t.Logf("discovered config %#v for dir %s", cfg, dir)
 }
+
+func TestDiscoverPromoteConfigKustomize(t *testing.T) {
+	dir := filepath.Join("test_data", "kustomize")
+	cfg, fileName, err := promoteconfig.Discover(dir, testPromoteNS)
+	require.NoError(t, err, "for dir %s", dir)
+	require.NotNil(t, cfg, "config not returned for %s", dir)
+	assert.Empty(t, fileName, "fileName for %s", dir)
+
+	assert.NotNil(t, cfg.Spec.KustomizeRule, "cfg.Spec.KustomizeRule for %s", dir)
+	assert.Equal(t, "kustomization.yaml", cfg.Spec.KustomizeRule.Path, "cfg.Spec.KustomizeRule.Path for %s", dir)
+
+	t.Logf("discovered config %#v for dir %s", cfg, dir)
+}
+
+func TestDiscoverPromoteConfigMissing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(dir)
+
+	_, _, err = promoteconfig.Discover(dir, testPromoteNS)
+	require.Error(t, err, "expected an error for %s", dir)
+	assert.Contains(t, err.Error(), "env/Chart.yaml, jx-apps.yml, helmfile.yaml or kustomization.yaml", "error for %s", dir)
+}
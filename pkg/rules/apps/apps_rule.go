@@ -1,9 +1,13 @@
 package apps
 
 import (
+	"github.com/jenkins-x/jx-apps/pkg/helmfile"
 	"github.com/jenkins-x/jx-apps/pkg/jxapps"
+	"github.com/jenkins-x/jx-logging/pkg/log"
 	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
 	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
 )
 
 // AppsRule uses a jx-apps.yml file
@@ -30,11 +34,26 @@ func modifyAppsFile(r *rules.PromoteRule, dir string, promoteNS string) error {
 	if err != nil {
 		return err
 	}
+	original := &jxapps.AppConfig{
+		Apps:         append([]jxapps.App(nil), appsConfig.Apps...),
+		Repositories: append([]helmfile.RepositorySpec(nil), appsConfig.Repositories...),
+	}
+
 	err = modifyApps(r, appsConfig, promoteNS)
 	if err != nil {
 		return err
 	}
 
+	// lets edit the file in place so that we retain comments and formatting
+	editor, err := yamlnodes.LoadEditor(fileName)
+	if err == nil {
+		err = editAppsFile(editor, original, appsConfig)
+	}
+	if err == nil {
+		return editor.SaveFile(fileName)
+	}
+	log.Logger().Warnf("could not edit file %s in place so rewriting it: %s", fileName, err.Error())
+
 	err = appsConfig.SaveConfig(fileName)
 	if err != nil {
 		return err
@@ -42,6 +61,58 @@ func modifyAppsFile(r *rules.PromoteRule, dir string, promoteNS string) error {
 	return nil
 }
 
+// editAppsFile applies the changes between the original and modified apps to the source of the file
+func editAppsFile(editor *yamlnodes.Editor, original *jxapps.AppConfig, modified *jxapps.AppConfig) error {
+	if len(editor.Docs) == 0 {
+		return errors.Errorf("no YAML documents")
+	}
+	root := yamlnodes.Root(editor.Docs[0])
+
+	appsNode := yamlnodes.GetMapValue(root, "apps")
+	for i := range original.Apps {
+		from := &original.Apps[i]
+		to := &modified.Apps[i]
+		if from.Version == to.Version && from.Namespace == to.Namespace {
+			continue
+		}
+		if appsNode == nil || appsNode.Kind != yaml.SequenceNode || len(appsNode.Content) != len(original.Apps) {
+			return errors.Errorf("the apps do not match the parsed file")
+		}
+		node := appsNode.Content[i]
+		if from.Version != to.Version {
+			err := editor.SetMapString(node, "version", to.Version)
+			if err != nil {
+				return err
+			}
+		}
+		if from.Namespace != to.Namespace {
+			err := editor.SetMapString(node, "namespace", to.Namespace)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var apps []*yaml.Node
+	for _, app := range modified.Apps[len(original.Apps):] {
+		apps = append(apps, yamlnodes.NewMap(
+			"name", app.Name,
+			"version", app.Version,
+			"namespace", app.Namespace,
+		))
+	}
+	err := editor.AppendItems(root, "apps", len(original.Apps), apps)
+	if err != nil {
+		return err
+	}
+
+	var repositories []*yaml.Node
+	for _, repo := range modified.Repositories[len(original.Repositories):] {
+		repositories = append(repositories, yamlnodes.NewMap("name", repo.Name, "url", repo.URL))
+	}
+	return editor.AppendItems(root, "repositories", len(original.Repositories), repositories)
+}
+
 func modifyApps(r *rules.PromoteRule, appsConfig *jxapps.AppConfig, promoteNS string) error {
 	if r.DevEnvContext == nil {
 		return errors.Errorf("no devEnvContext")
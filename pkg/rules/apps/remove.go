@@ -0,0 +1,112 @@
+package apps
+
+import (
+	"strings"
+
+	"github.com/jenkins-x/jx-apps/pkg/helmfile"
+	"github.com/jenkins-x/jx-apps/pkg/jxapps"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/pkg/errors"
+)
+
+// RemoveAppsRule removes the app from the jx-apps.yml file along with its repository if no other app uses it
+func RemoveAppsRule(r *rules.PromoteRule) error {
+	config := r.Config
+	if config.Spec.AppsRule == nil {
+		return errors.Errorf("no appsRule configured")
+	}
+	err := removeAppsFile(r, r.Dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove app from the apps file in dir %s", r.Dir)
+	}
+	return nil
+}
+
+func removeAppsFile(r *rules.PromoteRule, dir string) error {
+	appsConfig, fileName, err := jxapps.LoadAppConfig(dir)
+	if fileName == "" {
+		// if we don't have a `jx-apps.yml` then just return immediately
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if r.DevEnvContext == nil {
+		return errors.Errorf("no devEnvContext")
+	}
+	app := r.AppName
+	details, err := r.DevEnvContext.ChartDetails(app, r.HelmRepositoryURL)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get chart details for %s repo %s", app, r.HelmRepositoryURL)
+	}
+	// lets resolve the prefix without modifying the repositories
+	details.DefaultPrefix(&jxapps.AppConfig{Repositories: append([]helmfile.RepositorySpec(nil), appsConfig.Repositories...)}, "dev")
+
+	var removed []int
+	var apps []jxapps.App
+	removedPrefixes := map[string]bool{}
+	for i, appConfig := range appsConfig.Apps {
+		if appConfig.Name == app || appConfig.Name == details.Name {
+			removed = append(removed, i)
+			removedPrefixes[chartPrefix(appConfig.Name)] = true
+			continue
+		}
+		apps = append(apps, appConfig)
+	}
+	if len(removed) == 0 {
+		log.Logger().Infof("no app %s found in %s", app, termcolor.ColorInfo(fileName))
+		return nil
+	}
+	for _, appConfig := range apps {
+		delete(removedPrefixes, chartPrefix(appConfig.Name))
+	}
+
+	var repositories []int
+	var repos []helmfile.RepositorySpec
+	for i, repo := range appsConfig.Repositories {
+		if removedPrefixes[repo.Name] {
+			repositories = append(repositories, i)
+			continue
+		}
+		repos = append(repos, repo)
+	}
+
+	// lets edit the file in place so that we retain comments and formatting
+	editor, err := yamlnodes.LoadEditor(fileName)
+	if err == nil {
+		if len(editor.Docs) == 0 {
+			err = errors.Errorf("no YAML documents")
+		} else {
+			root := yamlnodes.Root(editor.Docs[0])
+			err = editor.DeleteSequenceItems(root, "apps", removed)
+			if err == nil {
+				err = editor.DeleteSequenceItems(root, "repositories", repositories)
+			}
+		}
+	}
+	if err == nil {
+		err = editor.SaveFile(fileName)
+		if err != nil {
+			return err
+		}
+		log.Logger().Infof("modified file %s", termcolor.ColorInfo(fileName))
+		return nil
+	}
+	log.Logger().Warnf("could not edit file %s in place so rewriting it: %s", fileName, err.Error())
+
+	appsConfig.Apps = apps
+	appsConfig.Repositories = repos
+	return appsConfig.SaveConfig(fileName)
+}
+
+// chartPrefix returns the repository prefix of the chart or an empty string if the chart is not from a repository
+func chartPrefix(chart string) string {
+	paths := strings.SplitN(chart, "/", 2)
+	if len(paths) < 2 {
+		return ""
+	}
+	return paths[0]
+}
@@ -0,0 +1,87 @@
+package apps
+
+import (
+	"strings"
+
+	"github.com/jenkins-x/jx-apps/pkg/helmfile"
+	"github.com/jenkins-x/jx-apps/pkg/jxapps"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/pkg/errors"
+)
+
+// Rule promotes apps in a jx-apps.yml file
+type Rule struct{}
+
+var _ rules.Rule = &Rule{}
+var _ rules.Lister = &Rule{}
+
+// Validate validates the appsRule
+func (*Rule) Validate(r *rules.PromoteRule) error {
+	if r.Config.Spec.AppsRule == nil {
+		return errors.Errorf("no appsRule configured")
+	}
+	return nil
+}
+
+// Read returns the version of the app in the jx-apps.yml file
+func (*Rule) Read(r *rules.PromoteRule) (string, error) {
+	if r.Config.Spec.AppsRule == nil {
+		return "", errors.Errorf("no appsRule configured")
+	}
+	appsConfig, fileName, err := jxapps.LoadAppConfig(r.Dir)
+	if fileName == "" {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if r.DevEnvContext == nil {
+		return "", errors.Errorf("no devEnvContext")
+	}
+	details, err := r.DevEnvContext.ChartDetails(r.AppName, r.HelmRepositoryURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get chart details for %s repo %s", r.AppName, r.HelmRepositoryURL)
+	}
+	// lets resolve the prefix without modifying the repositories
+	details.DefaultPrefix(&jxapps.AppConfig{Repositories: append([]helmfile.RepositorySpec(nil), appsConfig.Repositories...)}, "dev")
+
+	for _, appConfig := range appsConfig.Apps {
+		if appConfig.Name == r.AppName || appConfig.Name == details.Name {
+			return appConfig.Version, nil
+		}
+	}
+	return "", nil
+}
+
+// List returns the versions of the apps in the jx-apps.yml file
+func (*Rule) List(r *rules.PromoteRule) (map[string]string, error) {
+	if r.Config.Spec.AppsRule == nil {
+		return nil, errors.Errorf("no appsRule configured")
+	}
+	appsConfig, fileName, err := jxapps.LoadAppConfig(r.Dir)
+	if fileName == "" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	answer := map[string]string{}
+	for _, appConfig := range appsConfig.Apps {
+		name := appConfig.Name
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+		answer[name] = appConfig.Version
+	}
+	return answer, nil
+}
+
+// Apply promotes the version of the app
+func (*Rule) Apply(r *rules.PromoteRule) error {
+	return AppsRule(r)
+}
+
+// Remove removes the app
+func (*Rule) Remove(r *rules.PromoteRule) error {
+	return RemoveAppsRule(r)
+}
@@ -0,0 +1,347 @@
+package argocd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// APIGroup the API group of the Argo CD resources
+	APIGroup = "argoproj.io"
+
+	// DefaultServer the default destination server of new Applications
+	DefaultServer = "https://kubernetes.default.svc"
+
+	// DefaultProject the default project of new Applications
+	DefaultProject = "default"
+
+	// DefaultArgoCDNamespace the default namespace of new Applications
+	DefaultArgoCDNamespace = "argocd"
+)
+
+// ArgoCDRule modifies the Argo CD Application or ApplicationSet resources for the app
+func ArgoCDRule(r *rules.PromoteRule) error {
+	config := r.Config
+	if config.Spec.ArgoCDRule == nil {
+		return errors.Errorf("no argoCDRule configured")
+	}
+	rule := config.Spec.ArgoCDRule
+	if r.AppName == "" {
+		return errors.Errorf("no AppName so cannot promote via Argo CD")
+	}
+
+	dir := r.Dir
+	if rule.Path != "" {
+		dir = filepath.Join(dir, rule.Path)
+	}
+
+	selector, err := rules.EvaluateTemplate(r, rule.Selector)
+	if err != nil {
+		return errors.Wrapf(err, "failed to evaluate selector template")
+	}
+	matcher, err := createMatcher(r.AppName, selector)
+	if err != nil {
+		return err
+	}
+
+	found, err := modifyApplications(r, rule, dir, matcher)
+	if err != nil {
+		return errors.Wrapf(err, "failed to modify Argo CD resources in dir %s", dir)
+	}
+	if found {
+		return nil
+	}
+
+	err = createApplication(r, rule, dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create Argo CD Application in dir %s", dir)
+	}
+	return nil
+}
+
+// createMatcher creates a function which returns true if the resource is for the app
+func createMatcher(app string, selector string) (func(node *yaml.Node) bool, error) {
+	if selector == "" {
+		return func(node *yaml.Node) bool {
+			return yamlnodes.GetPathString(node, "metadata", "name") == app
+		}, nil
+	}
+	s, err := labels.Parse(selector)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse label selector %s", selector)
+	}
+	return func(node *yaml.Node) bool {
+		m := labels.Set{}
+		ls := yamlnodes.GetPath(node, "metadata", "labels")
+		if ls != nil && ls.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(ls.Content); i += 2 {
+				m[ls.Content[i].Value] = ls.Content[i+1].Value
+			}
+		}
+		return s.Matches(m)
+	}, nil
+}
+
+// modifyApplications modifies any matching resources in the YAML files in the dir returning true if any were found
+func modifyApplications(r *rules.PromoteRule, rule *v1alpha1.ArgoCDRule, dir string, matcher func(node *yaml.Node) bool) (bool, error) {
+	exists, err := files.DirExists(dir)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check if dir exists %s", dir)
+	}
+	if !exists {
+		return false, nil
+	}
+
+	found := false
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		docs, err := yamlnodes.LoadFile(path)
+		if err != nil {
+			log.Logger().Debugf("ignoring file %s as it could not be parsed: %s", path, err.Error())
+			return nil
+		}
+		modified := false
+		for _, doc := range docs {
+			node := yamlnodes.Root(doc)
+			spec := applicationSpec(node)
+			if spec == nil || !matcher(node) {
+				continue
+			}
+			err = modifyApplicationSpec(r, rule, spec)
+			if err != nil {
+				return errors.Wrapf(err, "failed to modify %s in file %s", yamlnodes.GetPathString(node, "metadata", "name"), path)
+			}
+			modified = true
+		}
+		if !modified {
+			return nil
+		}
+		found = true
+		err = yamlnodes.EditFile(path, docs)
+		if err != nil {
+			return err
+		}
+		log.Logger().Infof("modified file %s", termcolor.ColorInfo(path))
+		return nil
+	})
+	return found, err
+}
+
+// applicationSpec returns the Application spec of an Application or ApplicationSet or nil if the node is neither
+func applicationSpec(node *yaml.Node) *yaml.Node {
+	if !strings.HasPrefix(yamlnodes.GetMapString(node, "apiVersion"), APIGroup+"/") {
+		return nil
+	}
+	switch yamlnodes.GetMapString(node, "kind") {
+	case "Application":
+		return yamlnodes.GetMapValue(node, "spec")
+	case "ApplicationSet":
+		return yamlnodes.GetPath(node, "spec", "template", "spec")
+	default:
+		return nil
+	}
+}
+
+// modifyApplicationSpec sets the target revision of the source and any version parameter. For multiple sources only
+// the sources for the chart of the app are modified
+func modifyApplicationSpec(r *rules.PromoteRule, rule *v1alpha1.ArgoCDRule, spec *yaml.Node) error {
+	source := yamlnodes.GetMapValue(spec, "source")
+	if source != nil {
+		return modifySource(r, rule, source)
+	}
+	sources := yamlnodes.GetMapValue(spec, "sources")
+	if sources == nil || sources.Kind != yaml.SequenceNode {
+		return errors.Errorf("no spec.source or spec.sources found")
+	}
+	found := false
+	for _, s := range sources.Content {
+		if yamlnodes.GetMapString(s, "chart") == r.AppName {
+			err := modifySource(r, rule, s)
+			if err != nil {
+				return err
+			}
+			found = true
+		}
+	}
+	if !found {
+		return errors.Errorf("no spec.sources found for chart %s", r.AppName)
+	}
+	return nil
+}
+
+// modifySource sets the target revision of the source to the version for a helm chart source or to the revision
+// template for a git source along with any version parameter
+func modifySource(r *rules.PromoteRule, rule *v1alpha1.ArgoCDRule, source *yaml.Node) error {
+	if source.Kind != yaml.MappingNode {
+		return errors.Errorf("the source at line %d is not an object", source.Line)
+	}
+	revision := r.Version
+	if isGitSource(source) {
+		var err error
+		revision, err = gitRevision(r, rule)
+		if err != nil {
+			return err
+		}
+	}
+	yamlnodes.SetMapString(source, "targetRevision", revision)
+	if rule.VersionParameter == "" {
+		return nil
+	}
+	path, err := yamlnodes.ParsePath(fmt.Sprintf(".helm.parameters[name='%s'].value", rule.VersionParameter))
+	if err != nil {
+		return err
+	}
+	_, err = yamlnodes.SetPath(source, path, r.Version, true)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set helm parameter %s", rule.VersionParameter)
+	}
+	return nil
+}
+
+// isGitSource returns true if the source is a git repository rather than a helm chart
+func isGitSource(source *yaml.Node) bool {
+	return yamlnodes.GetMapString(source, "chart") == ""
+}
+
+// gitRevision returns the target revision of git sources from the revision template
+func gitRevision(r *rules.PromoteRule, rule *v1alpha1.ArgoCDRule) (string, error) {
+	if rule.RevisionTemplate == "" {
+		if r.Version == "" || strings.HasPrefix(r.Version, "v") {
+			return r.Version, nil
+		}
+		return "v" + r.Version, nil
+	}
+	revision, err := rules.EvaluateTemplate(r, rule.RevisionTemplate)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to evaluate revisionTemplate")
+	}
+	return revision, nil
+}
+
+// createApplication creates a new Application for the app from the template or chart details
+func createApplication(r *rules.PromoteRule, rule *v1alpha1.ArgoCDRule, dir string) error {
+	file := filepath.Join(dir, r.AppName+".yaml")
+	exists, err := files.FileExists(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", file)
+	}
+	if exists {
+		return errors.Errorf("cannot create Application as file %s already exists", file)
+	}
+
+	var data []byte
+	if rule.Template != "" {
+		data, err = applicationFromTemplate(r, rule, filepath.Join(r.Dir, rule.Template))
+	} else {
+		data, err = defaultApplication(r, rule)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", dir)
+	}
+	err = ioutil.WriteFile(file, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", file)
+	}
+	log.Logger().Infof("created file %s", termcolor.ColorInfo(file))
+	return nil
+}
+
+func evaluateTemplateFile(r *rules.PromoteRule, templateFile string) ([]byte, error) {
+	data, err := ioutil.ReadFile(templateFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read template file %s", templateFile)
+	}
+	text, err := rules.EvaluateTemplate(r, string(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to evaluate template file %s", templateFile)
+	}
+	return []byte(text), nil
+}
+
+// applicationFromTemplate evaluates the template file then sets the target revision of the sources of the resources
+// it contains so that git sources use the revision template
+func applicationFromTemplate(r *rules.PromoteRule, rule *v1alpha1.ArgoCDRule, templateFile string) ([]byte, error) {
+	data, err := evaluateTemplateFile(r, templateFile)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := yamlnodes.Parse(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the result of template file %s", templateFile)
+	}
+	for _, doc := range docs {
+		spec := applicationSpec(yamlnodes.Root(doc))
+		if spec == nil {
+			continue
+		}
+		err = modifyApplicationSpec(r, rule, spec)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to modify the result of template file %s", templateFile)
+		}
+	}
+	return yamlnodes.ToBytes(docs)
+}
+
+func defaultApplication(r *rules.PromoteRule, rule *v1alpha1.ArgoCDRule) ([]byte, error) {
+	if r.DevEnvContext == nil {
+		return nil, errors.Errorf("no devEnvContext")
+	}
+	details, err := r.DevEnvContext.ChartDetails(r.AppName, r.HelmRepositoryURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get chart details for %s repo %s", r.AppName, r.HelmRepositoryURL)
+	}
+	ns := rule.Namespace
+	if ns == "" {
+		ns = r.Namespace
+	}
+
+	node := yamlnodes.NewMap("apiVersion", APIGroup+"/v1alpha1", "kind", "Application")
+	yamlnodes.SetPathString(node, r.AppName, "metadata", "name")
+	yamlnodes.SetPathString(node, DefaultArgoCDNamespace, "metadata", "namespace")
+	yamlnodes.SetPathString(node, DefaultProject, "spec", "project")
+	source := yamlnodes.NewMap(
+		"repoURL", details.Repository,
+		"chart", details.LocalName,
+	)
+	spec := yamlnodes.GetMapValue(node, "spec")
+	spec.Content = append(spec.Content, yamlnodes.NewString("source"), source)
+	err = modifySource(r, rule, source)
+	if err != nil {
+		return nil, err
+	}
+	spec.Content = append(spec.Content, yamlnodes.NewString("destination"), yamlnodes.NewMap(
+		"server", DefaultServer,
+		"namespace", ns,
+	))
+	return yamlnodes.ToBytes([]*yaml.Node{node})
+}
@@ -0,0 +1,111 @@
+package argocd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// RemoveArgoCDRule removes the Argo CD Application or ApplicationSet resources for the app deleting any files
+// which become empty
+func RemoveArgoCDRule(r *rules.PromoteRule) error {
+	config := r.Config
+	if config.Spec.ArgoCDRule == nil {
+		return errors.Errorf("no argoCDRule configured")
+	}
+	rule := config.Spec.ArgoCDRule
+	if r.AppName == "" {
+		return errors.Errorf("no AppName so cannot remove via Argo CD")
+	}
+
+	dir := r.Dir
+	if rule.Path != "" {
+		dir = filepath.Join(dir, rule.Path)
+	}
+
+	selector, err := rules.EvaluateTemplate(r, rule.Selector)
+	if err != nil {
+		return errors.Wrapf(err, "failed to evaluate selector template")
+	}
+	matcher, err := createMatcher(r.AppName, selector)
+	if err != nil {
+		return err
+	}
+
+	found, err := removeApplications(dir, matcher)
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove Argo CD resources in dir %s", dir)
+	}
+	if !found {
+		log.Logger().Infof("no Argo CD resources found for app %s in %s", r.AppName, termcolor.ColorInfo(dir))
+	}
+	return nil
+}
+
+// removeApplications removes any matching resources in the YAML files in the dir returning true if any were found
+func removeApplications(dir string, matcher func(node *yaml.Node) bool) (bool, error) {
+	exists, err := files.DirExists(dir)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check if dir exists %s", dir)
+	}
+	if !exists {
+		return false, nil
+	}
+
+	found := false
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		docs, err := yamlnodes.LoadFile(path)
+		if err != nil {
+			log.Logger().Debugf("ignoring file %s as it could not be parsed: %s", path, err.Error())
+			return nil
+		}
+		var remaining []*yaml.Node
+		for _, doc := range docs {
+			node := yamlnodes.Root(doc)
+			if applicationSpec(node) != nil && matcher(node) {
+				continue
+			}
+			remaining = append(remaining, doc)
+		}
+		if len(remaining) == len(docs) {
+			return nil
+		}
+		found = true
+		if len(remaining) == 0 {
+			err = os.Remove(path)
+			if err != nil {
+				return errors.Wrapf(err, "failed to remove file %s", path)
+			}
+			log.Logger().Infof("removed file %s", termcolor.ColorInfo(path))
+			return nil
+		}
+		err = yamlnodes.SaveFile(path, remaining)
+		if err != nil {
+			return err
+		}
+		log.Logger().Infof("modified file %s", termcolor.ColorInfo(path))
+		return nil
+	})
+	return found, err
+}
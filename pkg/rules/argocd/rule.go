@@ -0,0 +1,152 @@
+package argocd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule promotes apps in Argo CD Application or ApplicationSet resources
+type Rule struct{}
+
+var _ rules.Rule = &Rule{}
+
+// Validate validates the argoCDRule and its selector
+func (*Rule) Validate(r *rules.PromoteRule) error {
+	rule := r.Config.Spec.ArgoCDRule
+	if rule == nil {
+		return errors.Errorf("no argoCDRule configured")
+	}
+	selector, err := rules.EvaluateTemplate(r, rule.Selector)
+	if err != nil {
+		return errors.Wrapf(err, "failed to evaluate selector template")
+	}
+	_, err = createMatcher(r.AppName, selector)
+	if err != nil {
+		return err
+	}
+	_, err = rules.EvaluateTemplate(r, rule.RevisionTemplate)
+	if err != nil {
+		return errors.Wrapf(err, "invalid revisionTemplate")
+	}
+	return nil
+}
+
+// Read returns the target revision of the first Application or ApplicationSet for the app
+func (*Rule) Read(r *rules.PromoteRule) (string, error) {
+	rule := r.Config.Spec.ArgoCDRule
+	if rule == nil {
+		return "", errors.Errorf("no argoCDRule configured")
+	}
+	dir := r.Dir
+	if rule.Path != "" {
+		dir = filepath.Join(dir, rule.Path)
+	}
+	selector, err := rules.EvaluateTemplate(r, rule.Selector)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to evaluate selector template")
+	}
+	matcher, err := createMatcher(r.AppName, selector)
+	if err != nil {
+		return "", err
+	}
+
+	exists, err := files.DirExists(dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to check if dir exists %s", dir)
+	}
+	if !exists {
+		return "", nil
+	}
+
+	version := ""
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || version != "" {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		docs, err := yamlnodes.LoadFile(path)
+		if err != nil {
+			log.Logger().Debugf("ignoring file %s as it could not be parsed: %s", path, err.Error())
+			return nil
+		}
+		for _, doc := range docs {
+			node := yamlnodes.Root(doc)
+			spec := applicationSpec(node)
+			if spec == nil || !matcher(node) {
+				continue
+			}
+			version, err = sourceVersion(r, rule, spec)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read %s in file %s", yamlnodes.GetPathString(node, "metadata", "name"), path)
+			}
+			if version != "" {
+				return nil
+			}
+		}
+		return nil
+	})
+	return version, err
+}
+
+// Apply promotes the version of the app
+func (*Rule) Apply(r *rules.PromoteRule) error {
+	return ArgoCDRule(r)
+}
+
+// Remove removes the app
+func (*Rule) Remove(r *rules.PromoteRule) error {
+	return RemoveArgoCDRule(r)
+}
+
+// sourceVersion returns the version of the source or of the source for the chart of the app
+func sourceVersion(r *rules.PromoteRule, rule *v1alpha1.ArgoCDRule, spec *yaml.Node) (string, error) {
+	source := yamlnodes.GetMapValue(spec, "source")
+	if source == nil {
+		sources := yamlnodes.GetMapValue(spec, "sources")
+		if sources == nil || sources.Kind != yaml.SequenceNode {
+			return "", nil
+		}
+		for _, s := range sources.Content {
+			if yamlnodes.GetMapString(s, "chart") == r.AppName {
+				source = s
+				break
+			}
+		}
+		if source == nil {
+			return "", nil
+		}
+	}
+	revision := yamlnodes.GetMapString(source, "targetRevision")
+	if !isGitSource(source) {
+		return revision, nil
+	}
+	if rule.RevisionTemplate == "" {
+		return strings.TrimPrefix(revision, "v"), nil
+	}
+	version, err := rules.ExtractVersion(r, rule.RevisionTemplate, revision)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find the version in revision %s", revision)
+	}
+	if version == "" {
+		return revision, nil
+	}
+	return version, nil
+}
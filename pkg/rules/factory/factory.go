@@ -1,31 +1,307 @@
 package factory
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
 	"github.com/jenkins-x/jx-promote/pkg/rules"
 	"github.com/jenkins-x/jx-promote/pkg/rules/apps"
+	"github.com/jenkins-x/jx-promote/pkg/rules/argocd"
 	"github.com/jenkins-x/jx-promote/pkg/rules/file"
+	"github.com/jenkins-x/jx-promote/pkg/rules/flux"
 	"github.com/jenkins-x/jx-promote/pkg/rules/helm"
 	"github.com/jenkins-x/jx-promote/pkg/rules/helmfile"
 	"github.com/jenkins-x/jx-promote/pkg/rules/kpt"
+	"github.com/jenkins-x/jx-promote/pkg/rules/kustomize"
+	"github.com/jenkins-x/jx-promote/pkg/rules/path"
+	"github.com/pkg/errors"
 )
 
-// NewFunction creates a function based on the kind of rule
-func NewFunction(r *rules.PromoteRule) rules.RuleFunction {
+// NewRule creates the rule based on the kind of rule configured or nil if no rule is configured. If there are
+// multiple rules they are evaluated in order
+func NewRule(r *rules.PromoteRule) rules.Rule {
 	spec := r.Config.Spec
+	if len(spec.Rules) == 0 && len(ruleKinds(&spec.RuleSpec)) <= 1 {
+		return newRuleSpecRule(&spec.RuleSpec)
+	}
+	// lets use a multiRule when more than one kind is configured inline so that Validate reports it
+	return &multiRule{
+		specs:  RuleSpecs(&spec),
+		inline: len(ruleKinds(&spec.RuleSpec)) > 0,
+	}
+}
+
+// RuleSpecs returns all the rules in the given spec in the order they should be evaluated
+func RuleSpecs(spec *v1alpha1.PromoteSpec) []v1alpha1.RuleSpec {
+	var answer []v1alpha1.RuleSpec
+	if len(ruleKinds(&spec.RuleSpec)) > 0 {
+		answer = append(answer, spec.RuleSpec)
+	}
+	return append(answer, spec.Rules...)
+}
+
+// ruleKinds returns the names of the kinds of rule configured in the spec
+func ruleKinds(spec *v1alpha1.RuleSpec) []string {
+	kinds := []struct {
+		name string
+		set  bool
+	}{
+		{"appsRule", spec.AppsRule != nil},
+		{"fileRule", spec.FileRule != nil},
+		{"helmRule", spec.HelmRule != nil},
+		{"helmfileRule", spec.HelmfileRule != nil},
+		{"kptRule", spec.KptRule != nil},
+		{"kustomizeRule", spec.KustomizeRule != nil},
+		{"pathRule", spec.PathRule != nil},
+		{"argoCDRule", spec.ArgoCDRule != nil},
+		{"fluxRule", spec.FluxRule != nil},
+	}
+	var answer []string
+	for _, k := range kinds {
+		if k.set {
+			answer = append(answer, k.name)
+		}
+	}
+	return answer
+}
+
+func newRuleSpecRule(spec *v1alpha1.RuleSpec) rules.Rule {
 	if spec.AppsRule != nil {
-		return apps.AppsRule
+		return &apps.Rule{}
 	}
 	if spec.FileRule != nil {
-		return file.FileRule
+		return &file.Rule{}
 	}
 	if spec.HelmRule != nil {
-		return helm.HelmRule
+		return &helm.Rule{}
 	}
 	if spec.HelmfileRule != nil {
-		return helmfile.HelmfileRule
+		return &helmfile.Rule{}
 	}
 	if spec.KptRule != nil {
-		return kpt.KptRule
+		return &kpt.Rule{}
+	}
+	if spec.KustomizeRule != nil {
+		return &kustomize.Rule{}
+	}
+	if spec.PathRule != nil {
+		return &path.Rule{}
+	}
+	if spec.ArgoCDRule != nil {
+		return &argocd.Rule{}
+	}
+	if spec.FluxRule != nil {
+		return &flux.Rule{}
+	}
+	return nil
+}
+
+// multiRule evaluates each of the rules in order against the same directory
+type multiRule struct {
+	specs  []v1alpha1.RuleSpec
+	inline bool
+}
+
+// name returns the name of the rule at the index for error messages
+func (m *multiRule) name(i int) string {
+	if m.inline {
+		if i == 0 {
+			return "spec"
+		}
+		i--
+	}
+	return fmt.Sprintf("rules[%d]", i)
+}
+
+// Validate validates all of the rules
+func (m *multiRule) Validate(r *rules.PromoteRule) error {
+	for i := range m.specs {
+		kinds := ruleKinds(&m.specs[i])
+		if len(kinds) > 1 {
+			return errors.Errorf("invalid %s: only one kind of rule can be configured but found %s", m.name(i), strings.Join(kinds, ", "))
+		}
+		rule, rc, err := specRule(r, &m.specs[i])
+		if err == nil {
+			err = rule.Validate(rc)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s", m.name(i))
+		}
+	}
+	return nil
+}
+
+// Read returns the version of the app from the first rule which declares the app
+func (m *multiRule) Read(r *rules.PromoteRule) (string, error) {
+	for i := range m.specs {
+		rule, rc, err := specRule(r, &m.specs[i])
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to evaluate %s", m.name(i))
+		}
+		version, err := rule.Read(rc)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read %s", m.name(i))
+		}
+		if version != "" {
+			return version, nil
+		}
+	}
+	return "", nil
+}
+
+// List returns the versions of the apps declared by the rules which can list their apps. If an app is declared by
+// more than one rule the first rule wins
+func (m *multiRule) List(r *rules.PromoteRule) (map[string]string, error) {
+	answer := map[string]string{}
+	for i := range m.specs {
+		rule, rc, err := specRule(r, &m.specs[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to evaluate %s", m.name(i))
+		}
+		lister, ok := rule.(rules.Lister)
+		if !ok {
+			continue
+		}
+		versions, err := lister.List(rc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list %s", m.name(i))
+		}
+		for app, version := range versions {
+			if _, ok := answer[app]; !ok {
+				answer[app] = version
+			}
+		}
+	}
+	return answer, nil
+}
+
+// Apply promotes the version of the app with each rule
+func (m *multiRule) Apply(r *rules.PromoteRule) error {
+	return m.evaluate(r, rules.Rule.Apply)
+}
+
+// Remove removes the app with each rule
+func (m *multiRule) Remove(r *rules.PromoteRule) error {
+	return m.evaluate(r, rules.Rule.Remove)
+}
+
+// evaluate invokes the function for each rule in order. If any rule fails the directory is restored so that we
+// never leave a half applied promotion behind
+func (m *multiRule) evaluate(r *rules.PromoteRule, fn func(rules.Rule, *rules.PromoteRule) error) error {
+	backupDir, err := ioutil.TempDir("", "jx-promote-rules-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(backupDir)
+
+	err = copyDirContents(r.Dir, backupDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to backup dir %s", r.Dir)
+	}
+
+	for i := range m.specs {
+		rule, rc, err := specRule(r, &m.specs[i])
+		if err == nil {
+			err = fn(rule, rc)
+			r.TemplateContext = rc.TemplateContext
+		}
+		if err != nil {
+			restoreErr := restoreDir(backupDir, r.Dir)
+			if restoreErr != nil {
+				log.Logger().Warnf("failed to restore dir %s after rule failure: %s", r.Dir, restoreErr.Error())
+			}
+			return errors.Wrapf(err, "failed to evaluate %s", m.name(i))
+		}
 	}
 	return nil
 }
+
+// specRule returns the rule for the spec along with a copy of the PromoteRule with its own copy of the config so
+// rules only see their own configuration
+func specRule(r *rules.PromoteRule, spec *v1alpha1.RuleSpec) (rules.Rule, *rules.PromoteRule, error) {
+	rule := newRuleSpecRule(spec)
+	if rule == nil {
+		return nil, nil, errors.Errorf("no rule kind configured")
+	}
+	rc := *r
+	rc.Config.Spec = v1alpha1.PromoteSpec{
+		RuleSpec: *spec,
+	}
+	return rule, &rc, nil
+}
+
+// restoreDir restores the dir from the backup, removing any files or directories which were created since the
+// backup was taken. The git metadata and symlinks are left as is
+func restoreDir(backupDir string, dir string) error {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+		_, err = os.Stat(filepath.Join(backupDir, rel))
+		if err == nil {
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		err = os.RemoveAll(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove %s", path)
+		}
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove new files from %s", dir)
+	}
+	return copyDirContents(backupDir, dir)
+}
+
+// copyDirContents copies the files in the source dir to the destination ignoring the git metadata and symlinks
+func copyDirContents(src string, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		dstPath := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(dstPath, info.Mode())
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+		err = files.CopyFile(path, dstPath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to copy %s to %s", path, dstPath)
+		}
+		return nil
+	})
+}
@@ -2,7 +2,9 @@ package factory_test
 
 import (
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/jenkins-x/jx-helpers/pkg/files"
@@ -58,42 +60,396 @@ func TestRuleFactory(t *testing.T) {
 				DevEnvContext: jxtesthelpers.CreateTestDevEnvironmentContext(t, ns),
 			}
 
-			fn := factory.NewFunction(r)
-			require.NotNil(t, fn, "failed to create RuleFunction at dir %s", dir)
+			rule := factory.NewRule(r)
+			require.NotNil(t, rule, "failed to create Rule at dir %s", dir)
 
-			err = fn(r)
-			require.NoError(t, err, "failed to invoke RuleFunction %v at dir %s", fn, dir)
+			err = rule.Validate(r)
+			require.NoError(t, err, "invalid Rule at dir %s", dir)
 
-			fileName := ruleFileName(cfg)
-			target := filepath.Join(dir, fileName)
-			assert.FileExists(t, target)
+			err = rule.Apply(r)
+			require.NoError(t, err, "failed to apply Rule %#v at dir %s", rule, dir)
+			assertReadVersion(t, rule, r, "1.2.3")
 
-			testhelpers.AssertTextFilesEqual(t, filepath.Join(src, fileName+".1.expected"), target, fileName)
+			fileNames := ruleFileNames(t, cfg, dir)
+			fileNames = appendExpectedFileNames(t, fileNames, src)
+			for _, fileName := range fileNames {
+				target := filepath.Join(dir, fileName)
+				assert.FileExists(t, target)
+
+				testhelpers.AssertTextFilesEqual(t, filepath.Join(src, fileName+".1.expected"), target, fileName)
+			}
 
 			// now lets modify to new version
 			r.TemplateContext.Version = "1.2.4"
 
-			err = fn(r)
-			require.NoError(t, err, "failed to run FileRule at dir %s", dir)
+			err = rule.Apply(r)
+			require.NoError(t, err, "failed to apply Rule at dir %s", dir)
+			assertReadVersion(t, rule, r, "1.2.4")
+
+			for _, fileName := range fileNames {
+				target := filepath.Join(dir, fileName)
+				testhelpers.AssertTextFilesEqual(t, filepath.Join(src, fileName+".2.expected"), target, fileName)
+			}
+
+			// now lets remove the app if we have expected results
+			removedFileNames := expectedFileNames(t, src, ".removed.expected")
+			if len(removedFileNames) == 0 {
+				continue
+			}
+			err = rule.Remove(r)
+			require.NoError(t, err, "failed to remove app at dir %s", dir)
+			assertReadVersion(t, rule, r, "")
+
+			for _, fileName := range removedFileNames {
+				target := filepath.Join(dir, fileName)
+				testhelpers.AssertTextFilesEqual(t, filepath.Join(src, fileName+".removed.expected"), target, fileName)
+			}
+		}
+	}
+}
+
+func TestRuleFactoryRulesRestoredOnFailure(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+
+	src := filepath.Join("test_data", "helmfile")
+	err = files.CopyDirOverwrite(src, tmpDir)
+	require.NoError(t, err, "could not copy source data in %s to %s", src, tmpDir)
+
+	ns := "jx"
+	r := &rules.PromoteRule{
+		TemplateContext: rules.TemplateContext{
+			GitURL:            "https://github.com/myorg/myapp.git",
+			Version:           "1.2.3",
+			AppName:           "myapp",
+			Namespace:         ns,
+			HelmRepositoryURL: "http://chartmuseum-jx.34.78.195.22.nip.io",
+		},
+		Dir: tmpDir,
+		Config: v1alpha1.Promote{
+			Spec: v1alpha1.PromoteSpec{
+				Rules: []v1alpha1.RuleSpec{
+					{
+						HelmfileRule: &v1alpha1.HelmfileRule{
+							Path: "helmfile.yaml",
+						},
+					},
+					{
+						FileRule: &v1alpha1.FileRule{
+							Path:            "does-not-exist",
+							CommandTemplate: "echo {{.Version}}",
+						},
+					},
+				},
+			},
+		},
+		DevEnvContext: jxtesthelpers.CreateTestDevEnvironmentContext(t, ns),
+	}
+
+	rule := factory.NewRule(r)
+	require.NotNil(t, rule, "failed to create Rule at dir %s", tmpDir)
+
+	err = rule.Apply(r)
+	require.Error(t, err, "expected the rules to fail at dir %s", tmpDir)
+
+	fileName := "helmfile.yaml"
+	testhelpers.AssertTextFilesEqual(t, filepath.Join(src, fileName), filepath.Join(tmpDir, fileName), fileName)
+}
+
+func TestHelmfileRuleAmbiguousReleases(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+
+	fileName := "helmfile.yaml"
+	source := `releases:
+- chart: dev/myapp
+  version: 1.0.0
+  name: myapp
+  namespace: jx
+- chart: dev/myapp
+  version: 1.0.0
+  name: myapp
+  namespace: staging
+`
+	file := filepath.Join(tmpDir, fileName)
+	err = ioutil.WriteFile(file, []byte(source), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save file %s", file)
+
+	ns := "jx"
+	r := &rules.PromoteRule{
+		TemplateContext: rules.TemplateContext{
+			Version:           "1.2.3",
+			AppName:           "myapp",
+			Namespace:         ns,
+			HelmRepositoryURL: "http://chartmuseum-jx.34.78.195.22.nip.io",
+		},
+		Dir: tmpDir,
+		Config: v1alpha1.Promote{
+			Spec: v1alpha1.PromoteSpec{
+				RuleSpec: v1alpha1.RuleSpec{
+					HelmfileRule: &v1alpha1.HelmfileRule{
+						Path: fileName,
+					},
+				},
+			},
+		},
+		DevEnvContext: jxtesthelpers.CreateTestDevEnvironmentContext(t, ns),
+	}
+
+	// remote environments ignore the namespace so both releases match
+	r.DevEnvContext.DevEnv.Spec.RemoteCluster = true
+
+	rule := factory.NewRule(r)
+	require.NotNil(t, rule, "failed to create Rule at dir %s", tmpDir)
+
+	err = rule.Apply(r)
+	require.Error(t, err, "expected an error for the ambiguous releases at dir %s", tmpDir)
+	assert.Contains(t, err.Error(), "found 2 releases matching release name myapp")
+
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err, "failed to read file %s", file)
+	assert.Equal(t, source, string(data), "file %s should not be modified", fileName)
+}
+
+func TestFileRuleRemoveWithoutUpdateTemplate(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+
+	src := filepath.Join("test_data", "make-kpt")
+	err = files.CopyDirOverwrite(src, tmpDir)
+	require.NoError(t, err, "could not copy source data in %s to %s", src, tmpDir)
+
+	fileName := "Makefile"
+	err = files.CopyFile(filepath.Join(src, fileName+".1.expected"), filepath.Join(tmpDir, fileName))
+	require.NoError(t, err, "failed to copy %s", fileName)
+
+	cfg, _, err := promoteconfig.Discover(tmpDir, "")
+	require.NoError(t, err, "failed to load cfg dir %s", tmpDir)
+	cfg.Spec.FileRule.UpdateTemplate = nil
+
+	r := &rules.PromoteRule{
+		TemplateContext: rules.TemplateContext{
+			GitURL:  "https://github.com/myorg/myapp.git",
+			Version: "1.2.3",
+			AppName: "myapp",
+		},
+		Dir:    tmpDir,
+		Config: *cfg,
+	}
+
+	rule := factory.NewRule(r)
+	require.NotNil(t, rule, "failed to create Rule at dir %s", tmpDir)
+
+	err = rule.Remove(r)
+	require.NoError(t, err, "failed to remove app at dir %s", tmpDir)
+	assertReadVersion(t, rule, r, "")
+	testhelpers.AssertTextFilesEqual(t, filepath.Join(src, fileName+".removed.expected"), filepath.Join(tmpDir, fileName), fileName)
+}
+
+func TestPathRuleRemoveNotSupported(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+
+	src := filepath.Join("test_data", "path-yaml")
+	err = files.CopyDirOverwrite(src, tmpDir)
+	require.NoError(t, err, "could not copy source data in %s to %s", src, tmpDir)
+
+	cfg, _, err := promoteconfig.Discover(tmpDir, "")
+	require.NoError(t, err, "failed to load cfg dir %s", tmpDir)
+
+	r := &rules.PromoteRule{
+		TemplateContext: rules.TemplateContext{
+			Version: "1.2.3",
+			AppName: "myapp",
+		},
+		Dir:    tmpDir,
+		Config: *cfg,
+	}
+
+	rule := factory.NewRule(r)
+	require.NotNil(t, rule, "failed to create Rule at dir %s", tmpDir)
+
+	err = rule.Remove(r)
+	require.Error(t, err, "expected the pathRule to fail to remove the app at dir %s", tmpDir)
+}
+
+func assertReadVersion(t *testing.T, rule rules.Rule, r *rules.PromoteRule, expected string) {
+	version, err := rule.Read(r)
+	require.NoError(t, err, "failed to read the version at dir %s", r.Dir)
+	assert.Equal(t, expected, version, "version read at dir %s", r.Dir)
 
-			testhelpers.AssertTextFilesEqual(t, filepath.Join(src, fileName+".2.expected"), target, fileName)
+	lister, ok := rule.(rules.Lister)
+	if !ok || expected == "" {
+		return
+	}
+	versions, err := lister.List(r)
+	require.NoError(t, err, "failed to list the versions at dir %s", r.Dir)
+	var values []string
+	for _, v := range versions {
+		values = append(values, v)
+	}
+	assert.Contains(t, values, expected, "versions listed at dir %s", r.Dir)
+}
+
+func ruleFileNames(t *testing.T, cfg *v1alpha1.Promote, dir string) []string {
+	var answer []string
+	specs := factory.RuleSpecs(&cfg.Spec)
+	for i := range specs {
+		answer = append(answer, ruleFileName(t, &specs[i], dir))
+	}
+	return answer
+}
+
+// appendExpectedFileNames appends any other files with expected results such as files in nested folders
+func appendExpectedFileNames(t *testing.T, fileNames []string, src string) []string {
+	found := map[string]bool{}
+	for _, fileName := range fileNames {
+		found[filepath.Clean(fileName)] = true
+	}
+	for _, fileName := range expectedFileNames(t, src, ".1.expected") {
+		if !found[fileName] {
+			found[fileName] = true
+			fileNames = append(fileNames, fileName)
 		}
 	}
+	return fileNames
+}
+
+// expectedFileNames returns the relative names of the files with expected results of the given suffix
+func expectedFileNames(t *testing.T, src string, suffix string) []string {
+	var answer []string
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, suffix) {
+			return err
+		}
+		rel, err := filepath.Rel(src, strings.TrimSuffix(path, suffix))
+		if err != nil {
+			return err
+		}
+		answer = append(answer, rel)
+		return nil
+	})
+	require.NoError(t, err, "failed to find expected files in %s", src)
+	return answer
 }
 
-func ruleFileName(cfg *v1alpha1.Promote) string {
-	if cfg.Spec.AppsRule != nil {
-		return cfg.Spec.AppsRule.Path
+func ruleFileName(t *testing.T, spec *v1alpha1.RuleSpec, dir string) string {
+	if spec.AppsRule != nil {
+		return spec.AppsRule.Path
 	}
-	if cfg.Spec.HelmRule != nil {
-		path := cfg.Spec.HelmRule.Path
+	if spec.HelmRule != nil {
+		path := spec.HelmRule.Path
 		if path == "" {
 			path = "."
 		}
-		return filepath.Join(path, "requirements.yaml")
+		// helm 3 charts keep their dependencies in the Chart.yaml
+		requirementsFile := filepath.Join(path, "requirements.yaml")
+		exists, err := files.FileExists(filepath.Join(dir, requirementsFile))
+		require.NoError(t, err, "failed to check for file %s", requirementsFile)
+		if !exists {
+			return filepath.Join(path, "Chart.yaml")
+		}
+		return requirementsFile
+	}
+	if spec.HelmfileRule != nil {
+		return spec.HelmfileRule.Path
+	}
+	if spec.KustomizeRule != nil {
+		return spec.KustomizeRule.Path
+	}
+	if spec.PathRule != nil {
+		return spec.PathRule.Path
+	}
+	if spec.ArgoCDRule != nil {
+		return filepath.Join(spec.ArgoCDRule.Path, "myapp.yaml")
+	}
+	if spec.FluxRule != nil {
+		return filepath.Join(spec.FluxRule.Path, "myapp.yaml")
 	}
-	if cfg.Spec.HelmfileRule != nil {
-		return cfg.Spec.HelmfileRule.Path
+	return spec.FileRule.Path
+}
+
+func TestRuleFactoryMultipleKinds(t *testing.T) {
+	twoKinds := v1alpha1.RuleSpec{
+		HelmfileRule: &v1alpha1.HelmfileRule{
+			Path: "helmfile.yaml",
+		},
+		KptRule: &v1alpha1.KptRule{
+			Path: "config-root",
+		},
+	}
+	oneKind := v1alpha1.RuleSpec{
+		FileRule: &v1alpha1.FileRule{
+			Path:            "Makefile",
+			CommandTemplate: "echo {{.Version}}",
+		},
+	}
+	testCases := []struct {
+		spec     v1alpha1.PromoteSpec
+		expected string
+	}{
+		{
+			spec:     v1alpha1.PromoteSpec{RuleSpec: twoKinds},
+			expected: "invalid spec: only one kind of rule can be configured but found helmfileRule, kptRule",
+		},
+		{
+			spec:     v1alpha1.PromoteSpec{Rules: []v1alpha1.RuleSpec{oneKind, twoKinds}},
+			expected: "invalid rules[1]: only one kind of rule can be configured but found helmfileRule, kptRule",
+		},
+		{
+			spec:     v1alpha1.PromoteSpec{RuleSpec: oneKind, Rules: []v1alpha1.RuleSpec{twoKinds}},
+			expected: "invalid rules[0]: only one kind of rule can be configured but found helmfileRule, kptRule",
+		},
+	}
+	for _, tc := range testCases {
+		r := &rules.PromoteRule{
+			TemplateContext: rules.TemplateContext{
+				Version: "1.2.3",
+				AppName: "myapp",
+			},
+			Dir: "test_data",
+			Config: v1alpha1.Promote{
+				Spec: tc.spec,
+			},
+		}
+		rule := factory.NewRule(r)
+		require.NotNil(t, rule, "no rule created for %s", tc.expected)
+		err := rule.Validate(r)
+		require.Error(t, err, "expected a validation error for %s", tc.expected)
+		assert.Equal(t, tc.expected, err.Error())
 	}
-	return cfg.Spec.FileRule.Path
+}
+
+func TestPathRuleKeepsFileMode(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	src := filepath.Join("test_data", "path-json")
+	err = files.CopyDirOverwrite(src, tmpDir)
+	require.NoError(t, err, "could not copy source data in %s to %s", src, tmpDir)
+
+	file := filepath.Join(tmpDir, "package.json")
+	require.NoError(t, os.Chmod(file, 0600), "failed to change the mode of %s", file)
+
+	cfg, _, err := promoteconfig.Discover(tmpDir, "")
+	require.NoError(t, err, "failed to load cfg dir %s", tmpDir)
+
+	r := &rules.PromoteRule{
+		TemplateContext: rules.TemplateContext{
+			Version: "1.2.3",
+			AppName: "myapp",
+		},
+		Dir:    tmpDir,
+		Config: *cfg,
+	}
+	rule := factory.NewRule(r)
+	require.NotNil(t, rule, "failed to create Rule at dir %s", tmpDir)
+
+	err = rule.Apply(r)
+	require.NoError(t, err, "failed to promote at dir %s", tmpDir)
+
+	info, err := os.Stat(file)
+	require.NoError(t, err, "failed to stat %s", file)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "the mode of %s should be kept", file)
 }
@@ -5,7 +5,6 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
-	"text/template"
 
 	"github.com/jenkins-x/jx-helpers/pkg/files"
 	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
@@ -50,19 +49,9 @@ func FileRule(r *rules.PromoteRule) error {
 	updated := false
 	updateTemplate := rule.UpdateTemplate
 	if updateTemplate != nil {
-		lineMatcher := v1alpha1.LineMatcher{}
-		lineMatcher.Prefix, err = evaluateTemplate(r, updateTemplate.Prefix, "")
+		m, err := createUpdateMatcher(r, rule)
 		if err != nil {
-			return errors.Wrapf(err, "failed to evaluate updateTemplate.prefix")
-		}
-		lineMatcher.Regex, err = evaluateTemplate(r, updateTemplate.Regex, "")
-		if err != nil {
-			return errors.Wrapf(err, "failed to evaluate updateTemplate.regex")
-		}
-
-		m, err := createMatcher(rule, lineMatcher)
-		if err != nil {
-			return errors.Wrapf(err, "failed to create line matcher for updateTemplate")
+			return err
 		}
 
 		for i, line := range lines {
@@ -116,6 +105,26 @@ func insertItem(a []string, index int, value string) []string {
 	return a
 }
 
+// createUpdateMatcher creates a line matcher from the updateTemplate of the rule
+func createUpdateMatcher(r *rules.PromoteRule, rule *v1alpha1.FileRule) (func(string) bool, error) {
+	updateTemplate := rule.UpdateTemplate
+	lineMatcher := v1alpha1.LineMatcher{}
+	var err error
+	lineMatcher.Prefix, err = evaluateTemplate(r, updateTemplate.Prefix, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to evaluate updateTemplate.prefix")
+	}
+	lineMatcher.Regex, err = evaluateTemplate(r, updateTemplate.Regex, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to evaluate updateTemplate.regex")
+	}
+	m, err := createMatcher(rule, lineMatcher)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create line matcher for updateTemplate")
+	}
+	return m, nil
+}
+
 func createMatcher(rule *v1alpha1.FileRule, lineMatcher v1alpha1.LineMatcher) (func(string) bool, error) {
 	linePrefix := rule.LinePrefix
 
@@ -149,18 +158,6 @@ func evaluateTemplate(r *rules.PromoteRule, templateText string, linePrefix stri
 	if templateText == "" {
 		return "", nil
 	}
-	tmpl, err := template.New("test").Parse(templateText)
-	if err != nil {
-		return "", errors.Wrapf(err, "failed to parse go template: %s", templateText)
-	}
-	ctx := r.TemplateContext
-	buf := &strings.Builder{}
-	if linePrefix != "" {
-		buf.WriteString(linePrefix)
-	}
-	err = tmpl.Execute(buf, &ctx)
-	if err != nil {
-		return buf.String(), errors.Wrapf(err, "failed to evaluate template with %#v", ctx)
-	}
-	return buf.String(), nil
+	text, err := rules.EvaluateTemplate(r, templateText)
+	return linePrefix + text, err
 }
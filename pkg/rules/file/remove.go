@@ -0,0 +1,85 @@
+package file
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/pkg/errors"
+)
+
+// RemoveFileRule removes the line of the app matching the updateTemplate of the file rule or, if there is no
+// updateTemplate, the line matching the commandTemplate
+func RemoveFileRule(r *rules.PromoteRule) error {
+	config := r.Config
+	if config.Spec.FileRule == nil {
+		return errors.Errorf("no fileRule configured")
+	}
+	rule := config.Spec.FileRule
+	path := rule.Path
+	if path == "" {
+		return errors.Errorf("no path property in FileRule %#v", rule)
+	}
+	path = filepath.Join(r.Dir, path)
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if !exists {
+		return errors.Errorf("file does not exist: %s", path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read file %s", path)
+	}
+	lines := strings.Split(string(data), "\n")
+
+	m, err := createRemoveMatcher(r, rule)
+	if err != nil {
+		return err
+	}
+
+	removed := false
+	for i, line := range lines {
+		if m(line) {
+			lines = append(lines[:i], lines[i+1:]...)
+			removed = true
+			break
+		}
+	}
+	if !removed {
+		log.Logger().Infof("no line found for app %s in %s", r.AppName, termcolor.ColorInfo(path))
+		return nil
+	}
+
+	data = []byte(strings.Join(lines, "\n"))
+	err = ioutil.WriteFile(path, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write file %s", path)
+	}
+	log.Logger().Infof("modified file %s", termcolor.ColorInfo(path))
+	return nil
+}
+
+// createRemoveMatcher creates a line matcher for the line of the app from the updateTemplate or, if there is no
+// updateTemplate, from the commandTemplate in the same way the version is read
+func createRemoveMatcher(r *rules.PromoteRule, rule *v1alpha1.FileRule) (func(string) bool, error) {
+	if rule.UpdateTemplate != nil {
+		return createUpdateMatcher(r, rule)
+	}
+	// lets check the commandTemplate contains the version so that we can find the line
+	_, err := rules.ExtractVersion(r, rule.CommandTemplate, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot find the line of app %s as the fileRule has no updateTemplate", r.AppName)
+	}
+	return func(line string) bool {
+		version, err := rules.ExtractVersion(r, rule.CommandTemplate, strings.TrimPrefix(line, rule.LinePrefix))
+		return err == nil && version != ""
+	}, nil
+}
@@ -0,0 +1,87 @@
+package file
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/pkg/errors"
+)
+
+// Rule promotes apps by modifying a line of a file such as a Makefile or script
+type Rule struct{}
+
+var _ rules.Rule = &Rule{}
+
+// Validate validates the fileRule and its line matchers
+func (*Rule) Validate(r *rules.PromoteRule) error {
+	rule := r.Config.Spec.FileRule
+	if rule == nil {
+		return errors.Errorf("no fileRule configured")
+	}
+	if rule.Path == "" {
+		return errors.Errorf("no path property in FileRule %#v", rule)
+	}
+	if rule.CommandTemplate == "" {
+		return errors.Errorf("no commandTemplate property in FileRule %#v", rule)
+	}
+	if rule.UpdateTemplate != nil {
+		_, err := createUpdateMatcher(r, rule)
+		if err != nil {
+			return err
+		}
+	}
+	for i, insertAfter := range rule.InsertAfter {
+		_, err := createMatcher(rule, insertAfter)
+		if err != nil {
+			return errors.Wrapf(err, "invalid insertAfter[%d]", i)
+		}
+	}
+	return nil
+}
+
+// Read returns the version of the app from the first line of the file matching the commandTemplate
+func (*Rule) Read(r *rules.PromoteRule) (string, error) {
+	rule := r.Config.Spec.FileRule
+	if rule == nil {
+		return "", errors.Errorf("no fileRule configured")
+	}
+	if rule.Path == "" {
+		return "", errors.Errorf("no path property in FileRule %#v", rule)
+	}
+	path := filepath.Join(r.Dir, rule.Path)
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if !exists {
+		return "", nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read file %s", path)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		version, err := rules.ExtractVersion(r, rule.CommandTemplate, strings.TrimPrefix(line, rule.LinePrefix))
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to find the version in file %s", path)
+		}
+		if version != "" {
+			return version, nil
+		}
+	}
+	return "", nil
+}
+
+// Apply promotes the version of the app
+func (*Rule) Apply(r *rules.PromoteRule) error {
+	return FileRule(r)
+}
+
+// Remove removes the app
+func (*Rule) Remove(r *rules.PromoteRule) error {
+	return RemoveFileRule(r)
+}
@@ -0,0 +1,274 @@
+package flux
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x/jx-promote/pkg/envctx"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// HelmAPIGroup the API group of the HelmRelease resource
+	HelmAPIGroup = "helm.toolkit.fluxcd.io"
+
+	// SourceAPIGroup the API group of the HelmRepository resource
+	SourceAPIGroup = "source.toolkit.fluxcd.io"
+
+	// DefaultRepositoryNamespace the default namespace of new HelmRepository resources
+	DefaultRepositoryNamespace = "flux-system"
+
+	// DefaultInterval the default reconcile interval of new resources
+	DefaultInterval = "5m"
+
+	kustomizationFile = "kustomization.yaml"
+)
+
+// repository a HelmRepository declared in the repository
+type repository struct {
+	Name      string
+	Namespace string
+	URL       string
+}
+
+// FluxRule modifies the Flux HelmRelease resources for the app
+func FluxRule(r *rules.PromoteRule) error {
+	config := r.Config
+	if config.Spec.FluxRule == nil {
+		return errors.Errorf("no fluxRule configured")
+	}
+	rule := config.Spec.FluxRule
+	if r.AppName == "" {
+		return errors.Errorf("no AppName so cannot promote via flux")
+	}
+
+	dir := r.Dir
+	if rule.Path != "" {
+		dir = filepath.Join(dir, rule.Path)
+	}
+
+	ns := rule.Namespace
+	if ns == "" {
+		ns = r.Namespace
+	}
+
+	repositories, found, err := modifyHelmReleases(r, dir, ns)
+	if err != nil {
+		return errors.Wrapf(err, "failed to modify flux resources in dir %s", dir)
+	}
+	if found {
+		return nil
+	}
+
+	err = createHelmRelease(r, rule, dir, ns, repositories)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create HelmRelease in dir %s", dir)
+	}
+	return nil
+}
+
+// modifyHelmReleases modifies the version of any HelmRelease for the app returning the declared HelmRepository
+// resources and whether a HelmRelease was found
+func modifyHelmReleases(r *rules.PromoteRule, dir string, ns string) ([]repository, bool, error) {
+	var repositories []repository
+	exists, err := files.DirExists(dir)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to check if dir exists %s", dir)
+	}
+	if !exists {
+		return nil, false, nil
+	}
+
+	found := false
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		docs, err := yamlnodes.LoadFile(path)
+		if err != nil {
+			log.Logger().Debugf("ignoring file %s as it could not be parsed: %s", path, err.Error())
+			return nil
+		}
+		modified := false
+		for _, doc := range docs {
+			node := yamlnodes.Root(doc)
+			if isKind(node, SourceAPIGroup, "HelmRepository") {
+				repositories = append(repositories, repository{
+					Name:      yamlnodes.GetPathString(node, "metadata", "name"),
+					Namespace: yamlnodes.GetPathString(node, "metadata", "namespace"),
+					URL:       yamlnodes.GetPathString(node, "spec", "url"),
+				})
+				continue
+			}
+			if !isKind(node, HelmAPIGroup, "HelmRelease") || !matchesRelease(node, r.AppName, ns) {
+				continue
+			}
+			chartSpec := yamlnodes.GetPath(node, "spec", "chart", "spec")
+			if chartSpec == nil || chartSpec.Kind != yaml.MappingNode {
+				return errors.Errorf("no spec.chart.spec found in HelmRelease %s in file %s", yamlnodes.GetPathString(node, "metadata", "name"), path)
+			}
+			yamlnodes.SetMapString(chartSpec, "version", r.Version)
+			modified = true
+		}
+		if !modified {
+			return nil
+		}
+		found = true
+		err = yamlnodes.EditFile(path, docs)
+		if err != nil {
+			return err
+		}
+		log.Logger().Infof("modified file %s", termcolor.ColorInfo(path))
+		return nil
+	})
+	return repositories, found, err
+}
+
+func isKind(node *yaml.Node, group string, kind string) bool {
+	return strings.HasPrefix(yamlnodes.GetMapString(node, "apiVersion"), group+"/") && yamlnodes.GetMapString(node, "kind") == kind
+}
+
+// matchesRelease returns true if the HelmRelease is for the app and is in the namespace if it specifies one
+func matchesRelease(node *yaml.Node, app string, ns string) bool {
+	releaseNs := yamlnodes.GetPathString(node, "metadata", "namespace")
+	if releaseNs != "" && ns != "" && releaseNs != ns {
+		return false
+	}
+	return yamlnodes.GetPathString(node, "metadata", "name") == app ||
+		yamlnodes.GetPathString(node, "spec", "chart", "spec", "chart") == app
+}
+
+// createHelmRelease creates a HelmRelease for the app adding a HelmRepository if the chart repository is not declared
+func createHelmRelease(r *rules.PromoteRule, rule *v1alpha1.FluxRule, dir string, ns string, repositories []repository) error {
+	if r.DevEnvContext == nil {
+		return errors.Errorf("no devEnvContext")
+	}
+	details, err := r.DevEnvContext.ChartDetails(r.AppName, r.HelmRepositoryURL)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get chart details for %s repo %s", r.AppName, r.HelmRepositoryURL)
+	}
+
+	err = os.MkdirAll(dir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", dir)
+	}
+
+	repo, found := defaultRepository(repositories, details, "dev")
+	if !found {
+		repo.Namespace = rule.RepositoryNamespace
+		if repo.Namespace == "" {
+			repo.Namespace = DefaultRepositoryNamespace
+		}
+		node := yamlnodes.NewMap("apiVersion", SourceAPIGroup+"/v1beta1", "kind", "HelmRepository")
+		yamlnodes.SetPathString(node, repo.Name, "metadata", "name")
+		yamlnodes.SetPathString(node, repo.Namespace, "metadata", "namespace")
+		yamlnodes.SetPathString(node, DefaultInterval, "spec", "interval")
+		yamlnodes.SetPathString(node, repo.URL, "spec", "url")
+		err = saveResource(dir, repo.Name+"-helmrepository.yaml", node)
+		if err != nil {
+			return err
+		}
+	}
+
+	node := yamlnodes.NewMap("apiVersion", HelmAPIGroup+"/v2beta1", "kind", "HelmRelease")
+	yamlnodes.SetPathString(node, r.AppName, "metadata", "name")
+	if ns != "" {
+		yamlnodes.SetPathString(node, ns, "metadata", "namespace")
+	}
+	yamlnodes.SetPathString(node, DefaultInterval, "spec", "interval")
+	chartSpec := yamlnodes.EnsureMapValue(node, yaml.MappingNode, "spec", "chart", "spec")
+	yamlnodes.SetMapString(chartSpec, "chart", details.LocalName)
+	yamlnodes.SetMapString(chartSpec, "version", r.Version)
+	chartSpec.Content = append(chartSpec.Content, yamlnodes.NewString("sourceRef"), yamlnodes.NewMap(
+		"kind", "HelmRepository",
+		"name", repo.Name,
+		"namespace", repo.Namespace,
+	))
+	return saveResource(dir, r.AppName+".yaml", node)
+}
+
+// defaultRepository finds the HelmRepository for the chart repository URL or creates a new one with a name that does
+// not clash with any other existing repositories. Returns true if the repository already exists
+func defaultRepository(repositories []repository, d *envctx.ChartDetails, defaultPrefix string) (repository, bool) {
+	names := map[string]bool{}
+	for _, repo := range repositories {
+		if repo.URL == d.Repository {
+			return repo, true
+		}
+		names[repo.Name] = true
+	}
+
+	name := d.Prefix
+	if name == "" {
+		name = defaultPrefix
+		for i := 2; names[name]; i++ {
+			// the defaultPrefix exists and maps to another URL
+			// so lets create another similar name as an alias for this repo URL
+			name = fmt.Sprintf("%s%d", defaultPrefix, i)
+		}
+	}
+	return repository{
+		Name: name,
+		URL:  d.Repository,
+	}, false
+}
+
+// saveResource saves the new resource to the file in the dir adding it to the resources of any kustomization file
+func saveResource(dir string, fileName string, node *yaml.Node) error {
+	file := filepath.Join(dir, fileName)
+	exists, err := files.FileExists(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", file)
+	}
+	if exists {
+		return errors.Errorf("cannot create resource as file %s already exists", file)
+	}
+	err = yamlnodes.EditFile(file, []*yaml.Node{node})
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("created file %s", termcolor.ColorInfo(file))
+
+	kf := filepath.Join(dir, kustomizationFile)
+	exists, err = files.FileExists(kf)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", kf)
+	}
+	if !exists {
+		return nil
+	}
+	docs, err := yamlnodes.LoadFile(kf)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 || yamlnodes.Root(docs[0]).Kind != yaml.MappingNode {
+		return errors.Errorf("file %s does not contain a YAML object", kf)
+	}
+	resources := yamlnodes.EnsureMapValue(yamlnodes.Root(docs[0]), yaml.SequenceNode, "resources")
+	for _, resource := range resources.Content {
+		if resource.Value == fileName {
+			return nil
+		}
+	}
+	resources.Content = append(resources.Content, yamlnodes.NewString(fileName))
+	return yamlnodes.EditFile(kf, docs)
+}
@@ -0,0 +1,155 @@
+package flux
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// RemoveFluxRule removes the Flux HelmRelease resources for the app deleting any files which become empty along with
+// their entry in the resources of the kustomization file
+func RemoveFluxRule(r *rules.PromoteRule) error {
+	config := r.Config
+	if config.Spec.FluxRule == nil {
+		return errors.Errorf("no fluxRule configured")
+	}
+	rule := config.Spec.FluxRule
+	if r.AppName == "" {
+		return errors.Errorf("no AppName so cannot remove via flux")
+	}
+
+	dir := r.Dir
+	if rule.Path != "" {
+		dir = filepath.Join(dir, rule.Path)
+	}
+
+	ns := rule.Namespace
+	if ns == "" {
+		ns = r.Namespace
+	}
+
+	found, err := removeHelmReleases(r, dir, ns)
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove flux resources in dir %s", dir)
+	}
+	if !found {
+		log.Logger().Infof("no HelmRelease found for app %s in %s", r.AppName, termcolor.ColorInfo(dir))
+	}
+	return nil
+}
+
+// removeHelmReleases removes any HelmRelease for the app returning true if any were found
+func removeHelmReleases(r *rules.PromoteRule, dir string, ns string) (bool, error) {
+	exists, err := files.DirExists(dir)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check if dir exists %s", dir)
+	}
+	if !exists {
+		return false, nil
+	}
+
+	var removedFiles []string
+	found := false
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		docs, err := yamlnodes.LoadFile(path)
+		if err != nil {
+			log.Logger().Debugf("ignoring file %s as it could not be parsed: %s", path, err.Error())
+			return nil
+		}
+		var remaining []*yaml.Node
+		for _, doc := range docs {
+			node := yamlnodes.Root(doc)
+			if isKind(node, HelmAPIGroup, "HelmRelease") && matchesRelease(node, r.AppName, ns) {
+				continue
+			}
+			remaining = append(remaining, doc)
+		}
+		if len(remaining) == len(docs) {
+			return nil
+		}
+		found = true
+		if len(remaining) == 0 {
+			removedFiles = append(removedFiles, path)
+			return nil
+		}
+		err = yamlnodes.SaveFile(path, remaining)
+		if err != nil {
+			return err
+		}
+		log.Logger().Infof("modified file %s", termcolor.ColorInfo(path))
+		return nil
+	})
+	if err != nil {
+		return found, err
+	}
+
+	for _, path := range removedFiles {
+		err = removeResource(path)
+		if err != nil {
+			return found, err
+		}
+	}
+	return found, nil
+}
+
+// removeResource removes the resource file and its entry in the resources of any kustomization file in the same dir
+func removeResource(path string) error {
+	err := os.Remove(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove file %s", path)
+	}
+	log.Logger().Infof("removed file %s", termcolor.ColorInfo(path))
+
+	kf := filepath.Join(filepath.Dir(path), kustomizationFile)
+	exists, err := files.FileExists(kf)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", kf)
+	}
+	if !exists {
+		return nil
+	}
+	docs, err := yamlnodes.LoadFile(kf)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 || yamlnodes.Root(docs[0]).Kind != yaml.MappingNode {
+		return errors.Errorf("file %s does not contain a YAML object", kf)
+	}
+	resources := yamlnodes.GetMapValue(yamlnodes.Root(docs[0]), "resources")
+	if resources == nil || resources.Kind != yaml.SequenceNode {
+		return nil
+	}
+	fileName := filepath.Base(path)
+	var content []*yaml.Node
+	for _, resource := range resources.Content {
+		if resource.Value != fileName {
+			content = append(content, resource)
+		}
+	}
+	if len(content) == len(resources.Content) {
+		return nil
+	}
+	resources.Content = content
+	return yamlnodes.SaveFile(kf, docs)
+}
@@ -0,0 +1,91 @@
+package flux
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/pkg/errors"
+)
+
+// Rule promotes apps in Flux HelmRelease resources
+type Rule struct{}
+
+var _ rules.Rule = &Rule{}
+
+// Validate validates the fluxRule
+func (*Rule) Validate(r *rules.PromoteRule) error {
+	if r.Config.Spec.FluxRule == nil {
+		return errors.Errorf("no fluxRule configured")
+	}
+	return nil
+}
+
+// Read returns the chart version of the first HelmRelease for the app
+func (*Rule) Read(r *rules.PromoteRule) (string, error) {
+	rule := r.Config.Spec.FluxRule
+	if rule == nil {
+		return "", errors.Errorf("no fluxRule configured")
+	}
+	dir := r.Dir
+	if rule.Path != "" {
+		dir = filepath.Join(dir, rule.Path)
+	}
+	ns := rule.Namespace
+	if ns == "" {
+		ns = r.Namespace
+	}
+
+	exists, err := files.DirExists(dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to check if dir exists %s", dir)
+	}
+	if !exists {
+		return "", nil
+	}
+
+	version := ""
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || version != "" {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		docs, err := yamlnodes.LoadFile(path)
+		if err != nil {
+			log.Logger().Debugf("ignoring file %s as it could not be parsed: %s", path, err.Error())
+			return nil
+		}
+		for _, doc := range docs {
+			node := yamlnodes.Root(doc)
+			if isKind(node, HelmAPIGroup, "HelmRelease") && matchesRelease(node, r.AppName, ns) {
+				version = yamlnodes.GetPathString(node, "spec", "chart", "spec", "version")
+				return nil
+			}
+		}
+		return nil
+	})
+	return version, err
+}
+
+// Apply promotes the version of the app
+func (*Rule) Apply(r *rules.PromoteRule) error {
+	return FluxRule(r)
+}
+
+// Remove removes the app
+func (*Rule) Remove(r *rules.PromoteRule) error {
+	return RemoveFluxRule(r)
+}
@@ -1,12 +1,24 @@
 package helm
 
 import (
+	"os"
 	"path/filepath"
 
 	"github.com/jenkins-x/jx-helpers/pkg/files"
 	"github.com/jenkins-x/jx-helpers/pkg/helmer"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
 	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
 	"github.com/pkg/errors"
+	yaml3 "gopkg.in/yaml.v3"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ChartAPIVersionV2 the chart API version used by Helm 3 charts which store their dependencies in Chart.yaml
+	ChartAPIVersionV2 = "v2"
 )
 
 // HelmRule uses a helm rule to create promote pull requests
@@ -22,7 +34,23 @@ func HelmRule(r *rules.PromoteRule) error {
 		dir = filepath.Join(dir, rule.Path)
 	}
 
-	err := modifyChartFiles(r, dir)
+	chartFile, err := helmer.FindChartFileName(dir)
+	if err != nil {
+		return err
+	}
+	docs, err := yamlnodes.LoadFile(chartFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load chart file %s", chartFile)
+	}
+	if len(docs) == 0 || yamlnodes.Root(docs[0]).Kind != yaml3.MappingNode {
+		return errors.Errorf("chart file %s does not contain a YAML object", chartFile)
+	}
+
+	if rule.MigrateRequirements || yamlnodes.GetMapString(yamlnodes.Root(docs[0]), "apiVersion") == ChartAPIVersionV2 {
+		err = modifyChartDependencies(r, rule, dir, chartFile, docs)
+	} else {
+		err = modifyChartFiles(r, dir)
+	}
 	if err != nil {
 		return errors.Wrapf(err, "failed to modify chart files in dir %s", dir)
 	}
@@ -80,3 +108,130 @@ func modifyRequirements(r *rules.PromoteRule, requirements *helmer.Requirements)
 	requirements.SetAppVersion(r.AppName, r.Version, r.HelmRepositoryURL, r.ChartAlias)
 	return nil
 }
+
+// modifyChartDependencies modifies the dependencies in the Helm 3 Chart.yaml file retaining comments, optionally
+// migrating any dependencies from the requirements.yaml file first
+func modifyChartDependencies(r *rules.PromoteRule, rule *v1alpha1.HelmRule, dir string, chartFile string, docs []*yaml3.Node) error {
+	chart := yamlnodes.Root(docs[0])
+	requirementsFile, err := helmer.FindRequirementsFileName(dir)
+	if err != nil {
+		return err
+	}
+	exists, err := files.FileExists(requirementsFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to detect file %s", requirementsFile)
+	}
+
+	if rule.MigrateRequirements {
+		if yamlnodes.GetMapValue(chart, "apiVersion") == nil {
+			// lets keep the apiVersion at the top of the file
+			key := yamlnodes.NewString("apiVersion")
+			if len(chart.Content) > 0 {
+				key.HeadComment = chart.Content[0].HeadComment
+				chart.Content[0].HeadComment = ""
+			}
+			chart.Content = append([]*yaml3.Node{key, yamlnodes.NewString(ChartAPIVersionV2)}, chart.Content...)
+		}
+		yamlnodes.SetMapString(chart, "apiVersion", ChartAPIVersionV2)
+		if exists {
+			err = migrateRequirements(chart, requirementsFile)
+			if err != nil {
+				return errors.Wrapf(err, "failed to migrate %s", requirementsFile)
+			}
+		}
+	} else if exists {
+		log.Logger().Warnf("ignoring file %s as the chart %s uses apiVersion %s. Enable migrateRequirements on the helmRule to move its dependencies into the chart", requirementsFile, chartFile, ChartAPIVersionV2)
+	}
+
+	dependencies := chartDependencies(chart)
+	if dependencies == nil {
+		return errors.Errorf("the dependencies in chart file %s are not a list", chartFile)
+	}
+	setDependencyVersion(dependencies, r.AppName, r.Version, r.HelmRepositoryURL, r.ChartAlias)
+
+	err = yamlnodes.EditFile(chartFile, docs)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("modified file %s", termcolor.ColorInfo(chartFile))
+	return nil
+}
+
+// migrateRequirements moves the dependencies in the requirements file into the chart and removes the requirements file
+func migrateRequirements(chart *yaml3.Node, requirementsFile string) error {
+	requirements, err := helmer.LoadRequirementsFile(requirementsFile)
+	if err != nil {
+		return err
+	}
+	dependencies := chartDependencies(chart)
+	if dependencies == nil {
+		return errors.Errorf("the dependencies in the chart are not a list")
+	}
+	for _, dep := range requirements.Dependencies {
+		if dep == nil || findDependency(dependencies, dep.Name, dep.Alias) != nil {
+			continue
+		}
+		data, err := yaml.Marshal(dep)
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal dependency %s", dep.Name)
+		}
+		nodes, err := yamlnodes.Parse(data)
+		if err != nil || len(nodes) == 0 {
+			return errors.Wrapf(err, "failed to parse dependency %s", dep.Name)
+		}
+		dependencies.Content = append(dependencies.Content, yamlnodes.Root(nodes[0]))
+	}
+	err = os.Remove(requirementsFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove %s", requirementsFile)
+	}
+	log.Logger().Infof("migrated the dependencies in %s to the chart", termcolor.ColorInfo(requirementsFile))
+	return nil
+}
+
+// chartDependencies returns the dependencies list of the chart creating it if it is missing or empty
+func chartDependencies(chart *yaml3.Node) *yaml3.Node {
+	dependencies := yamlnodes.EnsureMapValue(chart, yaml3.SequenceNode, "dependencies")
+	if dependencies.Kind == yaml3.ScalarNode && dependencies.Value == "" {
+		dependencies.Kind = yaml3.SequenceNode
+		dependencies.Tag = ""
+	}
+	if dependencies.Kind != yaml3.SequenceNode {
+		return nil
+	}
+	return dependencies
+}
+
+// setDependencyVersion sets the version of the app in the dependencies adding the app if it does not exist
+func setDependencyVersion(dependencies *yaml3.Node, app string, version string, repository string, alias string) {
+	dep := findDependency(dependencies, app, "")
+	if dep == nil {
+		dependencies.Content = append(dependencies.Content, yamlnodes.NewMap(
+			"name", app,
+			"version", version,
+			"repository", repository,
+			"alias", alias,
+		))
+		return
+	}
+	yamlnodes.SetMapString(dep, "version", version)
+	if repository != "" {
+		yamlnodes.SetMapString(dep, "repository", repository)
+	}
+	if alias != "" {
+		yamlnodes.SetMapString(dep, "alias", alias)
+	}
+}
+
+// findDependency finds the dependency of the given name and, if specified, alias
+func findDependency(dependencies *yaml3.Node, name string, alias string) *yaml3.Node {
+	for _, dep := range dependencies.Content {
+		if yamlnodes.GetMapString(dep, "name") != name {
+			continue
+		}
+		if alias == "" || yamlnodes.GetMapString(dep, "alias") == alias {
+			return dep
+		}
+	}
+	return nil
+}
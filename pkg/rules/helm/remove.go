@@ -0,0 +1,106 @@
+package helm
+
+import (
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/helmer"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/pkg/errors"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// RemoveHelmRule removes the app from the dependencies of the chart
+func RemoveHelmRule(r *rules.PromoteRule) error {
+	config := r.Config
+	if config.Spec.HelmRule == nil {
+		return errors.Errorf("no helmRule configured")
+	}
+	rule := config.Spec.HelmRule
+
+	dir := r.Dir
+	if rule.Path != "" {
+		dir = filepath.Join(dir, rule.Path)
+	}
+
+	chartFile, err := helmer.FindChartFileName(dir)
+	if err != nil {
+		return err
+	}
+	docs, err := yamlnodes.LoadFile(chartFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load chart file %s", chartFile)
+	}
+	if len(docs) == 0 || yamlnodes.Root(docs[0]).Kind != yaml3.MappingNode {
+		return errors.Errorf("chart file %s does not contain a YAML object", chartFile)
+	}
+
+	if yamlnodes.GetMapString(yamlnodes.Root(docs[0]), "apiVersion") == ChartAPIVersionV2 {
+		err = removeChartDependency(r, chartFile, docs)
+	} else {
+		err = removeRequirement(r, dir)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove app from chart files in dir %s", dir)
+	}
+	return nil
+}
+
+// removeRequirement removes the app from the requirements.yaml file
+func removeRequirement(r *rules.PromoteRule, dir string) error {
+	requirementsFile, err := helmer.FindRequirementsFileName(dir)
+	if err != nil {
+		return err
+	}
+	exists, err := files.FileExists(requirementsFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to detect file %s", requirementsFile)
+	}
+	if !exists {
+		return nil
+	}
+	requirements, err := helmer.LoadRequirementsFile(requirementsFile)
+	if err != nil {
+		return err
+	}
+	if !requirements.RemoveApplication(r.AppName) {
+		log.Logger().Infof("no dependency %s found in %s", r.AppName, termcolor.ColorInfo(requirementsFile))
+		return nil
+	}
+	err = helmer.SaveFile(requirementsFile, requirements)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("modified file %s", termcolor.ColorInfo(requirementsFile))
+	return nil
+}
+
+// removeChartDependency removes the app from the dependencies in the Helm 3 Chart.yaml file
+func removeChartDependency(r *rules.PromoteRule, chartFile string, docs []*yaml3.Node) error {
+	dependencies := yamlnodes.GetMapValue(yamlnodes.Root(docs[0]), "dependencies")
+	if dependencies == nil || dependencies.Kind != yaml3.SequenceNode {
+		return nil
+	}
+	var content []*yaml3.Node
+	for _, dep := range dependencies.Content {
+		if yamlnodes.GetMapString(dep, "name") == r.AppName && (r.ChartAlias == "" || yamlnodes.GetMapString(dep, "alias") == r.ChartAlias) {
+			continue
+		}
+		content = append(content, dep)
+	}
+	if len(content) == len(dependencies.Content) {
+		log.Logger().Infof("no dependency %s found in %s", r.AppName, termcolor.ColorInfo(chartFile))
+		return nil
+	}
+	dependencies.Content = content
+
+	err := yamlnodes.SaveFile(chartFile, docs)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("modified file %s", termcolor.ColorInfo(chartFile))
+	return nil
+}
@@ -0,0 +1,137 @@
+package helm
+
+import (
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/helmer"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/pkg/errors"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// Rule promotes apps in the dependencies of a helm chart
+type Rule struct{}
+
+var _ rules.Rule = &Rule{}
+var _ rules.Lister = &Rule{}
+
+// Validate validates the helmRule
+func (*Rule) Validate(r *rules.PromoteRule) error {
+	if r.Config.Spec.HelmRule == nil {
+		return errors.Errorf("no helmRule configured")
+	}
+	return nil
+}
+
+// Read returns the version of the app in the dependencies of the chart
+func (*Rule) Read(r *rules.PromoteRule) (string, error) {
+	chart, requirements, err := loadDependencies(r)
+	if err != nil {
+		return "", err
+	}
+	if chart != nil {
+		dependencies := yamlnodes.GetMapValue(chart, "dependencies")
+		if dependencies == nil || dependencies.Kind != yaml3.SequenceNode {
+			return "", nil
+		}
+		return yamlnodes.GetMapString(findDependency(dependencies, r.AppName, r.ChartAlias), "version"), nil
+	}
+	if requirements == nil {
+		return "", nil
+	}
+	for _, dep := range requirements.Dependencies {
+		if dep != nil && dep.Name == r.AppName && (r.ChartAlias == "" || dep.Alias == r.ChartAlias) {
+			return dep.Version, nil
+		}
+	}
+	return "", nil
+}
+
+// List returns the versions of the dependencies of the chart
+func (*Rule) List(r *rules.PromoteRule) (map[string]string, error) {
+	chart, requirements, err := loadDependencies(r)
+	if err != nil {
+		return nil, err
+	}
+	answer := map[string]string{}
+	if chart != nil {
+		dependencies := yamlnodes.GetMapValue(chart, "dependencies")
+		if dependencies == nil || dependencies.Kind != yaml3.SequenceNode {
+			return answer, nil
+		}
+		for _, dep := range dependencies.Content {
+			name := yamlnodes.GetMapString(dep, "name")
+			if name != "" {
+				answer[name] = yamlnodes.GetMapString(dep, "version")
+			}
+		}
+		return answer, nil
+	}
+	if requirements == nil {
+		return answer, nil
+	}
+	for _, dep := range requirements.Dependencies {
+		if dep != nil && dep.Name != "" {
+			answer[dep.Name] = dep.Version
+		}
+	}
+	return answer, nil
+}
+
+// loadDependencies loads the Helm 3 Chart.yaml file if the chart declares its dependencies inline otherwise the
+// requirements.yaml file which may be nil if there is no such file
+func loadDependencies(r *rules.PromoteRule) (*yaml3.Node, *helmer.Requirements, error) {
+	rule := r.Config.Spec.HelmRule
+	if rule == nil {
+		return nil, nil, errors.Errorf("no helmRule configured")
+	}
+	dir := r.Dir
+	if rule.Path != "" {
+		dir = filepath.Join(dir, rule.Path)
+	}
+
+	chartFile, err := helmer.FindChartFileName(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	docs, err := yamlnodes.LoadFile(chartFile)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to load chart file %s", chartFile)
+	}
+	if len(docs) == 0 || yamlnodes.Root(docs[0]).Kind != yaml3.MappingNode {
+		return nil, nil, errors.Errorf("chart file %s does not contain a YAML object", chartFile)
+	}
+	chart := yamlnodes.Root(docs[0])
+	if yamlnodes.GetMapString(chart, "apiVersion") == ChartAPIVersionV2 {
+		return chart, nil, nil
+	}
+
+	requirementsFile, err := helmer.FindRequirementsFileName(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	exists, err := files.FileExists(requirementsFile)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to detect file %s", requirementsFile)
+	}
+	if !exists {
+		return nil, nil, nil
+	}
+	requirements, err := helmer.LoadRequirementsFile(requirementsFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, requirements, nil
+}
+
+// Apply promotes the version of the app
+func (*Rule) Apply(r *rules.PromoteRule) error {
+	return HelmRule(r)
+}
+
+// Remove removes the app
+func (*Rule) Remove(r *rules.PromoteRule) error {
+	return RemoveHelmRule(r)
+}
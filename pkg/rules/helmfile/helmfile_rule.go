@@ -2,14 +2,30 @@ package helmfile
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
 	"github.com/jenkins-x/jx-helpers/pkg/yaml2s"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
 	"github.com/jenkins-x/jx-promote/pkg/envctx"
 	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
 	"github.com/pkg/errors"
 	"github.com/roboll/helmfile/pkg/state"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultPath the default path of the root helmfile
+	DefaultPath = "helmfile.yaml"
+
+	// NestedHelmfilesDir the folder containing the per namespace helmfiles when using a nested helmfile layout
+	NestedHelmfilesDir = "helmfiles"
 )
 
 // HelmfileRule uses a jx-apps.yml file
@@ -20,18 +36,22 @@ func HelmfileRule(r *rules.PromoteRule) error {
 	}
 	rule := config.Spec.HelmfileRule
 	if rule.Path == "" {
-		rule.Path = "helmfile.yaml"
+		rule.Path = DefaultPath
 	}
 
-	err := modifyHelmfile(r, filepath.Join(r.Dir, rule.Path), rule.Namespace)
+	err := modifyHelmfiles(r, rule, filepath.Join(r.Dir, rule.Path))
 	if err != nil {
 		return errors.Wrapf(err, "failed to modify chart files in dir %s", r.Dir)
 	}
 	return nil
 }
 
-// ModifyAppsFile modifies the 'jx-apps.yml' file to add/update/remove apps
-func modifyHelmfile(r *rules.PromoteRule, file string, promoteNs string) error {
+// modifyHelmfiles modifies the releases of the app in the helmfile and any nested helmfiles. If the app is not found
+// then it is added to the per namespace helmfile when using a nested layout otherwise to the given helmfile.
+//
+// If the rule has release selectors then all the matching releases are modified otherwise it is an error if more
+// than one release matches the app
+func modifyHelmfiles(r *rules.PromoteRule, rule *v1alpha1.HelmfileRule, file string) error {
 	exists, err := files.FileExists(file)
 	if err != nil {
 		return errors.Wrapf(err, "failed to detect if file exists %s", file)
@@ -40,27 +60,225 @@ func modifyHelmfile(r *rules.PromoteRule, file string, promoteNs string) error {
 		return errors.Errorf("file does not exist %s", file)
 	}
 
-	state := &state.HelmState{}
-	err = yaml2s.LoadFile(file, state)
+	promoteNs := promoteNamespace(r, rule)
+
+	matcher, err := newReleaseMatcher(r, rule, promoteNs)
 	if err != nil {
-		return errors.Wrapf(err, "failed to load file %s", file)
+		return err
 	}
 
-	err = modifyHelmfileApps(r, state, promoteNs)
+	helmfiles, err := FindHelmfiles(file)
 	if err != nil {
 		return err
 	}
 
-	err = yaml2s.SaveFile(state, file)
+	// lets find all the matching releases before modifying any files
+	var matched []string
+	count := 0
+	for _, hf := range helmfiles {
+		helmState, err := LoadHelmfile(hf)
+		if err != nil {
+			return err
+		}
+		n, err := modifyHelmfileApps(r, helmState, matcher, false)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			matched = append(matched, hf)
+			count += n
+		}
+	}
+	if count > 1 && !matcher.hasSelectors() {
+		return errors.Errorf("found %d releases matching %s in %s so please specify a releaseName, chart or selector on the helmfileRule to choose which releases to promote",
+			count, matcher.String(), strings.Join(matched, ", "))
+	}
+	for _, hf := range matched {
+		_, err = modifyHelmfile(r, hf, matcher, false)
+		if err != nil {
+			return err
+		}
+	}
+	if count > 0 {
+		return nil
+	}
+	if matcher.hasSelectors() {
+		return errors.Errorf("no releases found matching %s in %s", matcher.String(), strings.Join(helmfiles, ", "))
+	}
+
+	root, err := LoadHelmfile(file)
 	if err != nil {
-		return errors.Wrapf(err, "failed to save file %s", file)
+		return err
 	}
-	return nil
+	if len(root.Helmfiles) == 0 {
+		_, err = modifyHelmfile(r, file, matcher, true)
+		return err
+	}
+	return addNestedHelmfileRelease(r, file, root, matcher)
+}
+
+// addNestedHelmfileRelease adds the app to the helmfile for the namespace creating and registering it in the root
+// helmfile if required
+func addNestedHelmfileRelease(r *rules.PromoteRule, file string, root *state.HelmState, matcher *releaseMatcher) error {
+	promoteNs := matcher.promoteNs
+	rel := filepath.Join(NestedHelmfilesDir, promoteNs, DefaultPath)
+	nested := filepath.Join(filepath.Dir(file), rel)
+
+	exists, err := files.FileExists(nested)
+	if err != nil {
+		return errors.Wrapf(err, "failed to detect if file exists %s", nested)
+	}
+	if !exists {
+		err = os.MkdirAll(filepath.Dir(nested), files.DefaultDirWritePermissions)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create dir for %s", nested)
+		}
+		err = ioutil.WriteFile(nested, []byte(fmt.Sprintf("namespace: %s\n", promoteNs)), files.DefaultFileWritePermissions)
+		if err != nil {
+			return errors.Wrapf(err, "failed to save file %s", nested)
+		}
+		log.Logger().Infof("created file %s", termcolor.ColorInfo(nested))
+	}
+
+	registered := false
+	for _, hf := range root.Helmfiles {
+		if filepath.Clean(hf.Path) == rel {
+			registered = true
+		}
+	}
+	if !registered {
+		err = registerHelmfile(file, filepath.ToSlash(rel))
+		if err != nil {
+			return errors.Wrapf(err, "failed to add %s to the helmfiles of %s", rel, file)
+		}
+	}
+
+	_, err = modifyHelmfile(r, nested, matcher, true)
+	return err
+}
+
+// registerHelmfile adds the path to the nested helmfiles of the given helmfile
+func registerHelmfile(file string, path string) error {
+	editor, err := yamlnodes.LoadEditor(file)
+	if err != nil {
+		return err
+	}
+	if len(editor.Docs) == 0 {
+		return errors.Errorf("no YAML documents in %s", file)
+	}
+	helmfiles := yamlnodes.GetMapValue(yamlnodes.Root(editor.Docs[0]), "helmfiles")
+	if helmfiles == nil || helmfiles.Kind != yaml.SequenceNode || len(helmfiles.Content) == 0 {
+		return errors.Errorf("no helmfiles found in %s", file)
+	}
+
+	// lets use the same style as the existing entries
+	item := yamlnodes.NewMap("path", path)
+	if helmfiles.Content[0].Kind == yaml.ScalarNode {
+		item = yamlnodes.NewString(path)
+	}
+	err = editor.AppendSequence(helmfiles, item)
+	if err != nil {
+		return err
+	}
+	return editor.SaveFile(file)
 }
 
-func modifyHelmfileApps(r *rules.PromoteRule, helmfile *state.HelmState, promoteNs string) error {
+// modifyHelmfile modifies the matching releases in the given helmfile returning the number found. If create is true
+// then the app is added if it is not found
+func modifyHelmfile(r *rules.PromoteRule, file string, matcher *releaseMatcher, create bool) (int, error) {
+	helmState, err := LoadHelmfile(file)
+	if err != nil {
+		return 0, err
+	}
+
+	original := &state.HelmState{
+		Repositories: append([]state.RepositorySpec(nil), helmState.Repositories...),
+		Releases:     append([]state.ReleaseSpec(nil), helmState.Releases...),
+	}
+
+	count, err := modifyHelmfileApps(r, helmState, matcher, create)
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 && !create {
+		return 0, nil
+	}
+
+	// lets edit the file in place so that we retain comments and formatting
+	editor, err := yamlnodes.LoadEditor(file)
+	if err == nil {
+		err = editHelmfile(editor, original, helmState)
+	}
+	if err == nil {
+		return count, editor.SaveFile(file)
+	}
+	log.Logger().Warnf("could not edit file %s in place so rewriting it: %s", file, err.Error())
+
+	err = yaml2s.SaveFile(helmState, file)
+	if err != nil {
+		return count, errors.Wrapf(err, "failed to save file %s", file)
+	}
+	return count, nil
+}
+
+// editHelmfile applies the changes between the original and modified helmfile to the source of the file
+func editHelmfile(editor *yamlnodes.Editor, original *state.HelmState, modified *state.HelmState) error {
+	if len(editor.Docs) == 0 {
+		return errors.Errorf("no YAML documents")
+	}
+	root := yamlnodes.Root(editor.Docs[0])
+
+	var repositories []*yaml.Node
+	for _, repo := range modified.Repositories[len(original.Repositories):] {
+		repositories = append(repositories, yamlnodes.NewMap("name", repo.Name, "url", repo.URL))
+	}
+	err := editor.AppendItems(root, "repositories", len(original.Repositories), repositories)
+	if err != nil {
+		return err
+	}
+
+	releasesNode := yamlnodes.GetMapValue(root, "releases")
+	for i := range original.Releases {
+		from := &original.Releases[i]
+		to := &modified.Releases[i]
+		if from.Version == to.Version && from.Namespace == to.Namespace {
+			continue
+		}
+		if releasesNode == nil || releasesNode.Kind != yaml.SequenceNode || len(releasesNode.Content) != len(original.Releases) {
+			return errors.Errorf("the releases do not match the parsed helmfile")
+		}
+		node := releasesNode.Content[i]
+		if from.Version != to.Version {
+			err = editor.SetMapString(node, "version", to.Version)
+			if err != nil {
+				return err
+			}
+		}
+		if from.Namespace != to.Namespace {
+			err = editor.SetMapString(node, "namespace", to.Namespace)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var releases []*yaml.Node
+	for _, release := range modified.Releases[len(original.Releases):] {
+		releases = append(releases, yamlnodes.NewMap(
+			"name", release.Name,
+			"chart", release.Chart,
+			"version", release.Version,
+			"namespace", release.Namespace,
+		))
+	}
+	return editor.AppendItems(root, "releases", len(original.Releases), releases)
+}
+
+// modifyHelmfileApps updates the version of the matching releases returning the number found. If create is true then
+// the app is added if it is not found
+func modifyHelmfileApps(r *rules.PromoteRule, helmfile *state.HelmState, matcher *releaseMatcher, create bool) (int, error) {
 	if r.DevEnvContext == nil {
-		return errors.Errorf("no devEnvContext")
+		return 0, errors.Errorf("no devEnvContext")
 	}
 	app := r.AppName
 	version := r.Version
@@ -69,39 +287,34 @@ func modifyHelmfileApps(r *rules.PromoteRule, helmfile *state.HelmState, promote
 	}
 	details, err := r.DevEnvContext.ChartDetails(app, r.HelmRepositoryURL)
 	if err != nil {
-		return errors.Wrapf(err, "failed to get chart details for %s repo %s", app, r.HelmRepositoryURL)
+		return 0, errors.Wrapf(err, "failed to get chart details for %s repo %s", app, r.HelmRepositoryURL)
 	}
 	defaultPrefix(helmfile, details, "dev")
 
-	if promoteNs == "" {
-		promoteNs = r.Namespace
-		if promoteNs == "" {
-			promoteNs = "jx"
-		}
-	}
-
-	isRemoteEnv := r.DevEnvContext.DevEnv.Spec.RemoteCluster
-
-	found := false
+	count := 0
 	for i := range helmfile.Releases {
 		release := &helmfile.Releases[i]
-		if (release.Name == app || release.Name == details.Name) && (release.Namespace == promoteNs || isRemoteEnv) {
+		if matcher.matches(helmfile, release, details) {
 			release.Version = version
-			found = true
-			return nil
+			count++
 		}
 	}
-
-	if !found {
-		helmfile.Releases = append(helmfile.Releases, state.ReleaseSpec{
-			Name:      details.LocalName,
-			Chart:     details.Name,
-			Version:   version,
-			Namespace: promoteNs,
-		})
+	if count > 0 || !create {
+		return count, nil
 	}
 
-	return nil
+	// releases in a per namespace helmfile default to the namespace of the helmfile
+	ns := matcher.promoteNs
+	if helmfile.OverrideNamespace == ns {
+		ns = ""
+	}
+	helmfile.Releases = append(helmfile.Releases, state.ReleaseSpec{
+		Name:      details.LocalName,
+		Chart:     details.Name,
+		Version:   version,
+		Namespace: ns,
+	})
+	return 0, nil
 }
 
 // defaultPrefix lets find a chart prefix / repository name for the URL that does not clash with
@@ -1,6 +1,10 @@
 package helmfile
 
 import (
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
 	"github.com/jenkins-x/jx-helpers/pkg/yaml2s"
 	"github.com/pkg/errors"
 	"github.com/roboll/helmfile/pkg/state"
@@ -15,3 +19,50 @@ func LoadHelmfile(file string) (*state.HelmState, error) {
 	}
 	return state, nil
 }
+
+// FindHelmfiles returns the given helmfile and any local nested helmfiles it references in the order they are declared
+func FindHelmfiles(file string) ([]string, error) {
+	var answer []string
+	err := findHelmfiles(file, map[string]bool{}, &answer)
+	return answer, err
+}
+
+func findHelmfiles(file string, visited map[string]bool, answer *[]string) error {
+	file = filepath.Clean(file)
+	if visited[file] {
+		return nil
+	}
+	visited[file] = true
+
+	exists, err := files.FileExists(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to detect if file exists %s", file)
+	}
+	if !exists {
+		return nil
+	}
+	*answer = append(*answer, file)
+
+	helmState, err := LoadHelmfile(file)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(file)
+	for _, sub := range helmState.Helmfiles {
+		// lets ignore remote helmfiles such as git::https://...
+		if sub.Path == "" || strings.Contains(sub.Path, "::") {
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(dir, sub.Path))
+		if err != nil {
+			return errors.Wrapf(err, "failed to find helmfiles matching %s in %s", sub.Path, file)
+		}
+		for _, m := range matches {
+			err = findHelmfiles(m, visited, answer)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
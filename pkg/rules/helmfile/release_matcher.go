@@ -0,0 +1,123 @@
+package helmfile
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x/jx-promote/pkg/envctx"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/pkg/errors"
+	"github.com/roboll/helmfile/pkg/state"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// releaseMatcher decides which releases in a helmfile are promoted
+type releaseMatcher struct {
+	app         string
+	promoteNs   string
+	isRemoteEnv bool
+	releaseName string
+	chart       string
+	selector    labels.Selector
+}
+
+// newReleaseMatcher creates a matcher for the release selectors of the rule
+func newReleaseMatcher(r *rules.PromoteRule, rule *v1alpha1.HelmfileRule, promoteNs string) (*releaseMatcher, error) {
+	if r.DevEnvContext == nil {
+		return nil, errors.Errorf("no devEnvContext")
+	}
+	releaseName, err := rules.EvaluateTemplate(r, rule.ReleaseName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to evaluate releaseName template")
+	}
+	m := &releaseMatcher{
+		app:         r.AppName,
+		promoteNs:   promoteNs,
+		isRemoteEnv: r.DevEnvContext.DevEnv != nil && r.DevEnvContext.DevEnv.Spec.RemoteCluster,
+		releaseName: strings.TrimSpace(releaseName),
+		chart:       rule.Chart,
+	}
+	if rule.Selector != "" {
+		m.selector, err = labels.Parse(rule.Selector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse label selector %s", rule.Selector)
+		}
+	}
+	return m, nil
+}
+
+// hasSelectors returns true if the rule specifies which releases to promote
+func (m *releaseMatcher) hasSelectors() bool {
+	return m.releaseName != "" || m.chart != "" || m.selector != nil
+}
+
+// String describes the selectors for error messages
+func (m *releaseMatcher) String() string {
+	if !m.hasSelectors() {
+		return fmt.Sprintf("release name %s", m.app)
+	}
+	var answer []string
+	if m.releaseName != "" {
+		answer = append(answer, "releaseName "+m.releaseName)
+	}
+	if m.chart != "" {
+		answer = append(answer, "chart "+m.chart)
+	}
+	if m.selector != nil {
+		answer = append(answer, "selector "+m.selector.String())
+	}
+	return strings.Join(answer, " and ")
+}
+
+// matches returns true if the release in the helmfile should be promoted
+func (m *releaseMatcher) matches(helmfile *state.HelmState, release *state.ReleaseSpec, details *envctx.ChartDetails) bool {
+	if !m.inNamespace(helmfile, release) {
+		return false
+	}
+	releaseNs := releaseNamespace(helmfile, release)
+	if !m.hasSelectors() {
+		return release.Name == m.app || release.Name == details.Name
+	}
+	if m.releaseName != "" && release.Name != m.releaseName {
+		return false
+	}
+	if m.chart != "" && release.Chart != m.chart && chartName(release.Chart) != m.chart {
+		return false
+	}
+	if m.selector != nil && !m.selector.Matches(releaseLabels(release, releaseNs)) {
+		return false
+	}
+	return true
+}
+
+// inNamespace returns true if the release is in the namespace apps are promoted to
+func (m *releaseMatcher) inNamespace(helmfile *state.HelmState, release *state.ReleaseSpec) bool {
+	return m.isRemoteEnv || releaseNamespace(helmfile, release) == m.promoteNs
+}
+
+// releaseNamespace returns the namespace of the release defaulting to the namespace of the helmfile
+func releaseNamespace(helmfile *state.HelmState, release *state.ReleaseSpec) string {
+	if release.Namespace != "" {
+		return release.Namespace
+	}
+	return helmfile.OverrideNamespace
+}
+
+// releaseLabels returns the labels of the release along with the implicit labels helmfile adds for selectors
+func releaseLabels(release *state.ReleaseSpec, ns string) labels.Set {
+	answer := labels.Set{}
+	for k, v := range release.Labels {
+		answer[k] = v
+	}
+	answer["name"] = release.Name
+	answer["namespace"] = ns
+	answer["chart"] = chartName(release.Chart)
+	return answer
+}
+
+// chartName returns the chart name without any repository prefix
+func chartName(chart string) string {
+	paths := strings.Split(chart, "/")
+	return paths[len(paths)-1]
+}
@@ -0,0 +1,206 @@
+package helmfile
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-helpers/pkg/yaml2s"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/pkg/errors"
+	"github.com/roboll/helmfile/pkg/state"
+)
+
+// RemoveHelmfileRule removes the releases of the app from the helmfile and any nested helmfiles along with any
+// repositories no longer used by a release
+func RemoveHelmfileRule(r *rules.PromoteRule) error {
+	config := r.Config
+	if config.Spec.HelmfileRule == nil {
+		return errors.Errorf("no helmfileRule configured")
+	}
+	rule := config.Spec.HelmfileRule
+	if rule.Path == "" {
+		rule.Path = DefaultPath
+	}
+
+	err := removeHelmfileReleases(r, rule, filepath.Join(r.Dir, rule.Path))
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove app from helmfiles in dir %s", r.Dir)
+	}
+	return nil
+}
+
+func removeHelmfileReleases(r *rules.PromoteRule, rule *v1alpha1.HelmfileRule, file string) error {
+	exists, err := files.FileExists(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to detect if file exists %s", file)
+	}
+	if !exists {
+		return errors.Errorf("file does not exist %s", file)
+	}
+
+	promoteNs := promoteNamespace(r, rule)
+	matcher, err := newReleaseMatcher(r, rule, promoteNs)
+	if err != nil {
+		return err
+	}
+
+	helmfiles, err := FindHelmfiles(file)
+	if err != nil {
+		return err
+	}
+
+	states := map[string]*state.HelmState{}
+	matches := map[string][]int{}
+	var matched []string
+	count := 0
+	for _, hf := range helmfiles {
+		helmState, err := LoadHelmfile(hf)
+		if err != nil {
+			return err
+		}
+		states[hf] = helmState
+		indexes, err := findReleases(r, helmState, matcher)
+		if err != nil {
+			return err
+		}
+		if len(indexes) > 0 {
+			matches[hf] = indexes
+			matched = append(matched, hf)
+			count += len(indexes)
+		}
+	}
+	if count > 1 && !matcher.hasSelectors() {
+		return errors.Errorf("found %d releases matching %s in %s so please specify a releaseName, chart or selector on the helmfileRule to choose which releases to remove",
+			count, matcher.String(), strings.Join(matched, ", "))
+	}
+	if count == 0 {
+		log.Logger().Infof("no releases found matching %s in %s", matcher.String(), termcolor.ColorInfo(file))
+		return nil
+	}
+
+	// lets only remove repositories which are not used by a release in any of the helmfiles
+	removedPrefixes := map[string]bool{}
+	for _, hf := range matched {
+		helmState := states[hf]
+		for _, i := range matches[hf] {
+			removedPrefixes[chartPrefix(helmState.Releases[i].Chart)] = true
+		}
+		helmState.Releases = removeReleaseIndexes(helmState.Releases, matches[hf])
+	}
+	for _, helmState := range states {
+		for i := range helmState.Releases {
+			delete(removedPrefixes, chartPrefix(helmState.Releases[i].Chart))
+		}
+	}
+
+	for _, hf := range helmfiles {
+		helmState := states[hf]
+		var repositories []int
+		for i, repo := range helmState.Repositories {
+			if removedPrefixes[repo.Name] {
+				repositories = append(repositories, i)
+			}
+		}
+		if len(matches[hf]) == 0 && len(repositories) == 0 {
+			continue
+		}
+		err = removeHelmfileItems(hf, helmState, matches[hf], repositories)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findReleases returns the indexes of the releases matching the app
+func findReleases(r *rules.PromoteRule, helmState *state.HelmState, matcher *releaseMatcher) ([]int, error) {
+	if r.DevEnvContext == nil {
+		return nil, errors.Errorf("no devEnvContext")
+	}
+	details, err := r.DevEnvContext.ChartDetails(r.AppName, r.HelmRepositoryURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get chart details for %s repo %s", r.AppName, r.HelmRepositoryURL)
+	}
+	// lets resolve the prefix without modifying the repositories
+	defaultPrefix(&state.HelmState{Repositories: append([]state.RepositorySpec(nil), helmState.Repositories...)}, details, "dev")
+
+	var answer []int
+	for i := range helmState.Releases {
+		if matcher.matches(helmState, &helmState.Releases[i], details) {
+			answer = append(answer, i)
+		}
+	}
+	return answer, nil
+}
+
+// removeHelmfileItems removes the releases and repositories at the indexes from the helmfile
+func removeHelmfileItems(file string, helmState *state.HelmState, releases []int, repositories []int) error {
+	// lets edit the file in place so that we retain comments and formatting
+	editor, err := yamlnodes.LoadEditor(file)
+	if err == nil {
+		if len(editor.Docs) == 0 {
+			err = errors.Errorf("no YAML documents")
+		} else {
+			root := yamlnodes.Root(editor.Docs[0])
+			err = editor.DeleteSequenceItems(root, "releases", releases)
+			if err == nil {
+				err = editor.DeleteSequenceItems(root, "repositories", repositories)
+			}
+		}
+	}
+	if err == nil {
+		err = editor.SaveFile(file)
+		if err != nil {
+			return err
+		}
+		log.Logger().Infof("modified file %s", termcolor.ColorInfo(file))
+		return nil
+	}
+	log.Logger().Warnf("could not edit file %s in place so rewriting it: %s", file, err.Error())
+
+	var repos []state.RepositorySpec
+	for i, repo := range helmState.Repositories {
+		if !containsIndex(repositories, i) {
+			repos = append(repos, repo)
+		}
+	}
+	helmState.Repositories = repos
+	err = yaml2s.SaveFile(helmState, file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", file)
+	}
+	return nil
+}
+
+func removeReleaseIndexes(releases []state.ReleaseSpec, indexes []int) []state.ReleaseSpec {
+	var answer []state.ReleaseSpec
+	for i := range releases {
+		if !containsIndex(indexes, i) {
+			answer = append(answer, releases[i])
+		}
+	}
+	return answer
+}
+
+func containsIndex(indexes []int, index int) bool {
+	for _, i := range indexes {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
+// chartPrefix returns the repository prefix of the chart or an empty string if the chart is not from a repository
+func chartPrefix(chart string) string {
+	paths := strings.SplitN(chart, "/", 2)
+	if len(paths) < 2 {
+		return ""
+	}
+	return paths[0]
+}
@@ -0,0 +1,154 @@
+package helmfile
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/pkg/errors"
+)
+
+// Rule promotes apps in the releases of a helmfile
+type Rule struct{}
+
+var _ rules.Rule = &Rule{}
+var _ rules.Lister = &Rule{}
+
+// Validate validates the helmfileRule and its release selectors
+func (*Rule) Validate(r *rules.PromoteRule) error {
+	rule := r.Config.Spec.HelmfileRule
+	if rule == nil {
+		return errors.Errorf("no helmfileRule configured")
+	}
+	_, err := newReleaseMatcher(r, rule, promoteNamespace(r, rule))
+	return err
+}
+
+// Read returns the version of the release of the app in the helmfile or any nested helmfiles
+func (*Rule) Read(r *rules.PromoteRule) (string, error) {
+	rule := r.Config.Spec.HelmfileRule
+	if rule == nil {
+		return "", errors.Errorf("no helmfileRule configured")
+	}
+	path := rule.Path
+	if path == "" {
+		path = DefaultPath
+	}
+	file := filepath.Join(r.Dir, path)
+	exists, err := files.FileExists(file)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to detect if file exists %s", file)
+	}
+	if !exists {
+		return "", nil
+	}
+
+	matcher, err := newReleaseMatcher(r, rule, promoteNamespace(r, rule))
+	if err != nil {
+		return "", err
+	}
+	helmfiles, err := FindHelmfiles(file)
+	if err != nil {
+		return "", err
+	}
+
+	var versions []string
+	var matched []string
+	for _, hf := range helmfiles {
+		helmState, err := LoadHelmfile(hf)
+		if err != nil {
+			return "", err
+		}
+		indexes, err := findReleases(r, helmState, matcher)
+		if err != nil {
+			return "", err
+		}
+		for _, i := range indexes {
+			versions = append(versions, helmState.Releases[i].Version)
+			matched = append(matched, hf)
+		}
+	}
+	if len(versions) > 1 && !matcher.hasSelectors() {
+		return "", errors.Errorf("found %d releases matching %s in %s so please specify a releaseName, chart or selector on the helmfileRule to choose which release to read",
+			len(versions), matcher.String(), strings.Join(matched, ", "))
+	}
+	for _, v := range versions {
+		if v != versions[0] {
+			return "", errors.Errorf("the releases matching %s in %s have different versions %s", matcher.String(), strings.Join(matched, ", "), strings.Join(versions, ", "))
+		}
+	}
+	if len(versions) == 0 {
+		return "", nil
+	}
+	return versions[0], nil
+}
+
+// List returns the versions of the releases in the namespace the apps are promoted to in the helmfile or any nested
+// helmfiles
+func (*Rule) List(r *rules.PromoteRule) (map[string]string, error) {
+	rule := r.Config.Spec.HelmfileRule
+	if rule == nil {
+		return nil, errors.Errorf("no helmfileRule configured")
+	}
+	path := rule.Path
+	if path == "" {
+		path = DefaultPath
+	}
+	file := filepath.Join(r.Dir, path)
+	exists, err := files.FileExists(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to detect if file exists %s", file)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	matcher, err := newReleaseMatcher(r, rule, promoteNamespace(r, rule))
+	if err != nil {
+		return nil, err
+	}
+	helmfiles, err := FindHelmfiles(file)
+	if err != nil {
+		return nil, err
+	}
+
+	answer := map[string]string{}
+	for _, hf := range helmfiles {
+		helmState, err := LoadHelmfile(hf)
+		if err != nil {
+			return nil, err
+		}
+		for i := range helmState.Releases {
+			release := &helmState.Releases[i]
+			if _, ok := answer[release.Name]; ok || !matcher.inNamespace(helmState, release) {
+				continue
+			}
+			answer[release.Name] = release.Version
+		}
+	}
+	return answer, nil
+}
+
+// Apply promotes the version of the app
+func (*Rule) Apply(r *rules.PromoteRule) error {
+	return HelmfileRule(r)
+}
+
+// Remove removes the app
+func (*Rule) Remove(r *rules.PromoteRule) error {
+	return RemoveHelmfileRule(r)
+}
+
+// promoteNamespace returns the namespace the app is promoted to
+func promoteNamespace(r *rules.PromoteRule, rule *v1alpha1.HelmfileRule) string {
+	promoteNs := rule.Namespace
+	if promoteNs == "" {
+		promoteNs = r.Namespace
+		if promoteNs == "" {
+			promoteNs = "jx"
+		}
+	}
+	return promoteNs
+}
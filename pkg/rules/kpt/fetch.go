@@ -0,0 +1,172 @@
+package kpt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// GetPackage fetches the directory of the git repository at the ref into the package dir and creates its Kptfile
+func GetPackage(runner cmdrunner.CommandRunner, upstream *GitLock, name string, dir string) error {
+	tmpDir, err := ioutil.TempDir("", "jx-promote-kpt-")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	lock := *upstream
+	lock.Commit, err = fetchRef(runner, lock.Repo, lock.Ref, tmpDir)
+	if err != nil {
+		return err
+	}
+	srcDir, err := packageDir(tmpDir, lock.Directory)
+	if err != nil {
+		return err
+	}
+	err = files.CopyDirOverwrite(srcDir, dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to copy %s to %s", srcDir, dir)
+	}
+	err = SaveUpstream(dir, name, &lock)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save Kptfile in %s", dir)
+	}
+	log.Logger().Infof("fetched kpt package %s from %s at %s", termcolor.ColorInfo(name), lock.Repo, termcolor.ColorInfo(lock.Ref))
+	return nil
+}
+
+// UpdatePackage updates the package in the dir to the ref of its upstream using a three way merge between the
+// upstream commit in the Kptfile, the new ref and the local package so that any local changes are kept.
+//
+// If the directory is specified then the package is updated from that directory of the upstream repository
+func UpdatePackage(runner cmdrunner.CommandRunner, ref string, directory string, name string, dir string) error {
+	upstream, err := LoadUpstream(dir)
+	if err != nil {
+		return err
+	}
+	if upstream == nil {
+		return errors.Errorf("no %s found in dir %s", KptfileName, dir)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "jx-promote-kpt-")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	baseRepoDir := filepath.Join(tmpDir, "base")
+	err = fetchUpstream(runner, upstream, baseRepoDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch the current upstream of %s", dir)
+	}
+	lock := *upstream
+	lock.Ref = ref
+	if directory != "" {
+		lock.Directory = directory
+	}
+	updateRepoDir := filepath.Join(tmpDir, "update")
+	lock.Commit, err = fetchRef(runner, lock.Repo, ref, updateRepoDir)
+	if err != nil {
+		return err
+	}
+
+	baseDir, err := packageDir(baseRepoDir, upstream.Directory)
+	if err != nil {
+		return err
+	}
+	updateDir, err := packageDir(updateRepoDir, lock.Directory)
+	if err != nil {
+		return err
+	}
+	err = MergeDirs(baseDir, updateDir, dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to merge kpt package %s", dir)
+	}
+	err = SaveUpstream(dir, name, &lock)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save Kptfile in %s", dir)
+	}
+	log.Logger().Infof("updated kpt package %s to %s", termcolor.ColorInfo(name), termcolor.ColorInfo(ref))
+	return nil
+}
+
+// fetchUpstream fetches the locked commit of the upstream falling back to its ref as not all git servers allow
+// fetching a commit
+func fetchUpstream(runner cmdrunner.CommandRunner, upstream *GitLock, dir string) error {
+	if upstream.Commit != "" {
+		_, err := fetchRef(runner, upstream.Repo, upstream.Commit, dir)
+		if err == nil || upstream.Ref == "" {
+			return err
+		}
+		log.Logger().Warnf("failed to fetch commit %s of %s so using ref %s: %s", upstream.Commit, upstream.Repo, upstream.Ref, err.Error())
+	}
+	if upstream.Ref == "" {
+		return errors.Errorf("no commit or ref for the upstream %s", upstream.Repo)
+	}
+	_, err := fetchRef(runner, upstream.Repo, upstream.Ref, dir)
+	return err
+}
+
+// fetchRef fetches the ref of the git repository into the dir returning the commit SHA. The repository and ref are
+// rejected if they could be parsed as git options
+func fetchRef(runner cmdrunner.CommandRunner, repo string, ref string, dir string) (string, error) {
+	if strings.HasPrefix(repo, "-") {
+		return "", errors.Errorf("invalid git repository %s", repo)
+	}
+	if strings.HasPrefix(ref, "-") {
+		return "", errors.Errorf("invalid git ref %s", ref)
+	}
+	err := os.MkdirAll(dir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create dir %s", dir)
+	}
+	commands := [][]string{
+		{"init", "--quiet"},
+		{"fetch", "--quiet", "--depth", "1", "--", repo, ref},
+		{"checkout", "--quiet", "FETCH_HEAD", "--"},
+	}
+	for _, args := range commands {
+		_, err = runner(&cmdrunner.Command{
+			Name: "git",
+			Args: args,
+			Dir:  dir,
+		})
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to fetch %s from %s", ref, repo)
+		}
+	}
+	commit, err := runner(&cmdrunner.Command{
+		Name: "git",
+		Args: []string{"rev-parse", "HEAD"},
+		Dir:  dir,
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find the commit of %s from %s", ref, repo)
+	}
+	return strings.TrimSpace(commit), nil
+}
+
+// packageDir returns the directory of the package in the git repository dir
+func packageDir(repoDir string, directory string) (string, error) {
+	dir := filepath.Join(repoDir, filepath.FromSlash(strings.TrimPrefix(directory, "/")))
+	exists, err := files.DirExists(dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to check if dir exists %s", dir)
+	}
+	if !exists {
+		return "", errors.Errorf("the git repository does not contain the directory %s", directory)
+	}
+	// lets avoid copying the git metadata when fetching the root of a repository
+	err = os.RemoveAll(filepath.Join(repoDir, ".git"))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to remove the git metadata from %s", repoDir)
+	}
+	return dir, nil
+}
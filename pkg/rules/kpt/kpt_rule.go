@@ -12,7 +12,15 @@ import (
 	"github.com/pkg/errors"
 )
 
-// KptRule uses a jx-apps.yml file
+const (
+	// DefaultPackagePath the default template of the path of the package in the git repository of the app
+	DefaultPackagePath = "charts/{{ .AppName }}/resources"
+
+	// DefaultDirTemplate the default template of the folder to fetch the package into
+	DefaultDirTemplate = "{{ .AppName }}"
+)
+
+// KptRule fetches or updates the kpt package of the app
 func KptRule(r *rules.PromoteRule) error {
 	config := r.Config
 	if config.Spec.KptRule == nil {
@@ -28,7 +36,6 @@ func KptRule(r *rules.PromoteRule) error {
 	if app == "" {
 		return errors.Errorf("no AppName so cannot promote via kpt")
 	}
-	version := r.Version
 
 	dir := r.Dir
 	namespaceDir := dir
@@ -37,56 +44,119 @@ func KptRule(r *rules.PromoteRule) error {
 		namespaceDir = filepath.Join(dir, kptPath)
 	}
 
-	appDir := filepath.Join(namespaceDir, app)
+	packagePath, err := evaluateTemplate(r, "packagePath", rule.PackagePath, DefaultPackagePath)
+	if err != nil {
+		return err
+	}
+	packageDir, err := evaluateTemplate(r, "dirTemplate", rule.DirTemplate, DefaultDirTemplate)
+	if err != nil {
+		return err
+	}
+	version, err := evaluateTemplate(r, "refTemplate", rule.RefTemplate, "")
+	if err != nil {
+		return err
+	}
+	if rule.RefTemplate == "" {
+		version = r.Version
+		if version != "" && !strings.HasPrefix(version, "v") {
+			version = "v" + version
+		}
+	}
+
+	appDir := filepath.Join(namespaceDir, packageDir)
 	// if the dir exists lets upgrade otherwise lets add it
 	exists, err := files.DirExists(appDir)
 	if err != nil {
 		return errors.Wrapf(err, "failed to check if the app dir exists %s", appDir)
 	}
-
-	if version != "" && !strings.HasPrefix(version, "v") {
-		version = "v" + version
-	}
 	if version == "" {
 		version = "master"
 	}
 	if r.CommandRunner == nil {
 		r.CommandRunner = cmdrunner.DefaultCommandRunner
 	}
+	if rule.UseCLI {
+		return kptCLI(r, namespaceDir, gitURL, packagePath, packageDir, version, exists)
+	}
+	if exists {
+		// lets only move the upstream directory if its configured
+		directory := ""
+		if rule.PackagePath != "" {
+			directory = "/" + packagePath
+		}
+		err = UpdatePackage(r.CommandRunner, version, directory, app, appDir)
+		if err != nil {
+			return errors.Wrapf(err, "failed to update kpt app %s", app)
+		}
+		return nil
+	}
+	upstream := &GitLock{
+		Repo:      gitRepositoryURL(gitURL),
+		Directory: "/" + packagePath,
+		Ref:       version,
+	}
+	err = GetPackage(r.CommandRunner, upstream, app, appDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the app %s via kpt", app)
+	}
+	return nil
+}
+
+// evaluateTemplate evaluates the template of the rule or the default template if its empty
+func evaluateTemplate(r *rules.PromoteRule, name string, templateText string, defaultTemplate string) (string, error) {
+	if templateText == "" {
+		templateText = defaultTemplate
+	}
+	answer, err := rules.EvaluateTemplate(r, templateText)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to evaluate %s template", name)
+	}
+	answer = strings.Trim(strings.TrimSpace(answer), "/")
+	if templateText != "" && answer == "" {
+		return "", errors.Errorf("the %s template %s evaluated to an empty string", name, templateText)
+	}
+	return answer, nil
+}
+
+// kptCLI uses the kpt binary to get or update the app
+func kptCLI(r *rules.PromoteRule, namespaceDir string, gitURL string, packagePath string, packageDir string, version string, exists bool) error {
+	app := r.AppName
 	if exists {
 		// lets upgrade the version via kpt
-		args := []string{"pkg", "update", fmt.Sprintf("%s@%s", app, version), "--strategy=alpha-git-patch"}
+		args := []string{"pkg", "update", fmt.Sprintf("%s@%s", packageDir, version), "--strategy=alpha-git-patch"}
 		c := &cmdrunner.Command{
 			Name: "kpt",
 			Args: args,
 			Dir:  namespaceDir,
 		}
 		log.Logger().Infof("running command: %s", c.String())
-		_, err = r.CommandRunner(c)
+		_, err := r.CommandRunner(c)
 		if err != nil {
 			return errors.Wrapf(err, "failed to update kpt app %s", app)
 		}
 	} else {
-		if gitURL == "" {
-			return errors.Errorf("no gitURL")
-		}
-		gitURL = strings.TrimSuffix(gitURL, "/")
-		if !strings.HasSuffix(gitURL, ".git") {
-			gitURL += ".git"
-		}
 		// lets add the path to the released kubernetes resources
-		gitURL += fmt.Sprintf("/charts/%s/resources", app)
-		args := []string{"pkg", "get", fmt.Sprintf("%s@%s", gitURL, version), app}
+		gitURL = gitRepositoryURL(gitURL) + "/" + packagePath
+		args := []string{"pkg", "get", fmt.Sprintf("%s@%s", gitURL, version), packageDir}
 		c := &cmdrunner.Command{
 			Name: "kpt",
 			Args: args,
 			Dir:  namespaceDir,
 		}
 		log.Logger().Infof("running command: %s", c.String())
-		_, err = r.CommandRunner(c)
+		_, err := r.CommandRunner(c)
 		if err != nil {
 			return errors.Wrapf(err, "failed to get the app %s via kpt", app)
 		}
 	}
 	return nil
 }
+
+// gitRepositoryURL returns the git URL ending in .git
+func gitRepositoryURL(gitURL string) string {
+	gitURL = strings.TrimSuffix(gitURL, "/")
+	if !strings.HasSuffix(gitURL, ".git") {
+		gitURL += ".git"
+	}
+	return gitURL
+}
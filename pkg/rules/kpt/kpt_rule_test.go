@@ -0,0 +1,390 @@
+package kpt_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-helpers/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/rules/kpt"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const deploymentV1 = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: myapp
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: myapp
+        image: myorg/myapp:1.2.3
+`
+
+const deploymentV2 = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: myapp
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: myapp
+        image: myorg/myapp:1.2.4
+        env:
+        - name: FOO
+          value: bar
+`
+
+func TestKptRule(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	createUpstreamRepository(t, tmpDir, "charts/myapp/resources", "v")
+
+	envDir := filepath.Join(tmpDir, "env")
+	require.NoError(t, os.MkdirAll(envDir, files.DefaultDirWritePermissions))
+
+	r := &rules.PromoteRule{
+		TemplateContext: rules.TemplateContext{
+			GitURL:  filepath.Join(tmpDir, "myapp"),
+			Version: "1.2.3",
+			AppName: "myapp",
+		},
+		Dir: envDir,
+		Config: v1alpha1.Promote{
+			Spec: v1alpha1.PromoteSpec{
+				RuleSpec: v1alpha1.RuleSpec{
+					KptRule: &v1alpha1.KptRule{
+						Path: "config-root",
+					},
+				},
+			},
+		},
+		CommandRunner: cmdrunner.QuietCommandRunner,
+	}
+
+	err = kpt.KptRule(r)
+	require.NoError(t, err, "failed to get the package")
+
+	appDir := filepath.Join(envDir, "config-root", "myapp")
+	deploymentFile := filepath.Join(appDir, "deployment.yaml")
+	assertFileText(t, deploymentV1, deploymentFile)
+	assertUpstream(t, appDir, "v1.2.3", "/charts/myapp/resources")
+
+	// lets make local changes which should be retained on update
+	writeFile(t, deploymentFile, `# local changes
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: myapp
+spec:
+  replicas: 3 # scaled up locally
+
+  template:
+    spec:
+      containers:
+      - name: myapp
+        image: myorg/myapp:1.2.3
+`)
+	writeFile(t, filepath.Join(appDir, "configmap.yaml"), "apiVersion: v1\nkind: ConfigMap\n")
+
+	r.Version = "1.2.4"
+	err = kpt.KptRule(r)
+	require.NoError(t, err, "failed to update the package")
+
+	assertFileText(t, `# local changes
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: myapp
+spec:
+  replicas: 3 # scaled up locally
+
+  template:
+    spec:
+      containers:
+      - name: myapp
+        image: myorg/myapp:1.2.4
+        env:
+        - name: FOO
+          value: bar
+`, deploymentFile)
+	assert.FileExists(t, filepath.Join(appDir, "configmap.yaml"))
+	assertFileText(t, "# myapp\n", filepath.Join(appDir, "README.md"))
+	assertUpstream(t, appDir, "v1.2.4", "/charts/myapp/resources")
+
+	version, err := (&kpt.Rule{}).Read(r)
+	require.NoError(t, err, "failed to read the version")
+	assert.Equal(t, "1.2.4", version, "version read")
+
+	err = kpt.RemoveKptRule(r)
+	require.NoError(t, err, "failed to remove the package")
+	assert.NoDirExists(t, appDir)
+}
+
+func TestKptRuleTemplates(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	createUpstreamRepository(t, tmpDir, "deploy/myapp/manifests", "release-")
+
+	envDir := filepath.Join(tmpDir, "env")
+	require.NoError(t, os.MkdirAll(envDir, files.DefaultDirWritePermissions))
+
+	r := &rules.PromoteRule{
+		TemplateContext: rules.TemplateContext{
+			GitURL:  filepath.Join(tmpDir, "myapp"),
+			Version: "1.2.3",
+			AppName: "myapp",
+		},
+		Dir: envDir,
+		Config: v1alpha1.Promote{
+			Spec: v1alpha1.PromoteSpec{
+				RuleSpec: v1alpha1.RuleSpec{
+					KptRule: &v1alpha1.KptRule{
+						PackagePath: "deploy/{{ .AppName }}/manifests",
+						RefTemplate: "release-{{ .Version }}",
+						DirTemplate: "{{ .AppName }}-pkg",
+					},
+				},
+			},
+		},
+		CommandRunner: cmdrunner.QuietCommandRunner,
+	}
+
+	err = kpt.KptRule(r)
+	require.NoError(t, err, "failed to get the package")
+
+	appDir := filepath.Join(envDir, "myapp-pkg")
+	assertFileText(t, deploymentV1, filepath.Join(appDir, "deployment.yaml"))
+	assertUpstream(t, appDir, "release-1.2.3", "/deploy/myapp/manifests")
+
+	r.Version = "1.2.4"
+	err = kpt.KptRule(r)
+	require.NoError(t, err, "failed to update the package")
+
+	assertFileText(t, deploymentV2, filepath.Join(appDir, "deployment.yaml"))
+	assertUpstream(t, appDir, "release-1.2.4", "/deploy/myapp/manifests")
+
+	version, err := (&kpt.Rule{}).Read(r)
+	require.NoError(t, err, "failed to read the version")
+	assert.Equal(t, "1.2.4", version, "version read")
+}
+
+func TestKptRuleV1Alpha1Kptfile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	createUpstreamRepository(t, tmpDir, "charts/myapp/resources", "v")
+
+	envDir := filepath.Join(tmpDir, "env")
+	require.NoError(t, os.MkdirAll(envDir, files.DefaultDirWritePermissions))
+
+	r := &rules.PromoteRule{
+		TemplateContext: rules.TemplateContext{
+			GitURL:  filepath.Join(tmpDir, "myapp"),
+			Version: "1.2.3",
+			AppName: "myapp",
+		},
+		Dir: envDir,
+		Config: v1alpha1.Promote{
+			Spec: v1alpha1.PromoteSpec{
+				RuleSpec: v1alpha1.RuleSpec{
+					KptRule: &v1alpha1.KptRule{},
+				},
+			},
+		},
+		CommandRunner: cmdrunner.QuietCommandRunner,
+	}
+
+	err = kpt.KptRule(r)
+	require.NoError(t, err, "failed to get the package")
+
+	// lets replace the Kptfile with one created by kpt 0.x
+	appDir := filepath.Join(envDir, "myapp")
+	upstream, err := kpt.LoadUpstream(appDir)
+	require.NoError(t, err, "failed to load the Kptfile in %s", appDir)
+	writeFile(t, filepath.Join(appDir, kpt.KptfileName), `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: myapp
+upstream:
+  type: git
+  git:
+    commit: `+upstream.Commit+`
+    repo: `+upstream.Repo+`
+    directory: /charts/myapp/resources
+    ref: v1.2.3
+`)
+
+	r.Version = "1.2.4"
+	err = kpt.KptRule(r)
+	require.NoError(t, err, "failed to update the package")
+
+	assertFileText(t, deploymentV2, filepath.Join(appDir, "deployment.yaml"))
+
+	docs, err := yamlnodes.LoadFile(filepath.Join(appDir, kpt.KptfileName))
+	require.NoError(t, err, "failed to load the Kptfile in %s", appDir)
+	root := yamlnodes.Root(docs[0])
+	assert.Equal(t, kpt.KptfileAPIVersionV1Alpha1, yamlnodes.GetMapString(root, "apiVersion"), "apiVersion")
+	assert.Nil(t, yamlnodes.GetMapValue(root, "upstreamLock"), "upstreamLock")
+	assert.Equal(t, "v1.2.4", yamlnodes.GetPathString(root, "upstream", "git", "ref"), "upstream ref")
+	commit := yamlnodes.GetPathString(root, "upstream", "git", "commit")
+	assert.NotEmpty(t, commit, "upstream commit")
+	assert.NotEqual(t, upstream.Commit, commit, "upstream commit")
+
+	version, err := (&kpt.Rule{}).Read(r)
+	require.NoError(t, err, "failed to read the version")
+	assert.Equal(t, "1.2.4", version, "version read")
+}
+
+func TestKptRuleRejectsOptions(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	createUpstreamRepository(t, tmpDir, "charts/myapp/resources", "v")
+
+	testCases := []struct {
+		gitURL      string
+		refTemplate string
+		expected    string
+	}{
+		{
+			gitURL:      filepath.Join(tmpDir, "myapp"),
+			refTemplate: "--upload-pack=touch " + filepath.Join(tmpDir, "pwned") + " {{ .Version }}",
+			expected:    "invalid git ref --upload-pack",
+		},
+		{
+			gitURL:   "--upload-pack=touch " + filepath.Join(tmpDir, "pwned"),
+			expected: "invalid git repository --upload-pack",
+		},
+	}
+	for i, tc := range testCases {
+		envDir := filepath.Join(tmpDir, "env", fmt.Sprintf("%d", i))
+		require.NoError(t, os.MkdirAll(envDir, files.DefaultDirWritePermissions))
+
+		r := &rules.PromoteRule{
+			TemplateContext: rules.TemplateContext{
+				GitURL:  tc.gitURL,
+				Version: "1.2.3",
+				AppName: "myapp",
+			},
+			Dir: envDir,
+			Config: v1alpha1.Promote{
+				Spec: v1alpha1.PromoteSpec{
+					RuleSpec: v1alpha1.RuleSpec{
+						KptRule: &v1alpha1.KptRule{
+							RefTemplate: tc.refTemplate,
+						},
+					},
+				},
+			},
+			CommandRunner: cmdrunner.QuietCommandRunner,
+		}
+
+		err = kpt.KptRule(r)
+		require.Error(t, err, "expected an error for git URL %s and ref template %s", tc.gitURL, tc.refTemplate)
+		assert.Contains(t, err.Error(), tc.expected)
+		assert.NoFileExists(t, filepath.Join(tmpDir, "pwned"))
+	}
+}
+
+func TestMergeDirsConflict(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	baseDir := filepath.Join(tmpDir, "base")
+	updateDir := filepath.Join(tmpDir, "update")
+	localDir := filepath.Join(tmpDir, "local")
+	for _, dir := range []string{baseDir, updateDir, localDir} {
+		require.NoError(t, os.MkdirAll(dir, files.DefaultDirWritePermissions))
+	}
+	writeFile(t, filepath.Join(baseDir, "deployment.yaml"), deploymentV1)
+	writeFile(t, filepath.Join(updateDir, "deployment.yaml"), deploymentV2)
+	local := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: myapp
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: myapp
+        image: myorg/myapp:1.0.0-local
+`
+	writeFile(t, filepath.Join(localDir, "deployment.yaml"), local)
+
+	err = kpt.MergeDirs(baseDir, updateDir, localDir)
+	require.Error(t, err, "expected a merge conflict")
+	assert.Contains(t, err.Error(), "spec.template.spec.containers[myapp].image")
+	assertFileText(t, local, filepath.Join(localDir, "deployment.yaml"))
+}
+
+// createUpstreamRepository creates a git repository for the app with the package at the path in 2 tagged releases
+func createUpstreamRepository(t *testing.T, tmpDir string, path string, tagPrefix string) {
+	repoDir := filepath.Join(tmpDir, "myapp.git")
+	resourcesDir := filepath.Join(repoDir, filepath.FromSlash(path))
+	require.NoError(t, os.MkdirAll(resourcesDir, files.DefaultDirWritePermissions))
+	runGit(t, repoDir, "init", "--quiet")
+	writeFile(t, filepath.Join(resourcesDir, "deployment.yaml"), deploymentV1)
+	writeFile(t, filepath.Join(resourcesDir, "README.md"), "# myapp\n")
+	commitAndTag(t, repoDir, tagPrefix+"1.2.3")
+	writeFile(t, filepath.Join(resourcesDir, "deployment.yaml"), deploymentV2)
+	commitAndTag(t, repoDir, tagPrefix+"1.2.4")
+}
+
+func assertUpstream(t *testing.T, dir string, ref string, directory string) {
+	upstream, err := kpt.LoadUpstream(dir)
+	require.NoError(t, err, "failed to load the Kptfile in %s", dir)
+	require.NotNil(t, upstream, "no Kptfile in %s", dir)
+	assert.Equal(t, ref, upstream.Ref, "upstream ref")
+	assert.Equal(t, directory, upstream.Directory, "upstream directory")
+	assert.NotEmpty(t, upstream.Commit, "upstream commit")
+
+	docs, err := yamlnodes.LoadFile(filepath.Join(dir, kpt.KptfileName))
+	require.NoError(t, err, "failed to load the Kptfile in %s", dir)
+	assert.Equal(t, ref, yamlnodes.GetPathString(yamlnodes.Root(docs[0]), "upstreamLock", "git", "ref"), "upstreamLock ref")
+}
+
+func assertFileText(t *testing.T, expected string, file string) {
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err, "failed to read file %s", file)
+	assert.Equal(t, expected, string(data), "contents of file %s", file)
+}
+
+func writeFile(t *testing.T, file string, text string) {
+	err := ioutil.WriteFile(file, []byte(text), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save file %s", file)
+}
+
+func commitAndTag(t *testing.T, dir string, tag string) {
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "release "+tag)
+	runGit(t, dir, "tag", tag)
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	_, err := cmdrunner.QuietCommandRunner(&cmdrunner.Command{
+		Name: "git",
+		Args: args,
+		Dir:  dir,
+	})
+	require.NoError(t, err, "failed to run git %v in %s", args, dir)
+}
@@ -0,0 +1,137 @@
+package kpt
+
+import (
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// KptfileName the name of the file containing the kpt package metadata
+	KptfileName = "Kptfile"
+
+	// KptfileAPIVersion the API version of new Kptfiles
+	KptfileAPIVersion = "kpt.dev/v1"
+
+	// KptfileAPIVersionV1Alpha1 the API version of Kptfiles created by kpt 0.x which keep the commit in the upstream
+	KptfileAPIVersionV1Alpha1 = "kpt.dev/v1alpha1"
+
+	// DefaultUpdateStrategy the default update strategy of new Kptfiles
+	DefaultUpdateStrategy = "resource-merge"
+)
+
+// GitLock the git repository, directory, ref and commit of a package
+type GitLock struct {
+	Repo      string
+	Directory string
+	Ref       string
+	Commit    string
+}
+
+// LoadUpstream loads the upstream of the package in the dir returning nil if there is no Kptfile.
+//
+// The commit comes from the upstreamLock or from the upstream of older Kptfiles
+func LoadUpstream(dir string) (*GitLock, error) {
+	file := filepath.Join(dir, KptfileName)
+	exists, err := files.FileExists(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if file exists %s", file)
+	}
+	if !exists {
+		return nil, nil
+	}
+	docs, err := yamlnodes.LoadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, errors.Errorf("no YAML document in %s", file)
+	}
+	root := yamlnodes.Root(docs[0])
+	git := yamlnodes.GetPath(root, "upstream", "git")
+	if git == nil {
+		return nil, errors.Errorf("no upstream.git found in %s", file)
+	}
+	answer := &GitLock{
+		Repo:      yamlnodes.GetMapString(git, "repo"),
+		Directory: yamlnodes.GetMapString(git, "directory"),
+		Ref:       yamlnodes.GetMapString(git, "ref"),
+		Commit:    yamlnodes.GetMapString(git, "commit"),
+	}
+	lock := yamlnodes.GetPath(root, "upstreamLock", "git")
+	if lock != nil {
+		answer.Commit = yamlnodes.GetMapString(lock, "commit")
+		if answer.Ref == "" {
+			answer.Ref = yamlnodes.GetMapString(lock, "ref")
+		}
+	}
+	if answer.Repo == "" {
+		return nil, errors.Errorf("no upstream.git.repo found in %s", file)
+	}
+	return answer, nil
+}
+
+// SaveUpstream saves the upstream and upstreamLock of the Kptfile in the dir creating it if it does not exist.
+// The API version and any other content of an existing Kptfile is retained. Kptfiles using the v1alpha1 schema of
+// kpt 0.x have their upstream commit updated as they have no upstreamLock
+func SaveUpstream(dir string, name string, lock *GitLock) error {
+	file := filepath.Join(dir, KptfileName)
+	exists, err := files.FileExists(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", file)
+	}
+	var docs []*yaml.Node
+	if exists {
+		docs, err = yamlnodes.LoadFile(file)
+		if err != nil {
+			return err
+		}
+	}
+	if len(docs) == 0 {
+		docs = []*yaml.Node{{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}}
+	}
+	root := yamlnodes.Root(docs[0])
+	if root.Kind != yaml.MappingNode {
+		return errors.Errorf("file %s does not contain a YAML object", file)
+	}
+
+	apiVersion := yamlnodes.GetMapString(root, "apiVersion")
+	if apiVersion == "" {
+		apiVersion = KptfileAPIVersion
+		yamlnodes.SetMapString(root, "apiVersion", apiVersion)
+	}
+	yamlnodes.SetMapString(root, "kind", "Kptfile")
+	metadata := yamlnodes.EnsureMapValue(root, yaml.MappingNode, "metadata")
+	if yamlnodes.GetMapString(metadata, "name") == "" {
+		yamlnodes.SetMapString(metadata, "name", name)
+		yamlnodes.SetPathString(metadata, "true", "annotations", "config.kubernetes.io/local-config")
+	}
+
+	upstream := yamlnodes.EnsureMapValue(root, yaml.MappingNode, "upstream")
+	yamlnodes.SetMapString(upstream, "type", "git")
+	git := yamlnodes.EnsureMapValue(upstream, yaml.MappingNode, "git")
+	yamlnodes.SetMapString(git, "repo", lock.Repo)
+	yamlnodes.SetMapString(git, "directory", lock.Directory)
+	yamlnodes.SetMapString(git, "ref", lock.Ref)
+	if apiVersion == KptfileAPIVersionV1Alpha1 {
+		yamlnodes.SetMapString(git, "commit", lock.Commit)
+		return yamlnodes.EditFile(file, docs)
+	}
+	// lets move the commit of Kptfiles which have been upgraded from v1alpha1 into the upstreamLock
+	yamlnodes.RemoveMapKey(git, "commit")
+	if yamlnodes.GetMapString(upstream, "updateStrategy") == "" {
+		yamlnodes.SetMapString(upstream, "updateStrategy", DefaultUpdateStrategy)
+	}
+
+	upstreamLock := yamlnodes.EnsureMapValue(root, yaml.MappingNode, "upstreamLock")
+	yamlnodes.SetMapString(upstreamLock, "type", "git")
+	gitLock := yamlnodes.EnsureMapValue(upstreamLock, yaml.MappingNode, "git")
+	yamlnodes.SetMapString(gitLock, "repo", lock.Repo)
+	yamlnodes.SetMapString(gitLock, "directory", lock.Directory)
+	yamlnodes.SetMapString(gitLock, "ref", lock.Ref)
+	yamlnodes.SetMapString(gitLock, "commit", lock.Commit)
+	return yamlnodes.EditFile(file, docs)
+}
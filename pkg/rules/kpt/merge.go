@@ -0,0 +1,526 @@
+package kpt
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// MergeDirs performs a three way merge of the changes between the base and update dirs into the local dir.
+//
+// Files changed only upstream are replaced, files changed only locally are kept and YAML resources changed in both are
+// merged field by field. It is an error if the same value is changed both upstream and locally
+func MergeDirs(baseDir string, updateDir string, localDir string) error {
+	names := map[string]bool{}
+	for _, dir := range []string{baseDir, updateDir} {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			// the Kptfile upstream is updated separately
+			if rel != KptfileName {
+				names[rel] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to find files in %s", dir)
+		}
+	}
+
+	var fileNames []string
+	for name := range names {
+		fileNames = append(fileNames, name)
+	}
+	sort.Strings(fileNames)
+
+	// lets merge all the files before modifying any so we don't leave a partially merged package on conflicts
+	results := map[string][]byte{}
+	for _, name := range fileNames {
+		base, err := readOptionalFile(filepath.Join(baseDir, name))
+		if err != nil {
+			return err
+		}
+		update, err := readOptionalFile(filepath.Join(updateDir, name))
+		if err != nil {
+			return err
+		}
+		local, err := readOptionalFile(filepath.Join(localDir, name))
+		if err != nil {
+			return err
+		}
+		result, err := mergeFile(name, base, update, local)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(result, local) || (result == nil) != (local == nil) {
+			results[name] = result
+		}
+	}
+
+	for _, name := range fileNames {
+		result, ok := results[name]
+		if !ok {
+			continue
+		}
+		path := filepath.Join(localDir, name)
+		if result == nil {
+			err := os.Remove(path)
+			if err != nil {
+				return errors.Wrapf(err, "failed to remove file %s", path)
+			}
+			continue
+		}
+		err := os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create dir for %s", path)
+		}
+		err = ioutil.WriteFile(path, result, files.DefaultFileWritePermissions)
+		if err != nil {
+			return errors.Wrapf(err, "failed to save file %s", path)
+		}
+	}
+	return nil
+}
+
+// readOptionalFile returns the contents of the file or nil if it does not exist
+func readOptionalFile(path string) ([]byte, error) {
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if !exists {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read file %s", path)
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	return data, nil
+}
+
+// mergeFile merges the file contents where nil means the file does not exist
+func mergeFile(name string, base []byte, update []byte, local []byte) ([]byte, error) {
+	switch {
+	case sameFile(local, update):
+		return local, nil
+	case sameFile(base, local):
+		return update, nil
+	case sameFile(base, update):
+		return local, nil
+	case update == nil:
+		log.Logger().Warnf("keeping file %s as it was modified locally but removed upstream", name)
+		return local, nil
+	case local == nil:
+		log.Logger().Warnf("not adding file %s as it was removed locally but modified upstream", name)
+		return nil, nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext != ".yaml" && ext != ".yml" {
+		return nil, errors.Errorf("conflict merging file %s as it was modified both locally and upstream", name)
+	}
+	result, err := mergeResources(base, update, local)
+	if err != nil {
+		return nil, errors.Wrapf(err, "conflict merging file %s", name)
+	}
+	return result, nil
+}
+
+func sameFile(a []byte, b []byte) bool {
+	return (a == nil) == (b == nil) && bytes.Equal(a, b)
+}
+
+// mergeResources merges the YAML documents matching them by their kind, namespace and name. The local source is
+// edited in place so that the comments and formatting of the local file are retained
+func mergeResources(base []byte, update []byte, local []byte) ([]byte, error) {
+	baseDocs, err := parseResources(base)
+	if err != nil {
+		return nil, err
+	}
+	updateDocs, err := parseResources(update)
+	if err != nil {
+		return nil, err
+	}
+	editor, err := yamlnodes.NewEditor(local)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse YAML")
+	}
+	localDocs, err := indexResources(editor.Docs)
+	if err != nil {
+		return nil, err
+	}
+
+	var answer []*yaml.Node
+	merged := map[string]*yaml.Node{}
+	for _, key := range localDocs.keys {
+		localDoc := localDocs.docs[key]
+		baseDoc := baseDocs.docs[key]
+		updateDoc, inUpdate := updateDocs.docs[key]
+		if baseDoc != nil && !inUpdate {
+			// removed upstream so lets remove it unless it was modified locally
+			if !equalNodes(baseDoc, localDoc) {
+				answer = append(answer, localDoc)
+				merged[key] = localDoc
+			}
+			continue
+		}
+		node, err := mergeNodes(key, baseDoc, updateDoc, localDoc)
+		if err != nil {
+			return nil, err
+		}
+		answer = append(answer, node)
+		merged[key] = node
+	}
+	var added []*yaml.Node
+	for _, key := range updateDocs.keys {
+		_, inLocal := localDocs.docs[key]
+		_, inBase := baseDocs.docs[key]
+		// lets add new upstream resources but not ones removed locally
+		if !inLocal && !inBase {
+			answer = append(answer, updateDocs.docs[key])
+			added = append(added, updateDocs.docs[key])
+		}
+	}
+
+	result, err := editResources(editor, localDocs, merged, added)
+	if err == nil {
+		return result, nil
+	}
+	log.Logger().Debugf("reformatting the merged resources as the local source could not be edited: %s", err.Error())
+	var docs []*yaml.Node
+	for _, node := range answer {
+		docs = append(docs, &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{node}})
+	}
+	return yamlnodes.ToBytes(docs)
+}
+
+// editResources edits the source of the local resources to match the merged resources appending any added resources
+func editResources(editor *yamlnodes.Editor, localDocs *resources, merged map[string]*yaml.Node, added []*yaml.Node) ([]byte, error) {
+	for _, key := range localDocs.keys {
+		node := merged[key]
+		if node == nil {
+			return nil, errors.Errorf("cannot remove the resource %s from the source", key)
+		}
+		err := editNode(editor, key, nil, "", localDocs.docs[key], node)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result := editor.Bytes()
+	if len(added) == 0 {
+		return result, nil
+	}
+	var docs []*yaml.Node
+	for _, node := range added {
+		docs = append(docs, &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{node}})
+	}
+	data, err := yamlnodes.ToBytes(docs)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) > 0 && !bytes.HasSuffix(result, []byte("\n")) {
+		result = append(result, '\n')
+	}
+	result = append(result, []byte("---\n")...)
+	return append(result, data...), nil
+}
+
+// editNode edits the source of the local node so that it matches the merged node. The parent is the mapping which
+// contains the local node as the value of the key, if any
+func editNode(editor *yamlnodes.Editor, path string, parent *yaml.Node, key string, local *yaml.Node, merged *yaml.Node) error {
+	if equalNodes(local, merged) {
+		return nil
+	}
+	if local.Kind != merged.Kind || local.Style&yaml.FlowStyle != 0 {
+		return errors.Errorf("cannot edit %s in the source", path)
+	}
+	switch local.Kind {
+	case yaml.ScalarNode:
+		return editor.SetScalar(local, merged.Value)
+	case yaml.MappingNode:
+		return editMap(editor, path, local, merged)
+	case yaml.SequenceNode:
+		return editSequence(editor, path, parent, key, local, merged)
+	default:
+		return errors.Errorf("cannot edit %s in the source", path)
+	}
+}
+
+func editMap(editor *yamlnodes.Editor, path string, local *yaml.Node, merged *yaml.Node) error {
+	if len(merged.Content) == 0 {
+		return errors.Errorf("cannot remove all the keys of %s in the source", path)
+	}
+	for i := 0; i+1 < len(local.Content); i += 2 {
+		key := local.Content[i].Value
+		value := yamlnodes.GetMapValue(merged, key)
+		var err error
+		if value == nil {
+			err = editor.DeleteMapKey(local, key)
+		} else {
+			err = editNode(editor, path+"."+key, local, key, local.Content[i+1], value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for i := 0; i+1 < len(merged.Content); i += 2 {
+		key := merged.Content[i].Value
+		if yamlnodes.GetMapValue(local, key) == nil {
+			err := editor.AddMapValue(local, key, merged.Content[i+1])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// editSequence edits the items of the local sequence matching them by name if they are named otherwise by index
+func editSequence(editor *yamlnodes.Editor, path string, parent *yaml.Node, key string, local *yaml.Node, merged *yaml.Node) error {
+	localItems, ok1 := namedItems(local)
+	mergedItems, ok2 := namedItems(merged)
+	var deleted []int
+	var appended []*yaml.Node
+	if ok1 && ok2 {
+		for i, item := range local.Content {
+			name := yamlnodes.GetMapString(item, "name")
+			mergedItem := mergedItems[name]
+			if mergedItem == nil {
+				deleted = append(deleted, i)
+				continue
+			}
+			err := editNode(editor, path+"["+name+"]", nil, "", item, mergedItem)
+			if err != nil {
+				return err
+			}
+		}
+		for _, item := range merged.Content {
+			if localItems[yamlnodes.GetMapString(item, "name")] == nil {
+				appended = append(appended, item)
+			}
+		}
+	} else {
+		for i, item := range local.Content {
+			if i >= len(merged.Content) {
+				deleted = append(deleted, i)
+				continue
+			}
+			err := editNode(editor, fmt.Sprintf("%s[%d]", path, i), nil, "", item, merged.Content[i])
+			if err != nil {
+				return err
+			}
+		}
+		if len(merged.Content) > len(local.Content) {
+			appended = merged.Content[len(local.Content):]
+		}
+	}
+
+	if len(deleted) > 0 {
+		if parent == nil || len(deleted) == len(local.Content) {
+			return errors.Errorf("cannot remove the items of %s in the source", path)
+		}
+		err := editor.DeleteSequenceItems(parent, key, deleted)
+		if err != nil {
+			return err
+		}
+	}
+	for _, item := range appended {
+		err := editor.AppendSequence(local, item)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resources the YAML documents of a file indexed by their kind, namespace and name
+type resources struct {
+	keys []string
+	docs map[string]*yaml.Node
+}
+
+func parseResources(data []byte) (*resources, error) {
+	docs, err := yamlnodes.Parse(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse YAML")
+	}
+	return indexResources(docs)
+}
+
+func indexResources(docs []*yaml.Node) (*resources, error) {
+	answer := &resources{docs: map[string]*yaml.Node{}}
+	for _, doc := range docs {
+		node := yamlnodes.Root(doc)
+		if node == nil || node.Kind != yaml.MappingNode {
+			continue
+		}
+		key := strings.Join([]string{
+			yamlnodes.GetMapString(node, "kind"),
+			yamlnodes.GetPathString(node, "metadata", "namespace"),
+			yamlnodes.GetPathString(node, "metadata", "name"),
+		}, "/")
+		if answer.docs[key] != nil {
+			return nil, errors.Errorf("duplicate resource %s", key)
+		}
+		answer.keys = append(answer.keys, key)
+		answer.docs[key] = node
+	}
+	return answer, nil
+}
+
+// mergeNodes merges the update and local changes to the base node where nil means the node does not exist
+func mergeNodes(path string, base *yaml.Node, update *yaml.Node, local *yaml.Node) (*yaml.Node, error) {
+	switch {
+	case equalNodes(local, update):
+		return local, nil
+	case equalNodes(base, local):
+		return update, nil
+	case equalNodes(base, update):
+		return local, nil
+	case update != nil && local != nil && update.Kind == yaml.MappingNode && local.Kind == yaml.MappingNode:
+		return mergeMaps(path, base, update, local)
+	case update != nil && local != nil && update.Kind == yaml.SequenceNode && local.Kind == yaml.SequenceNode:
+		return mergeNamedSequences(path, base, update, local)
+	default:
+		return nil, errors.Errorf("%s was modified both locally and upstream", path)
+	}
+}
+
+func mergeMaps(path string, base *yaml.Node, update *yaml.Node, local *yaml.Node) (*yaml.Node, error) {
+	if base != nil && base.Kind != yaml.MappingNode {
+		base = nil
+	}
+	answer := &yaml.Node{Kind: yaml.MappingNode, Tag: local.Tag, Style: local.Style}
+	for i := 0; i+1 < len(local.Content); i += 2 {
+		key := local.Content[i]
+		value, err := mergeNodes(path+"."+key.Value, yamlnodes.GetMapValue(base, key.Value), yamlnodes.GetMapValue(update, key.Value), local.Content[i+1])
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			answer.Content = append(answer.Content, key, value)
+		}
+	}
+	for i := 0; i+1 < len(update.Content); i += 2 {
+		key := update.Content[i]
+		if yamlnodes.GetMapValue(local, key.Value) != nil {
+			continue
+		}
+		value, err := mergeNodes(path+"."+key.Value, yamlnodes.GetMapValue(base, key.Value), update.Content[i+1], nil)
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			answer.Content = append(answer.Content, key, value)
+		}
+	}
+	return answer, nil
+}
+
+// mergeNamedSequences merges sequences of objects with a name such as containers, ports or env vars
+func mergeNamedSequences(path string, base *yaml.Node, update *yaml.Node, local *yaml.Node) (*yaml.Node, error) {
+	baseItems, ok := namedItems(base)
+	if base != nil && (!ok || base.Kind != yaml.SequenceNode) {
+		baseItems = map[string]*yaml.Node{}
+	}
+	updateItems, ok1 := namedItems(update)
+	localItems, ok2 := namedItems(local)
+	if !ok1 || !ok2 {
+		return nil, errors.Errorf("%s was modified both locally and upstream", path)
+	}
+
+	answer := &yaml.Node{Kind: yaml.SequenceNode, Tag: local.Tag, Style: local.Style}
+	for _, item := range local.Content {
+		name := yamlnodes.GetMapString(item, "name")
+		baseItem := baseItems[name]
+		updateItem := updateItems[name]
+		if baseItem != nil && updateItem == nil && equalNodes(baseItem, item) {
+			// removed upstream and not modified locally
+			continue
+		}
+		merged, err := mergeNodes(path+"["+name+"]", baseItem, updateItem, item)
+		if err != nil {
+			return nil, err
+		}
+		if merged != nil {
+			answer.Content = append(answer.Content, merged)
+		}
+	}
+	for _, item := range update.Content {
+		name := yamlnodes.GetMapString(item, "name")
+		if localItems[name] == nil && baseItems[name] == nil {
+			answer.Content = append(answer.Content, item)
+		}
+	}
+	return answer, nil
+}
+
+// namedItems indexes the items of a sequence by name returning false if any item does not have a unique name
+func namedItems(node *yaml.Node) (map[string]*yaml.Node, bool) {
+	answer := map[string]*yaml.Node{}
+	if node == nil {
+		return answer, true
+	}
+	for _, item := range node.Content {
+		name := yamlnodes.GetMapString(item, "name")
+		if name == "" || answer[name] != nil {
+			return nil, false
+		}
+		answer[name] = item
+	}
+	return answer, true
+}
+
+// equalNodes returns true if the nodes have the same value ignoring comments and formatting
+func equalNodes(a *yaml.Node, b *yaml.Node) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Kind == yaml.AliasNode {
+		a = a.Alias
+	}
+	if b.Kind == yaml.AliasNode {
+		b = b.Alias
+	}
+	if a.Kind != b.Kind || len(a.Content) != len(b.Content) {
+		return false
+	}
+	if a.Kind == yaml.ScalarNode {
+		return a.Value == b.Value && a.ShortTag() == b.ShortTag()
+	}
+	if a.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(a.Content); i += 2 {
+			if !equalNodes(a.Content[i+1], yamlnodes.GetMapValue(b, a.Content[i].Value)) {
+				return false
+			}
+		}
+		return true
+	}
+	for i := range a.Content {
+		if !equalNodes(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package kpt
+
+import (
+	"os"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/pkg/errors"
+)
+
+// RemoveKptRule removes the kpt package dir of the app
+func RemoveKptRule(r *rules.PromoteRule) error {
+	config := r.Config
+	if config.Spec.KptRule == nil {
+		return errors.Errorf("no kptRule configured")
+	}
+	rule := config.Spec.KptRule
+	if r.AppName == "" {
+		return errors.Errorf("no AppName so cannot remove via kpt")
+	}
+
+	appDir, err := localPackageDir(r, rule)
+	if err != nil {
+		return err
+	}
+	exists, err := files.DirExists(appDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if the app dir exists %s", appDir)
+	}
+	if !exists {
+		log.Logger().Infof("no kpt package found at %s", termcolor.ColorInfo(appDir))
+		return nil
+	}
+	err = os.RemoveAll(appDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove dir %s", appDir)
+	}
+	log.Logger().Infof("removed dir %s", termcolor.ColorInfo(appDir))
+	return nil
+}
@@ -0,0 +1,132 @@
+package kpt
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/pkg/errors"
+)
+
+// Rule promotes apps by fetching or updating their kpt package
+type Rule struct{}
+
+var _ rules.Rule = &Rule{}
+var _ rules.Lister = &Rule{}
+
+// Validate validates the kptRule and its templates
+func (*Rule) Validate(r *rules.PromoteRule) error {
+	rule := r.Config.Spec.KptRule
+	if rule == nil {
+		return errors.Errorf("no kptRule configured")
+	}
+	names := []string{"packagePath", "refTemplate", "dirTemplate"}
+	for i, text := range []string{rule.PackagePath, rule.RefTemplate, rule.DirTemplate} {
+		_, err := rules.EvaluateTemplate(r, text)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s template", names[i])
+		}
+	}
+	return nil
+}
+
+// Read returns the version of the app from the upstream ref of its kpt package
+func (*Rule) Read(r *rules.PromoteRule) (string, error) {
+	rule := r.Config.Spec.KptRule
+	if rule == nil {
+		return "", errors.Errorf("no kptRule configured")
+	}
+	appDir, err := localPackageDir(r, rule)
+	if err != nil {
+		return "", err
+	}
+	upstream, err := LoadUpstream(appDir)
+	if err != nil {
+		return "", err
+	}
+	if upstream == nil {
+		return "", nil
+	}
+	ref := upstream.Ref
+	if rule.RefTemplate == "" {
+		return strings.TrimPrefix(ref, "v"), nil
+	}
+	version, err := rules.ExtractVersion(r, rule.RefTemplate, ref)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find the version in ref %s", ref)
+	}
+	if version == "" {
+		return ref, nil
+	}
+	return version, nil
+}
+
+// List returns the versions of the kpt packages in the environment. Packages can only be listed when the default
+// dirTemplate is used as otherwise the app name cannot be found from the package dir
+func (rule *Rule) List(r *rules.PromoteRule) (map[string]string, error) {
+	config := r.Config.Spec.KptRule
+	if config == nil {
+		return nil, errors.Errorf("no kptRule configured")
+	}
+	if config.DirTemplate != "" && config.DirTemplate != DefaultDirTemplate {
+		return nil, nil
+	}
+	namespaceDir := r.Dir
+	if config.Path != "" {
+		namespaceDir = filepath.Join(namespaceDir, config.Path)
+	}
+	exists, err := files.DirExists(namespaceDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if dir exists %s", namespaceDir)
+	}
+	if !exists {
+		return nil, nil
+	}
+	fileInfos, err := ioutil.ReadDir(namespaceDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read dir %s", namespaceDir)
+	}
+
+	answer := map[string]string{}
+	for _, f := range fileInfos {
+		if !f.IsDir() {
+			continue
+		}
+		rc := *r
+		rc.AppName = f.Name()
+		version, err := rule.Read(&rc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the kpt package %s", f.Name())
+		}
+		if version != "" {
+			answer[f.Name()] = version
+		}
+	}
+	return answer, nil
+}
+
+// Apply promotes the version of the app
+func (*Rule) Apply(r *rules.PromoteRule) error {
+	return KptRule(r)
+}
+
+// Remove removes the app
+func (*Rule) Remove(r *rules.PromoteRule) error {
+	return RemoveKptRule(r)
+}
+
+// localPackageDir returns the dir of the kpt package of the app in the environment
+func localPackageDir(r *rules.PromoteRule, rule *v1alpha1.KptRule) (string, error) {
+	namespaceDir := r.Dir
+	if rule.Path != "" {
+		namespaceDir = filepath.Join(namespaceDir, rule.Path)
+	}
+	dir, err := evaluateTemplate(r, "dirTemplate", rule.DirTemplate, DefaultDirTemplate)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(namespaceDir, dir), nil
+}
@@ -0,0 +1,167 @@
+package kustomize
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultPath the default name of the kustomization file
+	DefaultPath = "kustomization.yaml"
+
+	digestPrefix = "sha256:"
+)
+
+// KustomizeRule uses a kustomization.yaml file
+func KustomizeRule(r *rules.PromoteRule) error {
+	config := r.Config
+	if config.Spec.KustomizeRule == nil {
+		return errors.Errorf("no kustomizeRule configured")
+	}
+	rule := config.Spec.KustomizeRule
+	path := rule.Path
+	if path == "" {
+		path = DefaultPath
+	}
+
+	err := modifyKustomizeFile(r, rule, filepath.Join(r.Dir, path))
+	if err != nil {
+		return errors.Wrapf(err, "failed to modify kustomize files in dir %s", r.Dir)
+	}
+	return nil
+}
+
+func modifyKustomizeFile(r *rules.PromoteRule, rule *v1alpha1.KustomizeRule, file string) error {
+	exists, err := files.FileExists(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to detect if file exists %s", file)
+	}
+	if !exists {
+		return errors.Errorf("file does not exist %s", file)
+	}
+
+	docs, err := yamlnodes.LoadFile(file)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		docs = append(docs, &yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode}},
+		})
+	}
+	node := yamlnodes.Root(docs[0])
+	if node.Kind != yaml.MappingNode {
+		return errors.Errorf("file %s does not contain a YAML object", file)
+	}
+
+	err = modifyKustomization(r, rule, node)
+	if err != nil {
+		return err
+	}
+
+	err = yamlnodes.EditFile(file, docs)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("modified file %s", termcolor.ColorInfo(file))
+	return nil
+}
+
+func modifyKustomization(r *rules.PromoteRule, rule *v1alpha1.KustomizeRule, node *yaml.Node) error {
+	app := r.AppName
+	if app == "" {
+		return errors.Errorf("no AppName so cannot promote via kustomize")
+	}
+	image, err := rules.EvaluateTemplate(r, rule.Image)
+	if err != nil {
+		return errors.Wrapf(err, "failed to evaluate image template")
+	}
+
+	found := false
+	images := yamlnodes.GetMapValue(node, "images")
+	if images != nil && images.Kind == yaml.SequenceNode {
+		for _, entry := range images.Content {
+			if matchesImage(yamlnodes.GetMapString(entry, "name"), image, app) {
+				setImageVersion(entry, r.Version)
+				found = true
+			}
+		}
+	}
+
+	charts := yamlnodes.GetMapValue(node, "helmCharts")
+	if charts != nil && charts.Kind == yaml.SequenceNode {
+		for _, entry := range charts.Content {
+			name := yamlnodes.GetMapString(entry, "name")
+			if name == app || yamlnodes.GetMapString(entry, "releaseName") == app {
+				yamlnodes.SetMapString(entry, "version", r.Version)
+				found = true
+			}
+		}
+	}
+	if found {
+		return nil
+	}
+
+	if image != "" {
+		entry := yamlnodes.NewMap("name", image)
+		setImageVersion(entry, r.Version)
+		images = yamlnodes.EnsureMapValue(node, yaml.SequenceNode, "images")
+		images.Content = append(images.Content, entry)
+		return nil
+	}
+
+	if r.DevEnvContext == nil {
+		return errors.Errorf("no devEnvContext")
+	}
+	details, err := r.DevEnvContext.ChartDetails(app, r.HelmRepositoryURL)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get chart details for %s repo %s", app, r.HelmRepositoryURL)
+	}
+	ns := rule.Namespace
+	if ns == "" {
+		ns = r.Namespace
+	}
+	entry := yamlnodes.NewMap(
+		"name", details.LocalName,
+		"repo", details.Repository,
+		"version", r.Version,
+		"releaseName", app,
+		"namespace", ns,
+	)
+	charts = yamlnodes.EnsureMapValue(node, yaml.SequenceNode, "helmCharts")
+	charts.Content = append(charts.Content, entry)
+	return nil
+}
+
+// matchesImage returns true if the image name matches the configured image or the last path of the image is the app
+func matchesImage(name string, image string, app string) bool {
+	if name == "" {
+		return false
+	}
+	if image != "" {
+		return name == image
+	}
+	paths := strings.Split(name, "/")
+	return paths[len(paths)-1] == app
+}
+
+// setImageVersion sets the tag or digest of an images entry depending on the kind of version
+func setImageVersion(entry *yaml.Node, version string) {
+	if strings.HasPrefix(version, digestPrefix) {
+		yamlnodes.RemoveMapKey(entry, "newTag")
+		yamlnodes.SetMapString(entry, "digest", version)
+		return
+	}
+	yamlnodes.RemoveMapKey(entry, "digest")
+	yamlnodes.SetMapString(entry, "newTag", version)
+}
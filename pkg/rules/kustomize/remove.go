@@ -0,0 +1,105 @@
+package kustomize
+
+import (
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// RemoveKustomizeRule removes the images and helmCharts entries of the app from the kustomization.yaml file
+func RemoveKustomizeRule(r *rules.PromoteRule) error {
+	config := r.Config
+	if config.Spec.KustomizeRule == nil {
+		return errors.Errorf("no kustomizeRule configured")
+	}
+	rule := config.Spec.KustomizeRule
+	path := rule.Path
+	if path == "" {
+		path = DefaultPath
+	}
+
+	err := removeKustomizeEntries(r, rule, filepath.Join(r.Dir, path))
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove app from kustomize files in dir %s", r.Dir)
+	}
+	return nil
+}
+
+func removeKustomizeEntries(r *rules.PromoteRule, rule *v1alpha1.KustomizeRule, file string) error {
+	app := r.AppName
+	if app == "" {
+		return errors.Errorf("no AppName so cannot remove via kustomize")
+	}
+	exists, err := files.FileExists(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to detect if file exists %s", file)
+	}
+	if !exists {
+		return errors.Errorf("file does not exist %s", file)
+	}
+	image, err := rules.EvaluateTemplate(r, rule.Image)
+	if err != nil {
+		return errors.Wrapf(err, "failed to evaluate image template")
+	}
+
+	docs, err := yamlnodes.LoadFile(file)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+	node := yamlnodes.Root(docs[0])
+	if node.Kind != yaml.MappingNode {
+		return errors.Errorf("file %s does not contain a YAML object", file)
+	}
+
+	imagesRemoved := removeEntries(node, "images", func(entry *yaml.Node) bool {
+		return matchesImage(yamlnodes.GetMapString(entry, "name"), image, app)
+	})
+	chartsRemoved := removeEntries(node, "helmCharts", func(entry *yaml.Node) bool {
+		return yamlnodes.GetMapString(entry, "name") == app || yamlnodes.GetMapString(entry, "releaseName") == app
+	})
+	if !imagesRemoved && !chartsRemoved {
+		log.Logger().Infof("no images or helmCharts found for app %s in %s", app, termcolor.ColorInfo(file))
+		return nil
+	}
+
+	err = yamlnodes.SaveFile(file, docs)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("modified file %s", termcolor.ColorInfo(file))
+	return nil
+}
+
+// removeEntries removes the matching entries of the sequence removing the key if it becomes empty. Returns true if
+// any entries were removed
+func removeEntries(node *yaml.Node, key string, matches func(entry *yaml.Node) bool) bool {
+	entries := yamlnodes.GetMapValue(node, key)
+	if entries == nil || entries.Kind != yaml.SequenceNode {
+		return false
+	}
+	var content []*yaml.Node
+	for _, entry := range entries.Content {
+		if !matches(entry) {
+			content = append(content, entry)
+		}
+	}
+	if len(content) == len(entries.Content) {
+		return false
+	}
+	if len(content) == 0 {
+		yamlnodes.RemoveMapKey(node, key)
+		return true
+	}
+	entries.Content = content
+	return true
+}
@@ -0,0 +1,94 @@
+package kustomize
+
+import (
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule promotes apps in the images or helmCharts of a kustomization.yaml file
+type Rule struct{}
+
+var _ rules.Rule = &Rule{}
+
+// Validate validates the kustomizeRule
+func (*Rule) Validate(r *rules.PromoteRule) error {
+	rule := r.Config.Spec.KustomizeRule
+	if rule == nil {
+		return errors.Errorf("no kustomizeRule configured")
+	}
+	_, err := rules.EvaluateTemplate(r, rule.Image)
+	if err != nil {
+		return errors.Wrapf(err, "failed to evaluate image template")
+	}
+	return nil
+}
+
+// Read returns the tag or digest of the image of the app or the version of its chart
+func (*Rule) Read(r *rules.PromoteRule) (string, error) {
+	rule := r.Config.Spec.KustomizeRule
+	if rule == nil {
+		return "", errors.Errorf("no kustomizeRule configured")
+	}
+	path := rule.Path
+	if path == "" {
+		path = DefaultPath
+	}
+	file := filepath.Join(r.Dir, path)
+	exists, err := files.FileExists(file)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to detect if file exists %s", file)
+	}
+	if !exists {
+		return "", nil
+	}
+	image, err := rules.EvaluateTemplate(r, rule.Image)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to evaluate image template")
+	}
+
+	docs, err := yamlnodes.LoadFile(file)
+	if err != nil {
+		return "", err
+	}
+	if len(docs) == 0 {
+		return "", nil
+	}
+	node := yamlnodes.Root(docs[0])
+
+	images := yamlnodes.GetMapValue(node, "images")
+	if images != nil && images.Kind == yaml.SequenceNode {
+		for _, entry := range images.Content {
+			if matchesImage(yamlnodes.GetMapString(entry, "name"), image, r.AppName) {
+				digest := yamlnodes.GetMapString(entry, "digest")
+				if digest != "" {
+					return digest, nil
+				}
+				return yamlnodes.GetMapString(entry, "newTag"), nil
+			}
+		}
+	}
+	charts := yamlnodes.GetMapValue(node, "helmCharts")
+	if charts != nil && charts.Kind == yaml.SequenceNode {
+		for _, entry := range charts.Content {
+			if yamlnodes.GetMapString(entry, "name") == r.AppName || yamlnodes.GetMapString(entry, "releaseName") == r.AppName {
+				return yamlnodes.GetMapString(entry, "version"), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// Apply promotes the version of the app
+func (*Rule) Apply(r *rules.PromoteRule) error {
+	return KustomizeRule(r)
+}
+
+// Remove removes the app
+func (*Rule) Remove(r *rules.PromoteRule) error {
+	return RemoveKustomizeRule(r)
+}
@@ -0,0 +1,154 @@
+package path
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-helpers/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/jenkins-x/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// PathRule sets the values of paths within a YAML or JSON file
+func PathRule(r *rules.PromoteRule) error {
+	config := r.Config
+	if config.Spec.PathRule == nil {
+		return errors.Errorf("no pathRule configured")
+	}
+	rule := config.Spec.PathRule
+	if rule.Path == "" {
+		return errors.Errorf("no path configured for the pathRule")
+	}
+	if len(rule.Values) == 0 {
+		return errors.Errorf("no values configured for the pathRule")
+	}
+
+	file := filepath.Join(r.Dir, rule.Path)
+	exists, err := files.FileExists(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to detect if file exists %s", file)
+	}
+	if !exists {
+		return errors.Errorf("file does not exist %s", file)
+	}
+
+	if strings.HasSuffix(strings.ToLower(file), ".json") {
+		err = modifyJSONFile(r, rule, file)
+	} else {
+		err = modifyYAMLFile(r, rule, file)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to modify file %s", file)
+	}
+	log.Logger().Infof("modified file %s", termcolor.ColorInfo(file))
+	return nil
+}
+
+// modifyYAMLFile edits the values in the source of the YAML file so that the comments and formatting are retained
+func modifyYAMLFile(r *rules.PromoteRule, rule *v1alpha1.PathRule, file string) error {
+	editor, err := yamlnodes.LoadEditor(file)
+	if err != nil {
+		return err
+	}
+	if len(editor.Docs) == 0 {
+		// there is no formatting to retain in an empty file
+		docs := []*yaml.Node{
+			{
+				Kind:    yaml.DocumentNode,
+				Content: []*yaml.Node{{Kind: yaml.MappingNode}},
+			},
+		}
+		err = setValues(r, rule, docs, yamlnodes.SetPath)
+		if err != nil {
+			return err
+		}
+		return yamlnodes.SaveFile(file, docs)
+	}
+	err = setValues(r, rule, editor.Docs, editor.SetPath)
+	if err != nil {
+		return err
+	}
+	return editor.SaveFile(file)
+}
+
+func modifyJSONFile(r *rules.PromoteRule, rule *v1alpha1.PathRule, file string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat file %s", file)
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read file %s", file)
+	}
+	docs, err := yamlnodes.Parse(data)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse JSON file %s", file)
+	}
+	if len(docs) != 1 {
+		return errors.Errorf("file %s should contain a single JSON value but found %d", file, len(docs))
+	}
+	err = setValues(r, rule, docs, yamlnodes.SetPath)
+	if err != nil {
+		return err
+	}
+
+	result, err := yamlnodes.ToJSON(docs[0], yamlnodes.JSONIndent(data))
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal JSON for file %s", file)
+	}
+	if bytes.HasSuffix(data, []byte("\n")) {
+		result = append(result, '\n')
+	}
+	err = ioutil.WriteFile(file, result, info.Mode())
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", file)
+	}
+	return nil
+}
+
+// setPathFunc sets the value of the nodes matching the path in a document returning the number of nodes modified
+type setPathFunc func(node *yaml.Node, path []yamlnodes.PathSegment, value string, create bool) (int, error)
+
+// setValues sets each value in the documents. Missing paths are only created if there is a single document
+// as we cannot know which of many documents should contain the path
+func setValues(r *rules.PromoteRule, rule *v1alpha1.PathRule, docs []*yaml.Node, setPath setPathFunc) error {
+	create := len(docs) == 1
+	for i, pv := range rule.Values {
+		expression, err := rules.EvaluateTemplate(r, pv.Expression)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate expression template for values[%d]", i)
+		}
+		value := r.Version
+		if pv.Value != "" {
+			value, err = rules.EvaluateTemplate(r, pv.Value)
+			if err != nil {
+				return errors.Wrapf(err, "failed to evaluate value template for values[%d]", i)
+			}
+		}
+		path, err := yamlnodes.ParsePath(expression)
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		for _, doc := range docs {
+			n, err := setPath(doc, path, value, create)
+			if err != nil {
+				return errors.Wrapf(err, "failed to set path %s", expression)
+			}
+			count += n
+		}
+		if count == 0 {
+			return errors.Errorf("no match found for path %s", expression)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package path
+
+import (
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/pkg/errors"
+)
+
+// RemovePathRule fails as the paths of a pathRule cannot be removed without also removing whatever else uses them
+func RemovePathRule(r *rules.PromoteRule) error {
+	config := r.Config
+	if config.Spec.PathRule == nil {
+		return errors.Errorf("no pathRule configured")
+	}
+	return errors.Errorf("cannot remove app %s as removal is not supported by the pathRule so please modify %s by hand", r.AppName, config.Spec.PathRule.Path)
+}
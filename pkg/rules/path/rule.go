@@ -0,0 +1,104 @@
+package path
+
+import (
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule promotes apps by setting the values of paths within a YAML or JSON file
+type Rule struct{}
+
+var _ rules.Rule = &Rule{}
+
+// Validate validates the pathRule and its path expressions
+func (*Rule) Validate(r *rules.PromoteRule) error {
+	rule := r.Config.Spec.PathRule
+	if rule == nil {
+		return errors.Errorf("no pathRule configured")
+	}
+	if rule.Path == "" {
+		return errors.Errorf("no path configured for the pathRule")
+	}
+	if len(rule.Values) == 0 {
+		return errors.Errorf("no values configured for the pathRule")
+	}
+	for i, pv := range rule.Values {
+		expression, err := rules.EvaluateTemplate(r, pv.Expression)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate expression template for values[%d]", i)
+		}
+		_, err = yamlnodes.ParsePath(expression)
+		if err != nil {
+			return errors.Wrapf(err, "invalid expression for values[%d]", i)
+		}
+	}
+	return nil
+}
+
+// Read returns the version from the first path of the rule found in the file
+func (*Rule) Read(r *rules.PromoteRule) (string, error) {
+	rule := r.Config.Spec.PathRule
+	if rule == nil {
+		return "", errors.Errorf("no pathRule configured")
+	}
+	if rule.Path == "" {
+		return "", errors.Errorf("no path configured for the pathRule")
+	}
+	file := filepath.Join(r.Dir, rule.Path)
+	exists, err := files.FileExists(file)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to detect if file exists %s", file)
+	}
+	if !exists {
+		return "", nil
+	}
+
+	// JSON is valid YAML so we can parse both kinds of file the same way
+	docs, err := yamlnodes.LoadFile(file)
+	if err != nil {
+		return "", err
+	}
+	for i, pv := range rule.Values {
+		expression, err := rules.EvaluateTemplate(r, pv.Expression)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to evaluate expression template for values[%d]", i)
+		}
+		path, err := yamlnodes.ParsePath(expression)
+		if err != nil {
+			return "", err
+		}
+		for _, doc := range docs {
+			for _, node := range yamlnodes.FindPath(doc, path) {
+				if node.Kind != yaml.ScalarNode {
+					continue
+				}
+				if pv.Value == "" {
+					return node.Value, nil
+				}
+				version, err := rules.ExtractVersion(r, pv.Value, node.Value)
+				if err != nil {
+					return "", errors.Wrapf(err, "failed to find the version for values[%d]", i)
+				}
+				if version != "" {
+					return version, nil
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+// Apply promotes the version of the app
+func (*Rule) Apply(r *rules.PromoteRule) error {
+	return PathRule(r)
+}
+
+// Remove removes the app
+func (*Rule) Remove(r *rules.PromoteRule) error {
+	return RemovePathRule(r)
+}
@@ -0,0 +1,58 @@
+package rules
+
+import (
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// EvaluateTemplate evaluates the go template text using the TemplateContext of the rule
+func EvaluateTemplate(r *PromoteRule, templateText string) (string, error) {
+	if templateText == "" {
+		return "", nil
+	}
+	tmpl, err := template.New("test").Parse(templateText)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse go template: %s", templateText)
+	}
+	ctx := r.TemplateContext
+	buf := &strings.Builder{}
+	err = tmpl.Execute(buf, &ctx)
+	if err != nil {
+		return buf.String(), errors.Wrapf(err, "failed to evaluate template with %#v", ctx)
+	}
+	return buf.String(), nil
+}
+
+// versionMarker a placeholder version used to find where the version is in the result of a template
+const versionMarker = "JX_PROMOTE_VERSION_MARKER"
+
+// ExtractVersion returns the version from the text if it matches the result of the go template or an empty string if
+// it does not match
+func ExtractVersion(r *PromoteRule, templateText string, text string) (string, error) {
+	rc := *r
+	rc.Version = versionMarker
+	expected, err := EvaluateTemplate(&rc, templateText)
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(expected, versionMarker)
+	if len(parts) < 2 {
+		return "", errors.Errorf("the template %s does not contain the version", templateText)
+	}
+	var suffixes []string
+	for _, part := range parts[1:] {
+		suffixes = append(suffixes, regexp.QuoteMeta(part))
+	}
+	re, err := regexp.Compile("^" + regexp.QuoteMeta(parts[0]) + `(\S+?)` + strings.Join(suffixes, `\S+?`) + "$")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create version regex for template %s", templateText)
+	}
+	m := re.FindStringSubmatch(text)
+	if m == nil {
+		return "", nil
+	}
+	return m[1], nil
+}
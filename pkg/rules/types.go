@@ -25,5 +25,23 @@ type TemplateContext struct {
 	HelmRepositoryURL string
 }
 
-// RuleFunction a rule function for evaluating the rule
-type RuleFunction func(*PromoteRule) error
+// Rule reads, promotes and removes the versions of apps declared in an environment git repository
+type Rule interface {
+	// Validate returns an error if the configuration of the rule is not valid
+	Validate(r *PromoteRule) error
+
+	// Read returns the version of the app currently declared or an empty string if the app is not declared
+	Read(r *PromoteRule) (string, error)
+
+	// Apply promotes the version of the app
+	Apply(r *PromoteRule) error
+
+	// Remove removes the app
+	Remove(r *PromoteRule) error
+}
+
+// Lister is implemented by rules which can find all of the apps declared in an environment git repository
+type Lister interface {
+	// List returns the versions of the apps declared indexed by app name
+	List(r *PromoteRule) (map[string]string, error)
+}
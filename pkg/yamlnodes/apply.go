@@ -0,0 +1,162 @@
+package yamlnodes
+
+import (
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-logging/pkg/log"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// EditFile saves the documents, which were loaded from the file and then modified, by editing the source of the file
+// so that only the changed values are modified. If the file does not exist or the changes cannot be made in place the
+// documents are marshalled instead
+func EditFile(path string, docs []*yaml.Node) error {
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if !exists {
+		return SaveFile(path, docs)
+	}
+	editor, err := LoadEditor(path)
+	if err == nil {
+		err = editor.Apply(docs)
+	}
+	if err == nil {
+		return editor.SaveFile(path)
+	}
+	log.Logger().Warnf("could not edit file %s in place so rewriting it: %s", path, err.Error())
+	return SaveFile(path, docs)
+}
+
+// Apply edits the source so that it matches the modified documents which must have been parsed from the same source.
+// Nodes are matched using their position in the source so any nodes added to the documents must not have a position
+func (e *Editor) Apply(docs []*yaml.Node) error {
+	if len(docs) != len(e.Docs) {
+		return errors.Errorf("expected %d documents but found %d", len(e.Docs), len(docs))
+	}
+	for i, doc := range docs {
+		err := e.apply(nil, "", e.Docs[i], doc)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply edits the source of the original node to match the modified node. The parent mapping and key are used to
+// delete sequence items
+func (e *Editor) apply(parent *yaml.Node, key string, from *yaml.Node, to *yaml.Node) error {
+	if to.Kind != from.Kind || to.Line != from.Line || to.Column != from.Column {
+		return errors.Errorf("cannot replace the value at line %d", from.Line)
+	}
+	switch from.Kind {
+	case yaml.DocumentNode:
+		if len(to.Content) != len(from.Content) {
+			return errors.Errorf("cannot replace the document at line %d", from.Line)
+		}
+		for i := range from.Content {
+			err := e.apply(nil, "", from.Content[i], to.Content[i])
+			if err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if to.Value == from.Value && to.Tag == from.Tag {
+			return nil
+		}
+		if to.Tag == StringTag {
+			return e.SetString(from, to.Value)
+		}
+		return e.SetScalar(from, to.Value)
+	case yaml.MappingNode:
+		return e.applyMap(from, to)
+	case yaml.SequenceNode:
+		return e.applySequence(parent, key, from, to)
+	}
+	return nil
+}
+
+// applyMap deletes, modifies and adds the keys of the original mapping to match the modified mapping. Keys can only
+// be added after the existing keys
+func (e *Editor) applyMap(from *yaml.Node, to *yaml.Node) error {
+	for i := 0; i+1 < len(from.Content); i += 2 {
+		key := from.Content[i].Value
+		if mapKeyIndex(to, key) < 0 {
+			err := e.DeleteMapKey(from, key)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	added := false
+	for i := 0; i+1 < len(to.Content); i += 2 {
+		key := to.Content[i].Value
+		value := to.Content[i+1]
+		idx := mapKeyIndex(from, key)
+		var err error
+		if idx < 0 {
+			added = true
+			err = e.AddMapValue(from, key, value)
+		} else if added {
+			err = errors.Errorf("cannot add keys before the key %s at line %d", key, from.Content[idx].Line)
+		} else {
+			err = e.apply(from, key, from.Content[idx+1], value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applySequence deletes, modifies and appends the items of the original sequence to match the modified sequence.
+// Items can only be deleted from a sequence which is the value of the key in the parent mapping
+func (e *Editor) applySequence(parent *yaml.Node, key string, from *yaml.Node, to *yaml.Node) error {
+	positions := map[[2]int]int{}
+	for i, item := range from.Content {
+		positions[[2]int{item.Line, item.Column}] = i
+	}
+	kept := map[int]bool{}
+	next := 0
+	var added []*yaml.Node
+	for _, item := range to.Content {
+		if item.Line == 0 {
+			added = append(added, item)
+			continue
+		}
+		i, ok := positions[[2]int{item.Line, item.Column}]
+		if !ok || i < next || len(added) > 0 {
+			return errors.Errorf("cannot move the item at line %d", item.Line)
+		}
+		err := e.apply(nil, "", from.Content[i], item)
+		if err != nil {
+			return err
+		}
+		kept[i] = true
+		next = i + 1
+	}
+
+	var deleted []int
+	for i := range from.Content {
+		if !kept[i] {
+			deleted = append(deleted, i)
+		}
+	}
+	if len(deleted) > 0 {
+		if parent == nil || (len(deleted) == len(from.Content) && len(added) > 0) {
+			return errors.Errorf("cannot delete the items of the sequence at line %d", from.Line)
+		}
+		err := e.DeleteSequenceItems(parent, key, deleted)
+		if err != nil {
+			return err
+		}
+	}
+	for _, item := range added {
+		err := e.AppendSequence(from, item)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package yamlnodes_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditorApply(t *testing.T) {
+	source := `# the images
+images:
+    - name: myorg/myapp # the app
+      newTag: 1.0.0
+    - name: myorg/old
+      digest: sha256:abc
+
+    - name: myorg/another
+      newTag: '2.0.0'
+replicas: 1
+---
+kind: Other
+`
+	expected := `# the images
+images:
+    - name: myorg/myapp # the app
+      newTag: 1.2.3
+
+    - name: myorg/another
+      newTag: '2.0.0'
+      digest: sha256:def
+    - name: myorg/new
+      newTag: 3.0.0
+replicas: 2
+namespace: jx
+---
+kind: Other
+`
+	editor, err := yamlnodes.NewEditor([]byte(source))
+	require.NoError(t, err, "failed to parse YAML")
+
+	docs, err := yamlnodes.Parse([]byte(source))
+	require.NoError(t, err, "failed to parse YAML")
+	root := yamlnodes.Root(docs[0])
+	images := yamlnodes.GetMapValue(root, "images")
+	yamlnodes.SetMapString(images.Content[0], "newTag", "1.2.3")
+	yamlnodes.SetMapString(images.Content[2], "digest", "sha256:def")
+	images.Content = append(images.Content[:1], images.Content[2], yamlnodes.NewMap("name", "myorg/new", "newTag", "3.0.0"))
+	yamlnodes.SetScalar(yamlnodes.GetMapValue(root, "replicas"), "2")
+	yamlnodes.SetMapString(root, "namespace", "jx")
+
+	err = editor.Apply(docs)
+	require.NoError(t, err, "failed to apply the changes")
+	assert.Equal(t, expected, string(editor.Bytes()))
+
+	docs, err = yamlnodes.Parse([]byte(source))
+	require.NoError(t, err, "failed to parse YAML")
+	images = yamlnodes.GetMapValue(yamlnodes.Root(docs[0]), "images")
+	images.Content[0], images.Content[1] = images.Content[1], images.Content[0]
+	err = editor.Apply(docs)
+	require.Error(t, err, "expected an error for reordered items")
+
+	err = editor.Apply(docs[:1])
+	require.Error(t, err, "expected an error for a removed document")
+}
+
+func TestEditFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not make a temp dir")
+	defer os.RemoveAll(tmpDir)
+
+	file := filepath.Join(tmpDir, "values.yaml")
+	source := "# the values\nimage: {tag: 1.0.0}\nlabels: {}\n"
+	require.NoError(t, ioutil.WriteFile(file, []byte(source), files.DefaultFileWritePermissions))
+
+	docs, err := yamlnodes.LoadFile(file)
+	require.NoError(t, err, "failed to load file")
+	root := yamlnodes.Root(docs[0])
+	yamlnodes.SetPathString(root, "1.2.3", "image", "tag")
+
+	err = yamlnodes.EditFile(file, docs)
+	require.NoError(t, err, "failed to edit the file")
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err, "failed to read file")
+	assert.Equal(t, "# the values\nimage: {tag: 1.2.3}\nlabels: {}\n", string(data), "the value should be edited in place")
+
+	// lets add a key to a flow style mapping which cannot be edited in place
+	docs, err = yamlnodes.LoadFile(file)
+	require.NoError(t, err, "failed to load file")
+	yamlnodes.SetPathString(yamlnodes.Root(docs[0]), "myapp", "labels", "app")
+
+	err = yamlnodes.EditFile(file, docs)
+	require.NoError(t, err, "failed to rewrite the file")
+	data, err = ioutil.ReadFile(file)
+	require.NoError(t, err, "failed to read file")
+	assert.Equal(t, "# the values\nimage: {tag: 1.2.3}\nlabels: {app: myapp}\n", string(data), "the file should be rewritten")
+}
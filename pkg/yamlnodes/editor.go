@@ -0,0 +1,503 @@
+package yamlnodes
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Editor edits the source text of YAML documents in place using the positions of the parsed nodes so that the
+// comments, blank lines, quoting and indentation of the rest of the file are left untouched.
+//
+// All changes are made relative to the original source so the Docs should not be modified directly
+type Editor struct {
+	// Docs the parsed documents
+	Docs []*yaml.Node
+
+	lines        []string
+	seqIndent    int
+	replacements map[int][]replacement
+	inserts      map[int][]string
+	deletes      map[int]bool
+}
+
+type replacement struct {
+	start int
+	end   int
+	text  string
+}
+
+// LoadEditor loads an editor for the given YAML file
+func LoadEditor(path string) (*Editor, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read file %s", path)
+	}
+	e, err := NewEditor(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse YAML file %s", path)
+	}
+	return e, nil
+}
+
+// NewEditor creates an editor for the given YAML source
+func NewEditor(data []byte) (*Editor, error) {
+	docs, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	e := &Editor{
+		Docs:         docs,
+		lines:        strings.Split(string(data), "\n"),
+		replacements: map[int][]replacement{},
+		inserts:      map[int][]string{},
+		deletes:      map[int]bool{},
+	}
+	for _, doc := range docs {
+		if indent, ok := e.detectSeqIndent(doc); ok {
+			e.seqIndent = indent
+			break
+		}
+	}
+	return e, nil
+}
+
+// Bytes returns the edited source
+func (e *Editor) Bytes() []byte {
+	var lines []string
+	for i, line := range e.lines {
+		lineNo := i + 1
+		reps := e.replacements[lineNo]
+		if len(reps) > 0 {
+			runes := []rune(line)
+			// apply from the end of the line so earlier columns remain valid
+			for j := len(reps) - 1; j >= 0; j-- {
+				rep := reps[j]
+				runes = append(runes[:rep.start], append([]rune(rep.text), runes[rep.end:]...)...)
+			}
+			line = string(runes)
+		}
+		if !e.deletes[lineNo] {
+			lines = append(lines, line)
+		}
+		lines = append(lines, e.inserts[lineNo]...)
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteString(strings.Join(lines, "\n"))
+	return buf.Bytes()
+}
+
+// SaveFile saves the edited source to the given file
+func (e *Editor) SaveFile(path string) error {
+	var mode os.FileMode = files.DefaultFileWritePermissions
+	info, err := os.Stat(path)
+	if err == nil {
+		mode = info.Mode()
+	}
+	err = ioutil.WriteFile(path, e.Bytes(), mode)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return nil
+}
+
+// SetString replaces the value of the given scalar node retaining any quoting style
+func (e *Editor) SetString(node *yaml.Node, value string) error {
+	if node.Kind != yaml.ScalarNode {
+		return errors.Errorf("cannot set value of non scalar at line %d", node.Line)
+	}
+	if node.Value == value && node.Tag == StringTag {
+		return nil
+	}
+	start, end, err := e.scalarRange(node)
+	if err != nil {
+		return err
+	}
+	style := node.Style & (yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle)
+	text, err := scalarText(value, style)
+	if err != nil {
+		return err
+	}
+	e.addReplacement(node.Line, replacement{start: start, end: end, text: text})
+	return nil
+}
+
+// SetMapString sets the string value of the key in the block mapping node adding the key if it does not exist
+func (e *Editor) SetMapString(node *yaml.Node, key string, value string) error {
+	existing := GetMapValue(node, key)
+	if existing != nil {
+		return e.SetString(existing, value)
+	}
+	return e.AddMapValue(node, key, NewString(value))
+}
+
+// AddMapValue adds a new key and value to the end of the given non empty block mapping node
+func (e *Editor) AddMapValue(node *yaml.Node, key string, value *yaml.Node) error {
+	if node.Kind != yaml.MappingNode || node.Style&yaml.FlowStyle != 0 || len(node.Content) == 0 {
+		return errors.Errorf("cannot add key %s to a flow style or empty mapping at line %d", key, node.Line)
+	}
+	entry := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{NewString(key), value}}
+	lines, err := e.render(entry, node.Content[0].Column-1)
+	if err != nil {
+		return err
+	}
+	e.insertAfter(EndLine(node), lines)
+	return nil
+}
+
+// AppendSequence appends the item to the end of the given non empty block sequence node
+func (e *Editor) AppendSequence(node *yaml.Node, item *yaml.Node) error {
+	if node.Kind != yaml.SequenceNode || node.Style&yaml.FlowStyle != 0 || len(node.Content) == 0 {
+		return errors.Errorf("cannot append to a flow style or empty sequence at line %d", node.Line)
+	}
+	dash, err := e.dashColumn(node.Content[0])
+	if err != nil {
+		return err
+	}
+	lines, err := e.renderSeqItem(item, dash)
+	if err != nil {
+		return err
+	}
+	e.insertAfter(EndLine(node), lines)
+	return nil
+}
+
+// AppendItems appends the items to the block sequence of the key in the mapping node adding the key if it does not
+// exist. The count is the number of items the sequence is expected to contain so that a sequence which does not match
+// the parsed file is not modified
+func (e *Editor) AppendItems(node *yaml.Node, key string, count int, items []*yaml.Node) error {
+	if len(items) == 0 {
+		return nil
+	}
+	seq := GetMapValue(node, key)
+	if seq == nil {
+		return e.AddMapValue(node, key, &yaml.Node{Kind: yaml.SequenceNode, Content: items})
+	}
+	if seq.Kind != yaml.SequenceNode || len(seq.Content) != count {
+		return errors.Errorf("the %s do not match the parsed file", key)
+	}
+	for _, item := range items {
+		err := e.AppendSequence(seq, item)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteMapKey deletes the key and its value from the block mapping node along with any comment lines directly above
+// the key
+func (e *Editor) DeleteMapKey(node *yaml.Node, key string) error {
+	idx := mapKeyIndex(node, key)
+	if idx < 0 {
+		return errors.Errorf("no key %s found", key)
+	}
+	if node.Style&yaml.FlowStyle != 0 {
+		return errors.Errorf("cannot delete key %s from a flow style mapping at line %d", key, node.Line)
+	}
+	keyNode := node.Content[idx]
+	runes, err := e.lineRunes(keyNode.Line)
+	if err != nil {
+		return err
+	}
+	indent := keyNode.Column - 1
+	if indent > len(runes) || strings.TrimSpace(string(runes[:indent])) != "" {
+		return errors.Errorf("cannot delete key %s which does not start line %d", key, keyNode.Line)
+	}
+	// lets remove the comments describing the key too
+	start := keyNode.Line
+	prefix := strings.Repeat(" ", indent) + "#"
+	for start > 1 && strings.HasPrefix(e.lines[start-2], prefix) && !e.deletes[start-1] {
+		start--
+	}
+	e.deleteLines(start, EndLine(node.Content[idx+1]))
+	return nil
+}
+
+// DeleteSequenceItems deletes the items at the indexes of the block sequence of the key in the mapping node along with
+// any comment lines directly above the items. If all the items are deleted then the key is deleted
+func (e *Editor) DeleteSequenceItems(node *yaml.Node, key string, indexes []int) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+	idx := mapKeyIndex(node, key)
+	if idx < 0 {
+		return errors.Errorf("no key %s found", key)
+	}
+	keyNode := node.Content[idx]
+	seq := node.Content[idx+1]
+	if seq.Kind != yaml.SequenceNode || seq.Style&yaml.FlowStyle != 0 {
+		return errors.Errorf("cannot delete items from a flow style or non sequence %s at line %d", key, seq.Line)
+	}
+	deleted := map[int]bool{}
+	for _, i := range indexes {
+		if i < 0 || i >= len(seq.Content) {
+			return errors.Errorf("index %d is out of range for %s", i, key)
+		}
+		deleted[i] = true
+	}
+	if len(deleted) == len(seq.Content) {
+		e.deleteLines(keyNode.Line, EndLine(seq))
+		return nil
+	}
+	for i, item := range seq.Content {
+		if !deleted[i] {
+			continue
+		}
+		dash, err := e.dashColumn(item)
+		if err != nil {
+			return err
+		}
+		// lets remove the comments describing the item too
+		start := item.Line
+		prefix := strings.Repeat(" ", dash) + "#"
+		for start > 1 && strings.HasPrefix(e.lines[start-2], prefix) && !e.deletes[start-1] {
+			start--
+		}
+		e.deleteLines(start, EndLine(item))
+	}
+	return nil
+}
+
+// deleteLines deletes the lines along with a following blank line if the deleted lines were surrounded by blank lines
+func (e *Editor) deleteLines(start int, end int) {
+	for line := start; line <= end; line++ {
+		e.deletes[line] = true
+	}
+	if (start == 1 || e.blankLine(start-1)) && end < len(e.lines) && e.blankLine(end+1) {
+		e.deletes[end+1] = true
+	}
+}
+
+// blankLine returns true if the line is empty and has not been deleted
+func (e *Editor) blankLine(line int) bool {
+	return !e.deletes[line] && strings.TrimSpace(e.lines[line-1]) == ""
+}
+
+// EndLine returns the last line of the source of the node and its children
+func EndLine(node *yaml.Node) int {
+	line := node.Line
+	if node.Kind == yaml.ScalarNode && node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		line += strings.Count(strings.TrimSuffix(node.Value, "\n"), "\n") + 1
+	}
+	for _, child := range node.Content {
+		l := EndLine(child)
+		if l > line {
+			line = l
+		}
+	}
+	return line
+}
+
+func (e *Editor) addReplacement(line int, rep replacement) {
+	reps := e.replacements[line]
+	i := 0
+	for i < len(reps) && reps[i].start < rep.start {
+		i++
+	}
+	if i < len(reps) && reps[i].start == rep.start {
+		reps[i] = rep
+	} else {
+		reps = append(reps[:i], append([]replacement{rep}, reps[i:]...)...)
+	}
+	e.replacements[line] = reps
+}
+
+func (e *Editor) insertAfter(line int, lines []string) {
+	e.inserts[line] = append(e.inserts[line], lines...)
+}
+
+func (e *Editor) lineRunes(line int) ([]rune, error) {
+	if line < 1 || line > len(e.lines) {
+		return nil, errors.Errorf("line %d is out of range", line)
+	}
+	return []rune(e.lines[line-1]), nil
+}
+
+// scalarRange returns the start and end rune offsets of the source of a single line scalar
+func (e *Editor) scalarRange(node *yaml.Node) (int, int, error) {
+	runes, err := e.lineRunes(node.Line)
+	if err != nil {
+		return 0, 0, err
+	}
+	start := node.Column - 1
+	if start < 0 || start > len(runes) {
+		return 0, 0, errors.Errorf("column %d is out of range on line %d", node.Column, node.Line)
+	}
+	switch {
+	case node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		return 0, 0, errors.Errorf("cannot replace block scalar at line %d", node.Line)
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		for i := start + 1; i < len(runes); i++ {
+			if runes[i] == '\\' {
+				i++
+				continue
+			}
+			if runes[i] == '"' {
+				return start, i + 1, nil
+			}
+		}
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		for i := start + 1; i < len(runes); i++ {
+			if runes[i] == '\'' {
+				if i+1 < len(runes) && runes[i+1] == '\'' {
+					i++
+					continue
+				}
+				return start, i + 1, nil
+			}
+		}
+	default:
+		if node.Value == "" {
+			return 0, 0, errors.Errorf("cannot replace empty value at line %d", node.Line)
+		}
+		end := start + len([]rune(node.Value))
+		if end <= len(runes) && string(runes[start:end]) == node.Value {
+			return start, end, nil
+		}
+	}
+	return 0, 0, errors.Errorf("cannot find the source of the value at line %d", node.Line)
+}
+
+// dashColumn returns the zero based column of the '-' of the given sequence item
+func (e *Editor) dashColumn(item *yaml.Node) (int, error) {
+	runes, err := e.lineRunes(item.Line)
+	if err != nil {
+		return 0, err
+	}
+	for i := item.Column - 2; i >= 0 && i < len(runes); i-- {
+		switch runes[i] {
+		case '-':
+			return i, nil
+		case ' ':
+			continue
+		default:
+			return 0, errors.Errorf("cannot find the sequence indicator at line %d", item.Line)
+		}
+	}
+	return 0, errors.Errorf("cannot find the sequence indicator at line %d", item.Line)
+}
+
+// detectSeqIndent detects how far block sequences in mappings are indented relative to their key
+func (e *Editor) detectSeqIndent(node *yaml.Node) (int, bool) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			value := node.Content[i+1]
+			if value.Kind == yaml.SequenceNode && value.Style&yaml.FlowStyle == 0 && len(value.Content) > 0 {
+				dash, err := e.dashColumn(value.Content[0])
+				if err == nil {
+					return dash - (key.Column - 1), true
+				}
+			}
+		}
+	}
+	for _, child := range node.Content {
+		if indent, ok := e.detectSeqIndent(child); ok {
+			return indent, ok
+		}
+	}
+	return 0, false
+}
+
+// render renders the entries of a new mapping node as block style lines at the given indentation
+func (e *Editor) render(node *yaml.Node, indent int) ([]string, error) {
+	prefix := strings.Repeat(" ", indent)
+	var lines []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, err := scalarText(node.Content[i].Value, 0)
+		if err != nil {
+			return nil, err
+		}
+		value := node.Content[i+1]
+		switch {
+		case value.Kind == yaml.MappingNode && len(value.Content) > 0:
+			children, err := e.render(value, indent+2)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, prefix+key+":")
+			lines = append(lines, children...)
+		case value.Kind == yaml.SequenceNode && len(value.Content) > 0:
+			dash := indent + e.seqIndent
+			lines = append(lines, prefix+key+":")
+			for _, item := range value.Content {
+				children, err := e.renderSeqItem(item, dash)
+				if err != nil {
+					return nil, err
+				}
+				lines = append(lines, children...)
+			}
+		default:
+			text, err := e.inlineText(value)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, prefix+key+": "+text)
+		}
+	}
+	return lines, nil
+}
+
+// renderSeqItem renders a new sequence item as block style lines with the '-' at the given column
+func (e *Editor) renderSeqItem(item *yaml.Node, dash int) ([]string, error) {
+	prefix := strings.Repeat(" ", dash) + "- "
+	if item.Kind == yaml.MappingNode && len(item.Content) > 0 {
+		lines, err := e.render(item, dash+2)
+		if err != nil {
+			return nil, err
+		}
+		lines[0] = prefix + lines[0][dash+2:]
+		return lines, nil
+	}
+	text, err := e.inlineText(item)
+	if err != nil {
+		return nil, err
+	}
+	return []string{prefix + text}, nil
+}
+
+func (e *Editor) inlineText(node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "{}", nil
+	case yaml.SequenceNode:
+		return "[]", nil
+	case yaml.ScalarNode:
+		tag := node.ShortTag()
+		if tag != StringTag && node.Tag != "" {
+			// lets retain the type of numbers, booleans and nulls
+			return taggedScalarText(node.Value, tag)
+		}
+		return scalarText(node.Value, node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle))
+	default:
+		return "", errors.Errorf("unsupported YAML node kind %d", node.Kind)
+	}
+}
+
+// scalarText returns the YAML source of a single line string scalar using the given quoting style if possible
+func scalarText(value string, style yaml.Style) (string, error) {
+	return marshalScalar(&yaml.Node{Kind: yaml.ScalarNode, Tag: StringTag, Value: value, Style: style})
+}
+
+// taggedScalarText returns the YAML source of a single line scalar of the given type
+func taggedScalarText(value string, tag string) (string, error) {
+	return marshalScalar(&yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value})
+}
+
+func marshalScalar(node *yaml.Node) (string, error) {
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to marshal value %s", node.Value)
+	}
+	text := strings.TrimSuffix(string(data), "\n")
+	if strings.Contains(text, "\n") {
+		return "", errors.Errorf("cannot use multi line value %q", node.Value)
+	}
+	return text, nil
+}
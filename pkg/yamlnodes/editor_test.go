@@ -0,0 +1,119 @@
+package yamlnodes_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestEditor(t *testing.T) {
+	source := `# some releases
+releases:
+    - name: a # the first
+      version: '1.0.0'
+
+    - name: b
+      labels:
+        x: y
+other: true
+`
+	expected := `# some releases
+releases:
+    - name: a # the first
+      version: '2.0.0'
+
+    - name: b
+      labels:
+        x: y
+      namespace: jx
+    - name: c
+      version: "1.0"
+other: true
+repositories:
+    - name: dev
+`
+	editor, err := yamlnodes.NewEditor([]byte(source))
+	require.NoError(t, err, "failed to parse YAML")
+
+	root := yamlnodes.Root(editor.Docs[0])
+	releases := yamlnodes.GetMapValue(root, "releases")
+	require.NotNil(t, releases, "no releases")
+
+	err = editor.SetMapString(releases.Content[0], "version", "2.0.0")
+	require.NoError(t, err, "failed to set version")
+	err = editor.SetMapString(releases.Content[1], "namespace", "jx")
+	require.NoError(t, err, "failed to add namespace")
+	err = editor.AppendItems(root, "releases", 2, []*yaml.Node{yamlnodes.NewMap("name", "c", "version", "1.0")})
+	require.NoError(t, err, "failed to append release")
+	err = editor.AppendItems(root, "releases", 3, []*yaml.Node{yamlnodes.NewMap("name", "d")})
+	require.Error(t, err, "expected an error for releases which do not match the parsed file")
+
+	err = editor.AddMapValue(root, "repositories", &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{yamlnodes.NewMap("name", "dev")}})
+	require.NoError(t, err, "failed to add repositories")
+
+	assert.Equal(t, expected, string(editor.Bytes()))
+}
+
+func TestEditorDeleteSequenceItems(t *testing.T) {
+	source := `releases:
+# the first
+- name: a
+  version: 1.0.0
+# the second
+- name: b
+  version: 1.0.0
+repositories:
+  - name: dev
+other: true
+`
+	expected := `releases:
+# the first
+- name: a
+  version: 1.0.0
+other: true
+`
+	editor, err := yamlnodes.NewEditor([]byte(source))
+	require.NoError(t, err, "failed to parse YAML")
+
+	root := yamlnodes.Root(editor.Docs[0])
+	err = editor.DeleteSequenceItems(root, "releases", []int{1})
+	require.NoError(t, err, "failed to delete release")
+	err = editor.DeleteSequenceItems(root, "repositories", []int{0})
+	require.NoError(t, err, "failed to delete repositories")
+
+	assert.Equal(t, expected, string(editor.Bytes()))
+}
+
+func TestEditorDeleteMapKey(t *testing.T) {
+	source := `spec:
+  # the replicas
+  replicas: 1
+  template:
+    labels:
+      app: a
+  paused: false
+`
+	expected := `spec:
+  paused: false
+  strategy: Recreate
+`
+	editor, err := yamlnodes.NewEditor([]byte(source))
+	require.NoError(t, err, "failed to parse YAML")
+
+	spec := yamlnodes.GetPath(yamlnodes.Root(editor.Docs[0]), "spec")
+	require.NotNil(t, spec, "no spec")
+
+	err = editor.DeleteMapKey(spec, "replicas")
+	require.NoError(t, err, "failed to delete replicas")
+	err = editor.DeleteMapKey(spec, "template")
+	require.NoError(t, err, "failed to delete template")
+	err = editor.DeleteMapKey(spec, "missing")
+	require.Error(t, err, "expected an error for a missing key")
+	err = editor.AddMapValue(spec, "strategy", yamlnodes.NewString("Recreate"))
+	require.NoError(t, err, "failed to add strategy")
+
+	assert.Equal(t, expected, string(editor.Bytes()))
+}
@@ -0,0 +1,106 @@
+package yamlnodes
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ToJSON marshals the node as JSON using the given indentation retaining the order of object keys
+func ToJSON(node *yaml.Node, indent string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := writeJSON(buf, Root(node), indent, "")
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// JSONIndent returns the indentation used by the given JSON data defaulting to 2 spaces
+func JSONIndent(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) < len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "  "
+}
+
+func writeJSON(buf *bytes.Buffer, node *yaml.Node, indent string, prefix string) error {
+	if node == nil {
+		buf.WriteString("null")
+		return nil
+	}
+	childPrefix := prefix + indent
+	switch node.Kind {
+	case yaml.AliasNode:
+		return writeJSON(buf, node.Alias, indent, prefix)
+	case yaml.MappingNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{")
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n" + childPrefix)
+			err := writeJSONString(buf, node.Content[i].Value)
+			if err != nil {
+				return err
+			}
+			buf.WriteString(": ")
+			err = writeJSON(buf, node.Content[i+1], indent, childPrefix)
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + prefix + "}")
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteString("[")
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n" + childPrefix)
+			err := writeJSON(buf, item, indent, childPrefix)
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + prefix + "]")
+	case yaml.ScalarNode:
+		switch node.Tag {
+		case "!!null":
+			buf.WriteString("null")
+		case "!!bool", "!!int", "!!float":
+			buf.WriteString(node.Value)
+		default:
+			return writeJSONString(buf, node.Value)
+		}
+	default:
+		return errors.Errorf("unsupported YAML node kind %d at line %d", node.Kind, node.Line)
+	}
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, value string) error {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(value)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal JSON string")
+	}
+	buf.Write(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
+	return nil
+}
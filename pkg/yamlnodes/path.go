@@ -0,0 +1,439 @@
+package yamlnodes
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+type segmentKind int
+
+const (
+	keySegment segmentKind = iota
+	indexSegment
+	wildcardSegment
+	filterSegment
+)
+
+// PathSegment a segment of a parsed path expression
+type PathSegment struct {
+	kind  segmentKind
+	key   string
+	index int
+	value string
+}
+
+// ParsePath parses a JSONPath or yq style path expression such as `.image.tag`, `$.spec.containers[0].image`,
+// `.releases[name=myapp].version` or `$.items[?(@.name=='myapp')].version`
+func ParsePath(expression string) ([]PathSegment, error) {
+	text := strings.TrimSpace(expression)
+	text = strings.TrimPrefix(text, "$")
+	var answer []PathSegment
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch c {
+		case '.':
+			i++
+			if i < len(text) && text[i] == '.' {
+				return nil, errors.Errorf("recursive descent is not supported in path expression: %s", expression)
+			}
+		case '[':
+			end := closingBracket(text, i)
+			if end < 0 {
+				return nil, errors.Errorf("missing ']' in path expression: %s", expression)
+			}
+			segment, err := parseBracket(text[i+1 : end])
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse path expression: %s", expression)
+			}
+			answer = append(answer, segment)
+			i = end + 1
+		default:
+			end := i
+			for end < len(text) && text[end] != '.' && text[end] != '[' {
+				end++
+			}
+			key := text[i:end]
+			if key == "*" {
+				answer = append(answer, PathSegment{kind: wildcardSegment})
+			} else {
+				answer = append(answer, PathSegment{kind: keySegment, key: key})
+			}
+			i = end
+		}
+	}
+	if len(answer) == 0 {
+		return nil, errors.Errorf("empty path expression: %s", expression)
+	}
+	return answer, nil
+}
+
+// closingBracket returns the index of the ']' which closes the '[' at the given index ignoring quoted text
+func closingBracket(text string, start int) int {
+	var quote byte
+	for i := start + 1; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func parseBracket(text string) (PathSegment, error) {
+	text = strings.TrimSpace(text)
+	if text == "" || text == "*" {
+		return PathSegment{kind: wildcardSegment}, nil
+	}
+	if isQuoted(text) {
+		return PathSegment{kind: keySegment, key: unquote(text)}, nil
+	}
+	if index, err := strconv.Atoi(text); err == nil {
+		if index < 0 {
+			return PathSegment{}, errors.Errorf("negative index %d is not supported", index)
+		}
+		return PathSegment{kind: indexSegment, index: index}, nil
+	}
+
+	// lets support JSONPath filters of the form ?(@.name=='foo') along with the yq form name=foo
+	filter := text
+	if strings.HasPrefix(filter, "?(") && strings.HasSuffix(filter, ")") {
+		filter = strings.TrimSpace(filter[2 : len(filter)-1])
+		filter = strings.TrimPrefix(filter, "@")
+		filter = strings.TrimPrefix(filter, ".")
+	}
+	idx := strings.Index(filter, "=")
+	if idx <= 0 {
+		return PathSegment{}, errors.Errorf("unsupported path segment [%s]", text)
+	}
+	key := strings.TrimSpace(filter[:idx])
+	value := strings.TrimSpace(strings.TrimPrefix(filter[idx+1:], "="))
+	return PathSegment{kind: filterSegment, key: unquote(key), value: unquote(value)}, nil
+}
+
+func isQuoted(text string) bool {
+	return len(text) >= 2 && (text[0] == '\'' || text[0] == '"') && text[len(text)-1] == text[0]
+}
+
+func unquote(text string) string {
+	if isQuoted(text) {
+		return text[1 : len(text)-1]
+	}
+	return text
+}
+
+// FindPath returns all the nodes which match the given path
+func FindPath(node *yaml.Node, path []PathSegment) []*yaml.Node {
+	node = Root(node)
+	if node == nil {
+		return nil
+	}
+	if len(path) == 0 {
+		return []*yaml.Node{node}
+	}
+	var answer []*yaml.Node
+	for _, child := range children(node, path[0]) {
+		answer = append(answer, FindPath(child, path[1:])...)
+	}
+	return answer
+}
+
+func children(node *yaml.Node, segment PathSegment) []*yaml.Node {
+	switch segment.kind {
+	case keySegment:
+		child := GetMapValue(node, segment.key)
+		if child != nil {
+			return []*yaml.Node{child}
+		}
+	case indexSegment:
+		if node.Kind == yaml.SequenceNode && segment.index < len(node.Content) {
+			return []*yaml.Node{node.Content[segment.index]}
+		}
+	case wildcardSegment:
+		if node.Kind == yaml.SequenceNode {
+			return node.Content
+		}
+		if node.Kind == yaml.MappingNode {
+			var answer []*yaml.Node
+			for i := 1; i < len(node.Content); i += 2 {
+				answer = append(answer, node.Content[i])
+			}
+			return answer
+		}
+	case filterSegment:
+		var answer []*yaml.Node
+		if node.Kind == yaml.SequenceNode {
+			for _, item := range node.Content {
+				if GetMapString(item, segment.key) == segment.value {
+					answer = append(answer, item)
+				}
+			}
+		}
+		return answer
+	}
+	return nil
+}
+
+// SetPath sets the value of all the nodes matching the given path returning the number of nodes modified.
+// If create is true then any missing mapping keys, the next index in a sequence or a sequence item matching a
+// filter are created
+func SetPath(node *yaml.Node, path []PathSegment, value string, create bool) (int, error) {
+	node = Root(node)
+	if node == nil {
+		return 0, nil
+	}
+	if len(path) == 0 {
+		if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+			if len(node.Content) > 0 {
+				return 0, errors.Errorf("cannot set a value on a YAML object or array at line %d", node.Line)
+			}
+		}
+		SetScalar(node, value)
+		return 1, nil
+	}
+	segment := path[0]
+	matches := children(node, segment)
+	if len(matches) == 0 && create {
+		child, err := createChild(node, segment, path[1:])
+		if err != nil {
+			return 0, err
+		}
+		if child != nil {
+			matches = []*yaml.Node{child}
+		}
+	}
+	count := 0
+	for _, child := range matches {
+		n, err := SetPath(child, path[1:], value, create)
+		if err != nil {
+			return count, err
+		}
+		count += n
+	}
+	return count, nil
+}
+
+func createChild(node *yaml.Node, segment PathSegment, remaining []PathSegment) (*yaml.Node, error) {
+	child := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+	if len(remaining) > 0 {
+		child.Kind = yaml.MappingNode
+		child.Tag = ""
+		if remaining[0].kind != keySegment {
+			child.Kind = yaml.SequenceNode
+		}
+	}
+	switch segment.kind {
+	case keySegment:
+		if !ensureKind(node, yaml.MappingNode) {
+			return nil, errors.Errorf("cannot add key %s to a non object at line %d", segment.key, node.Line)
+		}
+		node.Content = append(node.Content, NewString(segment.key), child)
+	case indexSegment:
+		if !ensureKind(node, yaml.SequenceNode) || segment.index != len(node.Content) {
+			return nil, nil
+		}
+		node.Content = append(node.Content, child)
+	case filterSegment:
+		if !ensureKind(node, yaml.SequenceNode) {
+			return nil, nil
+		}
+		child = NewMap(segment.key, segment.value)
+		node.Content = append(node.Content, child)
+	default:
+		return nil, nil
+	}
+	return child, nil
+}
+
+// ensureKind converts an empty or null node to the given kind returning false if the node is some other kind
+func ensureKind(node *yaml.Node, kind yaml.Kind) bool {
+	if node.Kind == kind {
+		return true
+	}
+	if node.Kind == yaml.ScalarNode && (node.Tag == "!!null" || node.Value == "") {
+		node.Kind = kind
+		node.Tag = ""
+		node.Value = ""
+		node.Style = 0
+		return true
+	}
+	return false
+}
+
+// SetScalar sets the scalar value of the node. If the node is not a string and the new value is of the same type,
+// such as an integer or boolean, the type is retained otherwise the value is set as a string
+func SetScalar(node *yaml.Node, value string) {
+	if node.Kind == yaml.ScalarNode && node.Tag != "" && node.Tag != StringTag && node.Tag != "!!null" {
+		parsed := &yaml.Node{}
+		err := yaml.Unmarshal([]byte(value), parsed)
+		parsed = Root(parsed)
+		if err == nil && parsed != nil && parsed.Kind == yaml.ScalarNode && parsed.Tag == node.Tag {
+			node.Value = value
+			return
+		}
+	}
+	SetString(node, value)
+}
+
+// SetPath sets the value of all the nodes matching the given path in the document by editing the source so that the
+// formatting of the rest of the file is retained, returning the number of nodes modified. If create is true then any
+// missing mapping keys, the next index in a sequence or a sequence item matching a filter are added
+func (e *Editor) SetPath(node *yaml.Node, path []PathSegment, value string, create bool) (int, error) {
+	node = Root(node)
+	if node == nil {
+		return 0, nil
+	}
+	if node.Line == 0 {
+		return 0, errors.Errorf("cannot set a value within a value which has just been added")
+	}
+	if len(path) == 0 {
+		if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+			return 0, errors.Errorf("cannot set a value on a YAML object or array at line %d", node.Line)
+		}
+		return 1, e.SetScalar(node, value)
+	}
+	segment := path[0]
+	matches := children(node, segment)
+	if len(matches) == 0 && create {
+		return e.addChild(node, segment, path[1:], value)
+	}
+	count := 0
+	for _, child := range matches {
+		n, err := e.SetPath(child, path[1:], value, create)
+		if err != nil {
+			return count, err
+		}
+		count += n
+	}
+	return count, nil
+}
+
+// SetScalar sets the value of the scalar node in the source retaining the type of the node in the same way as
+// SetScalar
+func (e *Editor) SetScalar(node *yaml.Node, value string) error {
+	if node.Kind == yaml.ScalarNode && node.Tag != "" && node.Tag != StringTag && node.Tag != "!!null" {
+		parsed := &yaml.Node{}
+		err := yaml.Unmarshal([]byte(value), parsed)
+		parsed = Root(parsed)
+		if err == nil && parsed != nil && parsed.Kind == yaml.ScalarNode && parsed.Tag == node.Tag {
+			if node.Value == value {
+				return nil
+			}
+			start, end, err := e.scalarRange(node)
+			if err != nil {
+				return err
+			}
+			e.addReplacement(node.Line, replacement{start: start, end: end, text: value})
+			return nil
+		}
+	}
+	return e.SetString(node, value)
+}
+
+// addChild adds the missing child of the segment containing the rest of the path to the source. The child is also
+// added to the documents so that later paths can find it
+func (e *Editor) addChild(node *yaml.Node, segment PathSegment, remaining []PathSegment, value string) (int, error) {
+	switch segment.kind {
+	case keySegment:
+		child, err := newPathNode(remaining, value)
+		if err != nil || child == nil {
+			return 0, err
+		}
+		if node.Kind != yaml.MappingNode {
+			return 0, errors.Errorf("cannot add key %s to a non object at line %d", segment.key, node.Line)
+		}
+		err = e.AddMapValue(node, segment.key, child)
+		if err != nil {
+			return 0, err
+		}
+		node.Content = append(node.Content, NewString(segment.key), child)
+	case indexSegment:
+		if node.Kind != yaml.SequenceNode || segment.index != len(node.Content) {
+			return 0, nil
+		}
+		child, err := newPathNode(remaining, value)
+		if err != nil || child == nil {
+			return 0, err
+		}
+		err = e.AppendSequence(node, child)
+		if err != nil {
+			return 0, err
+		}
+		node.Content = append(node.Content, child)
+	case filterSegment:
+		if node.Kind != yaml.SequenceNode {
+			return 0, nil
+		}
+		child, err := newFilterNode(segment, remaining, value)
+		if err != nil || child == nil {
+			return 0, err
+		}
+		err = e.AppendSequence(node, child)
+		if err != nil {
+			return 0, err
+		}
+		node.Content = append(node.Content, child)
+	default:
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// newPathNode creates a new node containing the value at the given path or nil if the path cannot be created
+func newPathNode(path []PathSegment, value string) (*yaml.Node, error) {
+	if len(path) == 0 {
+		return NewString(value), nil
+	}
+	segment := path[0]
+	switch segment.kind {
+	case keySegment:
+		child, err := newPathNode(path[1:], value)
+		if err != nil || child == nil {
+			return nil, err
+		}
+		return &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{NewString(segment.key), child}}, nil
+	case indexSegment:
+		if segment.index != 0 {
+			return nil, nil
+		}
+		child, err := newPathNode(path[1:], value)
+		if err != nil || child == nil {
+			return nil, err
+		}
+		return &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{child}}, nil
+	case filterSegment:
+		child, err := newFilterNode(segment, path[1:], value)
+		if err != nil || child == nil {
+			return nil, err
+		}
+		return &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{child}}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// newFilterNode creates a new sequence item matching the filter which contains the value at the rest of the path
+func newFilterNode(segment PathSegment, remaining []PathSegment, value string) (*yaml.Node, error) {
+	if len(remaining) == 0 {
+		return nil, errors.Errorf("cannot set a value on the YAML object matching %s=%s", segment.key, segment.value)
+	}
+	if remaining[0].kind != keySegment {
+		return nil, nil
+	}
+	child, err := newPathNode(remaining, value)
+	if err != nil || child == nil {
+		return nil, err
+	}
+	item := NewMap(segment.key, segment.value)
+	item.Content = append(item.Content, child.Content...)
+	return item, nil
+}
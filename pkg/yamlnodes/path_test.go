@@ -0,0 +1,131 @@
+package yamlnodes_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/jx-promote/pkg/yamlnodes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetPath(t *testing.T) {
+	source := `# comment
+spec:
+  replicas: 1
+  containers:
+  - name: app
+    image: app:1.0.0
+  - name: sidecar
+    image: sidecar:1.0.0
+`
+	testCases := []struct {
+		expression string
+		value      string
+		create     bool
+		count      int
+		expected   string
+	}{
+		{
+			expression: ".spec.replicas",
+			value:      "3",
+			count:      1,
+			expected:   "# comment\nspec:\n  replicas: 3\n  containers:\n    - name: app\n      image: app:1.0.0\n    - name: sidecar\n      image: sidecar:1.0.0\n",
+		},
+		{
+			expression: "$.spec.containers[?(@.name=='app')].image",
+			value:      "app:1.2.3",
+			count:      1,
+			expected:   "# comment\nspec:\n  replicas: 1\n  containers:\n    - name: app\n      image: app:1.2.3\n    - name: sidecar\n      image: sidecar:1.0.0\n",
+		},
+		{
+			expression: ".spec.containers[1].image",
+			value:      "sidecar:2.0.0",
+			count:      1,
+			expected:   "# comment\nspec:\n  replicas: 1\n  containers:\n    - name: app\n      image: app:1.0.0\n    - name: sidecar\n      image: sidecar:2.0.0\n",
+		},
+		{
+			expression: ".spec.containers[*].imagePullPolicy",
+			value:      "Always",
+			count:      0,
+			expected:   source,
+		},
+		{
+			expression: `.metadata.labels["app.kubernetes.io/version"]`,
+			value:      "1.2.3",
+			create:     true,
+			count:      1,
+			expected:   "# comment\nspec:\n  replicas: 1\n  containers:\n    - name: app\n      image: app:1.0.0\n    - name: sidecar\n      image: sidecar:1.0.0\nmetadata:\n  labels:\n    app.kubernetes.io/version: 1.2.3\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		docs, err := yamlnodes.Parse([]byte(source))
+		require.NoError(t, err, "failed to parse YAML")
+
+		path, err := yamlnodes.ParsePath(tc.expression)
+		require.NoError(t, err, "failed to parse path %s", tc.expression)
+
+		count, err := yamlnodes.SetPath(docs[0], path, tc.value, tc.create)
+		require.NoError(t, err, "failed to set path %s", tc.expression)
+		assert.Equal(t, tc.count, count, "count for path %s", tc.expression)
+
+		if count == 0 {
+			continue
+		}
+		data, err := yamlnodes.ToBytes(docs)
+		require.NoError(t, err, "failed to marshal YAML")
+		assert.Equal(t, tc.expected, string(data), "YAML for path %s", tc.expression)
+	}
+}
+
+func TestEditorSetPath(t *testing.T) {
+	source := `# comment
+spec:
+  replicas: 1 # the replicas
+
+  containers:
+  - name: app
+    image: 'app:1.0.0'
+`
+	expected := `# comment
+spec:
+  replicas: 3 # the replicas
+
+  containers:
+  - name: app
+    image: 'app:1.2.3'
+  - name: sidecar
+    image: sidecar:1.2.3
+metadata:
+  labels:
+    app.kubernetes.io/version: 1.2.3
+`
+	editor, err := yamlnodes.NewEditor([]byte(source))
+	require.NoError(t, err, "failed to parse YAML")
+
+	values := []struct {
+		expression string
+		value      string
+	}{
+		{".spec.replicas", "3"},
+		{"$.spec.containers[?(@.name=='app')].image", "app:1.2.3"},
+		{".spec.containers[name=sidecar].image", "sidecar:1.2.3"},
+		{`.metadata.labels["app.kubernetes.io/version"]`, "1.2.3"},
+	}
+	for _, v := range values {
+		path, err := yamlnodes.ParsePath(v.expression)
+		require.NoError(t, err, "failed to parse path %s", v.expression)
+
+		count, err := editor.SetPath(editor.Docs[0], path, v.value, true)
+		require.NoError(t, err, "failed to set path %s", v.expression)
+		assert.Equal(t, 1, count, "count for path %s", v.expression)
+	}
+	assert.Equal(t, expected, string(editor.Bytes()))
+}
+
+func TestParsePathInvalid(t *testing.T) {
+	for _, expression := range []string{"", "$..name", ".spec[0", ".spec[-1]", ".spec[foo]"} {
+		_, err := yamlnodes.ParsePath(expression)
+		assert.Error(t, err, "expected error for path %s", expression)
+	}
+}
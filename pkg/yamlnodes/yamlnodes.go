@@ -0,0 +1,223 @@
+package yamlnodes
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/jenkins-x/jx-helpers/pkg/files"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// StringTag the tag used for string scalar nodes
+	StringTag = "!!str"
+)
+
+// LoadFile loads all the YAML documents from the given file retaining comments and the order of keys
+func LoadFile(path string) ([]*yaml.Node, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read file %s", path)
+	}
+	docs, err := Parse(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse YAML file %s", path)
+	}
+	return docs, nil
+}
+
+// Parse parses all the YAML documents in the given data
+func Parse(data []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		doc := &yaml.Node{}
+		err := decoder.Decode(doc)
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return docs, err
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// SaveFile saves the YAML documents to the given file
+func SaveFile(path string, docs []*yaml.Node) error {
+	data, err := ToBytes(docs)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal YAML for file %s", path)
+	}
+	var mode os.FileMode = files.DefaultFileWritePermissions
+	info, err := os.Stat(path)
+	if err == nil {
+		mode = info.Mode()
+	}
+	err = ioutil.WriteFile(path, data, mode)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return nil
+}
+
+// ToBytes marshals the YAML documents using a 2 space indentation
+func ToBytes(docs []*yaml.Node) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	for _, doc := range docs {
+		err := encoder.Encode(doc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := encoder.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Root returns the root content node of a document node or the node itself if its not a document
+func Root(node *yaml.Node) *yaml.Node {
+	if node != nil && node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		return node.Content[0]
+	}
+	return node
+}
+
+// GetMapValue returns the value node of the given key in the mapping node or nil if it does not exist
+func GetMapValue(node *yaml.Node, key string) *yaml.Node {
+	idx := mapKeyIndex(node, key)
+	if idx < 0 {
+		return nil
+	}
+	return node.Content[idx+1]
+}
+
+// GetMapString returns the string value of the given key in the mapping node or "" if it does not exist
+func GetMapString(node *yaml.Node, key string) string {
+	value := GetMapValue(node, key)
+	if value == nil || value.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return value.Value
+}
+
+// GetPath returns the node at the given path of mapping keys or nil if it does not exist
+func GetPath(node *yaml.Node, path ...string) *yaml.Node {
+	for _, key := range path {
+		node = GetMapValue(node, key)
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// GetPathString returns the string value at the given path of mapping keys or "" if it does not exist
+func GetPathString(node *yaml.Node, path ...string) string {
+	if len(path) == 0 {
+		return ""
+	}
+	parent := GetPath(node, path[:len(path)-1]...)
+	return GetMapString(parent, path[len(path)-1])
+}
+
+// SetMapString sets the string value of the given key in the mapping node adding the key if it does not exist.
+// Returns true if the value was changed
+func SetMapString(node *yaml.Node, key string, value string) bool {
+	existing := GetMapValue(node, key)
+	if existing != nil {
+		if existing.Kind == yaml.ScalarNode && existing.Value == value {
+			return false
+		}
+		SetString(existing, value)
+		return true
+	}
+	node.Content = append(node.Content, NewString(key), NewString(value))
+	return true
+}
+
+// SetPathString sets the string value at the given path of mapping keys creating any missing mapping nodes.
+// Returns true if the value was changed
+func SetPathString(node *yaml.Node, value string, path ...string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	parent := EnsureMapValue(node, yaml.MappingNode, path[:len(path)-1]...)
+	return SetMapString(parent, path[len(path)-1], value)
+}
+
+// EnsureMapValue returns the node at the given path of mapping keys creating any missing nodes.
+// The last node is created with the given kind if it does not exist
+func EnsureMapValue(node *yaml.Node, kind yaml.Kind, path ...string) *yaml.Node {
+	for i, key := range path {
+		value := GetMapValue(node, key)
+		if value == nil {
+			k := yaml.MappingNode
+			if i == len(path)-1 {
+				k = kind
+			}
+			value = &yaml.Node{Kind: k}
+			node.Content = append(node.Content, NewString(key), value)
+		}
+		node = value
+	}
+	return node
+}
+
+// RemoveMapKey removes the given key from the mapping node returning true if it was removed
+func RemoveMapKey(node *yaml.Node, key string) bool {
+	idx := mapKeyIndex(node, key)
+	if idx < 0 {
+		return false
+	}
+	node.Content = append(node.Content[:idx], node.Content[idx+2:]...)
+	return true
+}
+
+// SetString sets the scalar node to the given string value preserving any quoting style
+func SetString(node *yaml.Node, value string) {
+	node.Kind = yaml.ScalarNode
+	node.Tag = StringTag
+	node.Value = value
+	node.Style &^= yaml.TaggedStyle | yaml.FlowStyle
+	node.Content = nil
+}
+
+// NewString creates a new string scalar node
+func NewString(value string) *yaml.Node {
+	return &yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Tag:   StringTag,
+		Value: value,
+	}
+}
+
+// NewMap creates a new mapping node with the given key and value pairs ignoring empty values
+func NewMap(keyValues ...string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		if keyValues[i+1] != "" {
+			node.Content = append(node.Content, NewString(keyValues[i]), NewString(keyValues[i+1]))
+		}
+	}
+	return node
+}
+
+func mapKeyIndex(node *yaml.Node, key string) int {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return -1
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}